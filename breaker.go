@@ -0,0 +1,199 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling Firestore while a
+// CircuitBreaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type CircuitBreakerOptions struct {
+	// Threshold is how many consecutive Unavailable or DeadlineExceeded
+	// errors within Window open the circuit, 5 when unset.
+	Threshold int
+	// Window is 1 minute when unset.
+	Window time.Duration
+	// CoolDown is how long the circuit stays open before letting probes
+	// through, 30 seconds when unset.
+	CoolDown time.Duration
+	// Probes is how many calls at once are let through half-open, and how
+	// many must succeed to close the circuit, 1 when unset.
+	Probes int
+	// OnStateChange is called on every transition, e.g. to count them.
+	OnStateChange func(from, to CircuitState)
+	// Logger logs the transitions, DefaultLogger when nil.
+	Logger Logger
+}
+
+// CircuitBreaker fails calls fast with ErrCircuitOpen while Firestore looks
+// unavailable. Share one across collections with Use(client,
+// cb.Middleware()), or set it per collection with WithCircuitBreaker.
+type CircuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	mu           sync.Mutex
+	state        CircuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probes       int
+	successes    int
+}
+
+func NewCircuitBreaker(opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 5
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.CoolDown <= 0 {
+		opts.CoolDown = 30 * time.Second
+	}
+	if opts.Probes <= 0 {
+		opts.Probes = 1
+	}
+	return &CircuitBreaker{opts: opts}
+}
+
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) Middleware() Middleware {
+	return func(next Operation) Operation {
+		return func(ctx context.Context, call *OperationCall) (any, error) {
+			probe, err := cb.allow()
+			if err != nil {
+				return nil, err
+			}
+			out, err := next(ctx, call)
+			cb.record(probe, err)
+			return out, err
+		}
+	}
+}
+
+// WithCircuitBreaker runs the collection calls through cb, inside the
+// client middlewares.
+func (coll *Collection) WithCircuitBreaker(cb *CircuitBreaker) *Collection {
+	coll.middlewares = append(append([]Middleware{}, coll.middlewares...), cb.Middleware())
+	return coll
+}
+
+// allow reports whether a call may go through, and whether it's a probe.
+func (cb *CircuitBreaker) allow() (bool, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen {
+		if time.Since(cb.openedAt) < cb.opts.CoolDown {
+			return false, ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+	}
+	if cb.state == CircuitHalfOpen {
+		if cb.probes >= cb.opts.Probes {
+			return false, ErrCircuitOpen
+		}
+		cb.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (cb *CircuitBreaker) record(probe bool, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if probe && cb.state == CircuitHalfOpen {
+		cb.probes--
+	}
+	failed := isUnavailable(err)
+	switch cb.state {
+	case CircuitHalfOpen:
+		if !probe {
+			return
+		}
+		if failed {
+			cb.setState(CircuitOpen)
+		} else if cb.successes++; cb.successes >= cb.opts.Probes {
+			cb.setState(CircuitClosed)
+		}
+	case CircuitClosed:
+		if !failed {
+			cb.failures = 0
+			return
+		}
+		now := time.Now()
+		if cb.failures == 0 || now.Sub(cb.firstFailure) > cb.opts.Window {
+			cb.failures = 0
+			cb.firstFailure = now
+		}
+		if cb.failures++; cb.failures >= cb.opts.Threshold {
+			cb.setState(CircuitOpen)
+		}
+	}
+}
+
+// setState must be called with cb.mu held.
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	from := cb.state
+	cb.state = state
+	cb.failures = 0
+	cb.probes = 0
+	cb.successes = 0
+	if state == CircuitOpen {
+		cb.openedAt = time.Now()
+	}
+	logger := cb.opts.Logger
+	if logger == nil {
+		logger = DefaultLogger
+	}
+	if logger != nil {
+		logger.Printf("circuit breaker %s -> %s", from, state)
+	}
+	if cb.opts.OnStateChange != nil {
+		cb.opts.OnStateChange(from, state)
+	}
+}
+
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,151 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+	"time"
+)
+
+type BucketMode int
+
+const (
+	// BucketCountQueries runs one count aggregation per bucket.
+	BucketCountQueries BucketMode = iota
+	// BucketStream reads the field of every matching doc and buckets it
+	// client side, cheaper for small ranges.
+	BucketStream
+)
+
+type BucketOptions struct {
+	Mode BucketMode
+	// Location aligns day buckets to its midnight, defaults to UTC.
+	Location *time.Location
+	// Concurrency bounds the count queries in flight, defaults to 4.
+	Concurrency int
+}
+
+// CountByTimeBucket counts the docs matching condition per bucket of field
+// in [from, to). Buckets are keyed by their start, every bucket is present
+// even when empty. Buckets of whole days start at midnight in the options
+// location, so the first one may start before from.
+func (coll *Collection) CountByTimeBucket(ctx context.Context, condition []any, field string, from, to time.Time, bucket time.Duration, opts ...BucketOptions) (map[time.Time]int, error) {
+	return run(ctx, coll, call{Op: "CountByTimeBucket", Condition: condition}, func(ctx context.Context, c *OperationCall) (map[time.Time]int, error) {
+		return coll.countByTimeBucket(ctx, c.Condition, field, from, to, bucket, opts...)
+	})
+}
+
+func (coll *Collection) countByTimeBucket(ctx context.Context, condition []any, field string, from, to time.Time, bucket time.Duration, opts ...BucketOptions) (map[time.Time]int, error) {
+	opt := BucketOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Location == nil {
+		opt.Location = time.UTC
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+	if bucket <= 0 {
+		return nil, errors.New("bucket must be positive")
+	}
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+
+	starts := bucketStarts(from, to, bucket, opt.Location)
+	counts := make(map[time.Time]int, len(starts))
+	for _, start := range starts {
+		counts[start] = 0
+	}
+	condition = copyCondition(condition)
+	if len(condition) > 0 {
+		if _, ok := condition[len(condition)-1].(map[string]any); ok {
+			condition = condition[:len(condition)-1]
+		}
+	}
+	if opt.Mode == BucketStream {
+		return counts, coll.streamTimeBuckets(ctx, condition, field, from, to, starts, counts)
+	}
+
+	results := make([]int, len(starts))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opt.Concurrency)
+	for i := range starts {
+		i := i
+		bucketEnd := to
+		if i+1 < len(starts) {
+			bucketEnd = starts[i+1]
+		}
+		bucketStart := starts[i]
+		if i == 0 {
+			bucketStart = from
+		}
+		bucketCondition := append(copyCondition(condition), Between(field, bucketStart, bucketEnd))
+		g.Go(func() error {
+			count, err := coll.countDocs(gctx, bucketCondition)
+			results[i] = count
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	for i, start := range starts {
+		counts[start] = results[i]
+	}
+	return counts, nil
+}
+
+func (coll *Collection) streamTimeBuckets(ctx context.Context, condition []any, field string, from, to time.Time, starts []time.Time, counts map[time.Time]int) error {
+	query, err := coll.makeQuery(append(condition, Between(field, from, to)))
+	if err != nil {
+		return err
+	}
+	iter := query.Select(field).Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		val, err := snap.DataAt(field)
+		if err != nil {
+			continue
+		}
+		t, ok := val.(time.Time)
+		if !ok {
+			continue
+		}
+		// the last start not after t
+		for i := len(starts) - 1; i >= 0; i-- {
+			if !t.Before(starts[i]) {
+				counts[starts[i]]++
+				break
+			}
+		}
+	}
+}
+
+// bucketStarts splits [from, to) into buckets, day sized ones aligned to
+// midnight in loc and stepped by calendar days so DST shifts don't skew them.
+func bucketStarts(from, to time.Time, bucket time.Duration, loc *time.Location) []time.Time {
+	from = from.In(loc)
+	starts := make([]time.Time, 0)
+	if bucket%(24*time.Hour) == 0 {
+		days := int(bucket / (24 * time.Hour))
+		start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+		for ; start.Before(to); start = start.AddDate(0, 0, days) {
+			starts = append(starts, start)
+		}
+		return starts
+	}
+	for start := from.Truncate(bucket); start.Before(to); start = start.Add(bucket) {
+		starts = append(starts, start)
+	}
+	return starts
+}
@@ -0,0 +1,152 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+)
+
+// QueryBuilder composes a condition step by step. Every method returns a
+// new builder, so a partly built query can be reused safely.
+type QueryBuilder struct {
+	coll     *Collection
+	wheres   []any
+	orderBys []string
+	limit    *int
+	offset   *int
+	selects  []string
+}
+
+func (coll *Collection) Query() *QueryBuilder {
+	return &QueryBuilder{coll: coll}
+}
+
+func (q *QueryBuilder) clone() *QueryBuilder {
+	copied := *q
+	copied.wheres = append([]any{}, q.wheres...)
+	copied.orderBys = append([]string{}, q.orderBys...)
+	return &copied
+}
+
+func (q *QueryBuilder) Where(path string, op string, val any) *QueryBuilder {
+	next := q.clone()
+	next.wheres = append(next.wheres, []any{path, op, val})
+	return next
+}
+
+// WhereIf adds the where clause only when ok is true.
+func (q *QueryBuilder) WhereIf(ok bool, path string, op string, val any) *QueryBuilder {
+	if !ok {
+		return q
+	}
+	return q.Where(path, op, val)
+}
+
+// Match adds a Condition like Between or StartsWith.
+func (q *QueryBuilder) Match(c Condition) *QueryBuilder {
+	next := q.clone()
+	next.wheres = append(next.wheres, c)
+	return next
+}
+
+func (q *QueryBuilder) OrderBy(field string, direction firestore.Direction) *QueryBuilder {
+	next := q.clone()
+	dir := "asc"
+	if direction == firestore.Desc {
+		dir = "desc"
+	}
+	next.orderBys = append(next.orderBys, field+":"+dir)
+	return next
+}
+
+// Select reads only fields of the docs, and only their IDs when called
+// with none.
+func (q *QueryBuilder) Select(fields ...string) *QueryBuilder {
+	next := q.clone()
+	next.selects = append([]string{}, fields...)
+	return next
+}
+
+func (q *QueryBuilder) Limit(limit int) *QueryBuilder {
+	next := q.clone()
+	next.limit = &limit
+	return next
+}
+
+func (q *QueryBuilder) Offset(offset int) *QueryBuilder {
+	next := q.clone()
+	next.offset = &offset
+	return next
+}
+
+// Condition returns the built query in the []any condition format.
+func (q *QueryBuilder) Condition() []any {
+	condition := append([]any{}, q.wheres...)
+	options := make(map[string]any)
+	switch len(q.orderBys) {
+	case 0:
+	case 1:
+		options["orderBy"] = q.orderBys[0]
+	default:
+		options["orderBy"] = append([]string{}, q.orderBys...)
+	}
+	if q.limit != nil {
+		options["limit"] = *q.limit
+	}
+	if q.offset != nil {
+		options["offset"] = *q.offset
+	}
+	if q.selects != nil {
+		options["select"] = append([]string{}, q.selects...)
+	}
+	if len(options) > 0 {
+		condition = append(condition, options)
+	}
+	return condition
+}
+
+func (q *QueryBuilder) Docs(ctx context.Context) ([]map[string]any, error) {
+	condition := q.Condition()
+	return run(ctx, q.coll, call{Op: "ListDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		return cachedQuery(q.coll, "ListDocs", c.Condition, func() ([]map[string]any, error) {
+			return q.coll.listDocs(ctx, c.Condition)
+		})
+	})
+}
+
+// First returns the first matching doc, or nil when there is none.
+func (q *QueryBuilder) First(ctx context.Context) (map[string]any, error) {
+	condition := q.Limit(1).Condition()
+	return run(ctx, q.coll, call{Op: "FindDoc", Condition: condition}, func(ctx context.Context, c *OperationCall) (map[string]any, error) {
+		docs, err := q.coll.listDocs(ctx, c.Condition)
+		if err != nil || len(docs) == 0 {
+			return nil, err
+		}
+		return docs[0], nil
+	})
+}
+
+// Count counts the matching docs, ignoring limit and offset.
+func (q *QueryBuilder) Count(ctx context.Context) (int, error) {
+	condition := append([]any{}, q.wheres...)
+	return run(ctx, q.coll, call{Op: "CountDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (int, error) {
+		return cachedQuery(q.coll, "CountDocs", c.Condition, func() (int, error) {
+			return q.coll.countDocs(ctx, c.Condition)
+		})
+	})
+}
+
+// Paginate returns a page of the matching docs, overriding limit and offset.
+func (q *QueryBuilder) Paginate(ctx context.Context, page int, perPage int) (*PaginateResult, error) {
+	condition := q.Condition()
+	return run(ctx, q.coll, call{Op: "Paginate", Condition: condition}, func(ctx context.Context, c *OperationCall) (*PaginateResult, error) {
+		return cachedQuery(q.coll, "Paginate", []any{c.Condition, page, perPage}, func() (*PaginateResult, error) {
+			return q.coll.paginate(ctx, c.Condition, page, perPage)
+		})
+	})
+}
+
+// PaginateWithToken returns the page after pageToken of the matching docs,
+// see Collection.PaginateWithToken. The builder must have no offset.
+func (q *QueryBuilder) PaginateWithToken(ctx context.Context, pageToken string, perPage int) (*TokenPaginateResult, error) {
+	return q.coll.PaginateWithTokenTypedContext(ctx, q.Condition(), pageToken, perPage)
+}
@@ -0,0 +1,115 @@
+package cffirestore
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mixedShapeDocs are docs written by other tools: one without an id field,
+// one with a numeric one and one whose id field isn't its doc id.
+var mixedShapeDocs = map[string]map[string]any{
+	"a": {"name": "a"},
+	"b": {"name": "b", IdFieldName: int64(7)},
+	"c": {"name": "c", IdFieldName: "other"},
+}
+
+func TestBulkWritesSkipUnidentifiableDocs(t *testing.T) {
+	coll := testCollection(t)
+	// neither doc reaches Firestore: one has no id, the other is unchanged
+	docs := []map[string]any{
+		{"name": "no id"},
+		{IdFieldName: int64(7), "name": "kept"},
+	}
+	var seen []string
+	report := batchEach500Docs(context.Background(), coll, docs, func(doc map[string]any) map[string]any {
+		seen = append(seen, doc["name"].(string))
+		return doc
+	}, false)
+	if report.Failed != 1 || report.Succeeded != 0 || !strings.Contains(report.Entries[0].Error, "doc without _id or id field") {
+		t.Errorf("batch report = %+v", report)
+	}
+	if !reflect.DeepEqual(seen, []string{"kept"}) {
+		t.Errorf("batchFn saw %v, want the identified doc only", seen)
+	}
+
+	report = deleteEach500Docs(context.Background(), coll, docs[:1], DeleteOptions{SoftDelete: true}, time.Now(), &deleteProgress{})
+	if report.Failed != 1 || report.Succeeded != 0 {
+		t.Errorf("delete report = %+v", report)
+	}
+}
+
+func TestMemoryCollectionMixedShapeDocs(t *testing.T) {
+	m := NewMemoryCollection("tests")
+	for id, doc := range mixedShapeDocs {
+		m.docs[id] = deepCopyMap(doc).(map[string]any)
+	}
+	results, err := m.BatchDocs(nil, func(doc map[string]any) map[string]any {
+		doc["seen"] = true
+		return doc
+	})
+	if err != nil || len(results) != 3 {
+		t.Fatalf("BatchDocs = %d results, %v", len(results), err)
+	}
+	if _, err := m.DeleteDocs([]any{[]any{"seen", "==", true}}, true); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := m.ListDocs([]any{IsNotNull(DeletedAtFieldName)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 3 {
+		t.Errorf("soft deleted %d docs, want 3", len(deleted))
+	}
+	if _, err := m.DeleteDocs(nil); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := m.CountDocs(nil); count != 0 {
+		t.Errorf("%d docs left", count)
+	}
+}
+
+func TestMixedShapeDocs(t *testing.T) {
+	coll := emulatorCollection(t)
+	ctx := context.Background()
+	for id, doc := range mixedShapeDocs {
+		if _, err := coll.ref.Doc(id).Set(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := coll.BatchDocs(nil, func(doc map[string]any) map[string]any {
+		doc["seen"] = true
+		return doc
+	}); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := coll.ListDocs([]any{[]any{"seen", "==", true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc["_id"].(string))
+	}
+	sort.Strings(ids)
+	// updated in place, no doc "7" or "other" was created
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("updated docs = %v, want %v", ids, want)
+	}
+
+	if _, err := coll.DeleteDocs(nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := coll.CountDocs([]any{IsNull(DeletedAtFieldName)}); err != nil || count != 0 {
+		t.Errorf("live docs = %d, %v, want none", count, err)
+	}
+	if _, err := coll.DeleteDocs(nil); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := coll.CountDocs(nil); err != nil || count != 0 {
+		t.Errorf("docs left = %d, %v, want none", count, err)
+	}
+}
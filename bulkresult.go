@@ -0,0 +1,134 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"time"
+)
+
+// BulkEntry is the outcome of one doc in a bulk operation.
+type BulkEntry struct {
+	ID         string                 `json:"id"`
+	Op         string                 `json:"op"`
+	UpdateTime *time.Time             `json:"updateTime,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Result     *firestore.WriteResult `json:"-"`
+	Err        error                  `json:"-"`
+}
+
+// BulkResult reports every doc written or failed by a bulk operation, see
+// the *WithReport methods.
+type BulkResult struct {
+	Entries   []BulkEntry `json:"entries"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+}
+
+func (r *BulkResult) add(id string, op string, result *firestore.WriteResult, err error) {
+	entry := BulkEntry{ID: id, Op: op, Result: result, Err: err}
+	if err != nil {
+		entry.Error = err.Error()
+		r.Failed++
+	} else {
+		if result != nil {
+			entry.UpdateTime = &result.UpdateTime
+		}
+		r.Succeeded++
+	}
+	r.Entries = append(r.Entries, entry)
+}
+
+func (r *BulkResult) merge(other *BulkResult) {
+	for _, entry := range other.Entries {
+		r.add(entry.ID, entry.Op, entry.Result, entry.Err)
+	}
+}
+
+// Results returns the write results of the succeeded entries.
+func (r *BulkResult) Results() []*firestore.WriteResult {
+	results := make([]*firestore.WriteResult, 0, r.Succeeded)
+	for _, entry := range r.Entries {
+		if entry.Err == nil {
+			results = append(results, entry.Result)
+		}
+	}
+	return results
+}
+
+// ResultsByID returns the write results of the succeeded entries by doc id.
+func (r *BulkResult) ResultsByID() map[string]*firestore.WriteResult {
+	results := make(map[string]*firestore.WriteResult, r.Succeeded)
+	for _, entry := range r.Entries {
+		if entry.Err == nil && entry.ID != "" {
+			results[entry.ID] = entry.Result
+		}
+	}
+	return results
+}
+
+func (r *BulkResult) FailedIDs() []string {
+	ids := make([]string, 0, r.Failed)
+	for _, entry := range r.Entries {
+		if entry.Err != nil {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids
+}
+
+// Err joins the entry errors, each a *BulkDocError but those of entries
+// without doc id.
+func (r *BulkResult) Err() error {
+	errs := make([]error, 0, r.Failed)
+	for _, entry := range r.Entries {
+		if entry.Err == nil {
+			continue
+		}
+		if entry.ID == "" {
+			errs = append(errs, entry.Err)
+			continue
+		}
+		errs = append(errs, &BulkDocError{ID: entry.ID, Op: entry.Op, Err: entry.Err})
+	}
+	return errors.Join(errs...)
+}
+
+// BulkDocError is the error of one doc of a bulk operation.
+type BulkDocError struct {
+	ID  string
+	Op  string
+	Err error
+}
+
+func (e *BulkDocError) Error() string {
+	return fmt.Sprintf("%s doc %s: %v", e.Op, e.ID, e.Err)
+}
+
+func (e *BulkDocError) Unwrap() error {
+	return e.Err
+}
+
+// FailedIDs returns the ids of the docs err reports as failed, err being
+// returned by a bulk operation like DeleteDocs or BatchDocs.
+func FailedIDs(err error) []string {
+	ids := make([]string, 0)
+	var walk func(err error)
+	walk = func(err error) {
+		if e, ok := err.(*BulkDocError); ok {
+			ids = append(ids, e.ID)
+			return
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, err := range u.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		}
+	}
+	walk(err)
+	return lo.Uniq(ids)
+}
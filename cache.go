@@ -0,0 +1,208 @@
+package cffirestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readOps are the operations that don't invalidate the query cache, every
+// other operation is taken as a write.
+var readOps = map[string]bool{
+	"ListDocs": true, "ListDocsInRange": true, "SearchByPrefix": true, "ListDocsWhereIn": true, "ListDocsAs": true,
+	"FindDoc": true, "GetDoc": true, "GetDocAs": true, "GetDocBySlug": true, "CheckExists": true,
+	"CountDocs": true, "CountByTimeBucket": true, "ForEachDoc": true, "LoadDocs": true, "ListSubcollections": true, "ListRootCollections": true, "CountCollectionGroup": true, "PaginateAll": true, "GroupByCount": true, "ProbeFieldTypes": true, "ExportDocs": true, "ExportCSV": true, "SearchDocs": true, "QueryDocs": true, "QueryFirst": true, "QueryCount": true, "QueryPaginate": true,
+	"Paginate": true, "PaginateWithCount": true, "PaginateWithTotal": true, "PaginateWithToken": true, "ReadEvents": true,
+	"Watch": true, "ListenDoc": true, "LiveCache": true,
+}
+
+type queryCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]queryCacheEntry
+}
+
+type queryCacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// WithQueryCache caches ListDocs, CountDocs and Paginate results for ttl,
+// keyed by ConditionHash. Any write through the collection clears the cache,
+// writes from elsewhere show up once entries expire.
+func (coll *Collection) WithQueryCache(ttl time.Duration) *Collection {
+	coll.queryCache = &queryCache{ttl: ttl, entries: make(map[string]queryCacheEntry)}
+	return coll
+}
+
+func (c *queryCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *queryCache) set(key string, value any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = queryCacheEntry{value, time.Now().Add(ttl)}
+}
+
+func (c *queryCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]queryCacheEntry)
+}
+
+// cachedQuery returns a copy of the cached result of op with args, or runs
+// fn and caches a copy of its result.
+func cachedQuery[T any](coll *Collection, op string, args []any, fn func() (T, error)) (T, error) {
+	cache := coll.queryCache
+	if cache == nil {
+		return fn()
+	}
+	hash, err := ConditionHash(args)
+	if err != nil {
+		return fn()
+	}
+	key := op + ":" + hash
+	if val, ok := cache.get(key); ok {
+		return cloneResult(val).(T), nil
+	}
+	out, err := fn()
+	if err != nil {
+		return out, err
+	}
+	if ttl := coll.cacheFor(out, cache.ttl); ttl > 0 {
+		cache.set(key, cloneResult(out), ttl)
+	}
+	return out, nil
+}
+
+func cloneResult(v any) any {
+	switch r := v.(type) {
+	case *PaginateResult:
+		if r == nil {
+			return r
+		}
+		copied := *r
+		copied.Docs = deepCopyMap(r.Docs).([]map[string]any)
+		return &copied
+	default:
+		return deepCopyMap(v)
+	}
+}
+
+// ConditionHash returns a hash of condition that is the same for equal
+// conditions: map keys are sorted and values are tagged with their type, so
+// 1 and "1" differ. Conditions are hashed by their canonical values, and a
+// struct with unexported fields gives an error, the query then skips the
+// cache.
+func ConditionHash(condition []any) (string, error) {
+	var b strings.Builder
+	if err := writeCanonical(&b, reflect.ValueOf(condition)); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func writeCanonical(b *strings.Builder, rv reflect.Value) error {
+	if !rv.IsValid() {
+		b.WriteString("nil")
+		return nil
+	}
+	if rv.Type() == timeType {
+		t := rv.Interface().(time.Time)
+		b.WriteString("t:" + t.UTC().Format(time.RFC3339Nano))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			b.WriteString("nil")
+			return nil
+		}
+		return writeCanonical(b, rv.Elem())
+	case reflect.Bool:
+		b.WriteString("b:" + strconv.FormatBool(rv.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString("i:" + strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString("u:" + strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		b.WriteString("f:" + strconv.FormatFloat(rv.Float(), 'g', -1, 64))
+	case reflect.String:
+		b.WriteString("s:" + strconv.Quote(rv.String()))
+	case reflect.Slice, reflect.Array:
+		b.WriteString("[")
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if err := writeCanonical(b, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+	case reflect.Map:
+		entries := make([][2]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			var key, val strings.Builder
+			if err := writeCanonical(&key, iter.Key()); err != nil {
+				return err
+			}
+			if err := writeCanonical(&val, iter.Value()); err != nil {
+				return err
+			}
+			entries = append(entries, [2]string{key.String(), val.String()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i][0] < entries[j][0] })
+		b.WriteString("{")
+		for i, entry := range entries {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(entry[0] + ":" + entry[1])
+		}
+		b.WriteString("}")
+	case reflect.Struct:
+		if condition, ok := rv.Interface().(Condition); ok {
+			b.WriteString(rv.Type().String())
+			return writeCanonical(b, reflect.ValueOf(condition.canonical()))
+		}
+		b.WriteString(rv.Type().String() + "{")
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if !field.IsExported() {
+				return errors.New(fmt.Sprintf("cannot hash %s: unexported field %s", rv.Type(), field.Name))
+			}
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(field.Name + ":")
+			if err := writeCanonical(b, rv.Field(i)); err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+	default:
+		return errors.New(fmt.Sprintf("cannot hash %s", rv.Type()))
+	}
+	return nil
+}
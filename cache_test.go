@@ -0,0 +1,76 @@
+package cffirestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConditionHash(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	hash := func(condition []any) string {
+		t.Helper()
+		h, err := ConditionHash(condition)
+		if err != nil {
+			t.Fatalf("ConditionHash(%v): %v", condition, err)
+		}
+		return h
+	}
+	between := hash([]any{Between("createdAt", from, to)})
+	if between != hash([]any{Between("createdAt", from.In(time.FixedZone("ICT", 7*3600)), to)}) {
+		t.Error("Between over the same instants hashes differently")
+	}
+	for _, other := range [][]any{
+		{Between("createdAt", from, to.Add(time.Second))},
+		{BetweenInclusive("createdAt", from, to)},
+		{Between("updatedAt", from, to)},
+		{StartsWith("createdAt", "2024")},
+	} {
+		if hash(other) == between {
+			t.Errorf("%v hashes like Between(createdAt, from, to)", other)
+		}
+	}
+	or := []any{Or([]any{"status", "==", "open"}, And(Between("createdAt", from, to), IsNull("deletedAt")))}
+	if hash(or) != hash([]any{Or([]any{"status", "==", "open"}, And(Between("createdAt", from, to), IsNull("deletedAt")))}) {
+		t.Error("equal Or conditions hash differently")
+	}
+	if hash(or) == hash([]any{And([]any{"status", "==", "open"}, And(Between("createdAt", from, to), IsNull("deletedAt")))}) {
+		t.Error("Or hashes like And")
+	}
+	if _, err := ConditionHash([]any{struct{ hidden time.Time }{from}}); err == nil {
+		t.Error("ConditionHash of a struct with an unexported field returned no error")
+	}
+}
+
+func TestCachedQueryBetweenTimes(t *testing.T) {
+	coll := (&Collection{}).WithQueryCache(time.Minute)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	condition := []any{Between("createdAt", from, from.AddDate(0, 1, 0))}
+	calls := 0
+	for i := 0; i < 2; i++ {
+		n, err := cachedQuery(coll, "CountDocs", condition, func() (int, error) {
+			calls++
+			return 3, nil
+		})
+		if err != nil || n != 3 {
+			t.Fatalf("cachedQuery = %d, %v", n, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("query ran %d times, want 1", calls)
+	}
+
+	calls = 0
+	uncacheable := []any{struct{ hidden time.Time }{from}}
+	for i := 0; i < 2; i++ {
+		if _, err := cachedQuery(coll, "CountDocs", uncacheable, func() (int, error) {
+			calls++
+			return 3, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("uncacheable query ran %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,96 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+type missingField struct{}
+
+// Missing as an expected value matches only a field that is absent, while
+// nil matches only a field explicitly set to null.
+var Missing = missingField{}
+
+// CompareAndSwapField sets field to newValue only if it currently equals
+// expected, reporting whether it did.
+func (coll *Collection) CompareAndSwapField(ctx context.Context, id string, field string, expected, newValue any) (bool, error) {
+	return run(ctx, coll, call{Op: "CompareAndSwapField", DocID: id}, func(ctx context.Context, c *OperationCall) (bool, error) {
+		return coll.compareAndSwap(ctx, c.DocID, map[string]any{field: expected}, map[string]any{field: newValue})
+	})
+}
+
+// CompareAndSwapFields applies updates only if every field of expected
+// currently equals its value, reporting whether it did. Keys are dot paths.
+func (coll *Collection) CompareAndSwapFields(ctx context.Context, id string, expected map[string]any, updates map[string]any) (bool, error) {
+	return run(ctx, coll, call{Op: "CompareAndSwapFields", DocID: id}, func(ctx context.Context, c *OperationCall) (bool, error) {
+		return coll.compareAndSwap(ctx, c.DocID, expected, updates)
+	})
+}
+
+func (coll *Collection) compareAndSwap(ctx context.Context, id string, expected map[string]any, updates map[string]any) (bool, error) {
+	if len(updates) == 0 {
+		return false, errors.New("no updates")
+	}
+	for _, field := range coll.uniqueFields {
+		if _, ok := updates[field]; ok {
+			return false, errors.New(fmt.Sprintf("unique field %s cannot be swapped, use UpdateDoc", field))
+		}
+	}
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	ref := coll.ref.Doc(id)
+	swapped := false
+	err := coll.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		swapped = false
+		snap, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return docNotFound(id)
+		}
+		if err != nil {
+			return err
+		}
+		doc := snap.Data()
+		if err := coll.decryptFields(doc); err != nil {
+			return err
+		}
+		for _, field := range sortedKeys(expected) {
+			val, ok := GetPathAny(doc, field)
+			if !fieldMatches(val, ok, expected[field]) {
+				return nil
+			}
+		}
+		writes := make([]firestore.Update, 0, len(updates)+1)
+		for _, field := range sortedKeys(updates) {
+			writes = append(writes, firestore.Update{Path: field, Value: updates[field]})
+		}
+		writes, err = coll.encryptUpdates(writes)
+		if err != nil {
+			return err
+		}
+		if !lo.ContainsBy(writes, func(u firestore.Update) bool { return u.Path == UpdatedAtFieldName }) {
+			writes = append(writes, firestore.Update{Path: UpdatedAtFieldName, Value: coll.now()})
+		}
+		swapped = true
+		return tx.Update(ref, writes)
+	})
+	return swapped, err
+}
+
+func fieldMatches(val any, exists bool, expected any) bool {
+	if expected == Missing {
+		return !exists
+	}
+	if !exists {
+		return false
+	}
+	if val == nil || expected == nil {
+		return val == nil && expected == nil
+	}
+	return valuesEqual(val, expected, time.Microsecond)
+}
@@ -0,0 +1,127 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"context"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/proto"
+	"os"
+	"sync"
+	"testing"
+)
+
+const testProject = "cffirestore-test"
+
+// testClient returns a client of the emulator at FIRESTORE_EMULATOR_HOST, or
+// one that never connects when it is unset, enough to build queries.
+func testClient(t *testing.T) *firestore.Client {
+	t.Helper()
+	opts := []option.ClientOption{option.WithoutAuthentication()}
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		opts = append(opts, option.WithEndpoint("localhost:1"))
+	}
+	client, err := firestore.NewClient(context.Background(), testProject, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// testCollection returns a collection to build queries with.
+func testCollection(t *testing.T) *Collection {
+	return CollectionWithPath(testClient(t), "tests")
+}
+
+// emulatorCollection returns an empty collection of the emulator, skipping
+// the test when FIRESTORE_EMULATOR_HOST is unset.
+func emulatorCollection(t *testing.T) *Collection {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	return CollectionWithPath(testClient(t), "tests_"+newMemoryID())
+}
+
+// structuredQuery returns the query Firestore would receive.
+func structuredQuery(t *testing.T, query firestore.Query) *pb.StructuredQuery {
+	t.Helper()
+	b, err := query.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req pb.RunQueryRequest
+	if err := proto.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+	return req.GetStructuredQuery()
+}
+
+// orderByKeys returns the orderBy of sq as field:direction keys.
+func orderByKeys(sq *pb.StructuredQuery) []string {
+	return lo.Map(sq.GetOrderBy(), func(o *pb.StructuredQuery_Order, _ int) string {
+		if o.GetDirection() == pb.StructuredQuery_DESCENDING {
+			return o.GetField().GetFieldPath() + ":desc"
+		}
+		return o.GetField().GetFieldPath() + ":asc"
+	})
+}
+
+// recordingTracer records the name and doc count of every ended span.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []recordedSpan
+}
+
+type recordedSpan struct {
+	name     string
+	docCount int
+}
+
+func (tr *recordingTracer) Start(ctx context.Context, info SpanInfo) (context.Context, Span) {
+	return ctx, &recordingSpan{tr, info.Name}
+}
+
+// counts returns the doc counts of the spans named name.
+func (tr *recordingTracer) counts(name string) []int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	counts := make([]int, 0)
+	for _, span := range tr.spans {
+		if span.name == name {
+			counts = append(counts, span.docCount)
+		}
+	}
+	return counts
+}
+
+type recordingSpan struct {
+	tr   *recordingTracer
+	name string
+}
+
+func (s *recordingSpan) End(docCount int, err error) {
+	s.tr.mu.Lock()
+	defer s.tr.mu.Unlock()
+	s.tr.spans = append(s.tr.spans, recordedSpan{s.name, docCount})
+}
+
+// seedDocs writes n docs with ids doc0000... and fields n and group.
+func seedDocs(t *testing.T, coll *Collection, n int) {
+	t.Helper()
+	ctx := context.Background()
+	bw := coll.Client.BulkWriter(ctx)
+	for i := 0; i < n; i++ {
+		if _, err := bw.Set(coll.ref.Doc(fmt.Sprintf("doc%04d", i)), map[string]any{
+			"n":                int64(i),
+			"group":            int64(i % 3),
+			DeletedAtFieldName: nil,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bw.End()
+}
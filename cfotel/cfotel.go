@@ -0,0 +1,53 @@
+// Package cfotel traces cffirestore operations with OpenTelemetry. It is a
+// module of its own, so only its users depend on OpenTelemetry:
+//
+//	go get github.com/classfunc/cffirestore/cfotel
+//
+//	cffirestore.DefaultTracer = cfotel.NewTracer(otel.GetTracerProvider())
+package cfotel
+
+import (
+	"context"
+	"github.com/classfunc/cffirestore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/classfunc/cffirestore"
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func NewTracer(tp trace.TracerProvider) cffirestore.Tracer {
+	return &tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+func (t *tracer) Start(ctx context.Context, info cffirestore.SpanInfo) (context.Context, cffirestore.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "firestore"),
+		attribute.String("cffirestore.collection", info.Path),
+	}
+	if info.DocID != "" {
+		attrs = append(attrs, attribute.String("cffirestore.doc_id", info.DocID))
+	}
+	if info.Condition != "" {
+		attrs = append(attrs, attribute.String("cffirestore.condition", info.Condition))
+	}
+	ctx, span := t.tracer.Start(ctx, info.Name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, &otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) End(docCount int, err error) {
+	s.span.SetAttributes(attribute.Int("cffirestore.doc_count", docCount))
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,61 @@
+package cffirestore
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock stamps the createdAt, updatedAt and deletedAt fields.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// DefaultClock is used by collections without their own clock, see
+// WithClock.
+var DefaultClock Clock = realClock{}
+
+func (coll *Collection) WithClock(clock Clock) *Collection {
+	coll.clock = clock
+	return coll
+}
+
+func (coll *Collection) now() time.Time {
+	if coll.clock != nil {
+		return coll.clock.Now()
+	}
+	return DefaultClock.Now()
+}
+
+// FakeClock is a Clock standing still until Set or Advance, for tests.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
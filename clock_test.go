@@ -0,0 +1,77 @@
+package cffirestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockStampsMemoryDocs(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	m := NewMemoryCollection("tests")
+	m.Config().WithClock(clock)
+	id := "a"
+	if _, _, err := m.AddDocWithId(&id, nil, map[string]any{"name": "John Doe"}); err != nil {
+		t.Fatal(err)
+	}
+	assertStamps(t, m, id, start, start, nil)
+
+	clock.Advance(time.Minute)
+	if _, err := m.UpdateDoc(id, map[string]any{"name": "Jane Doe"}); err != nil {
+		t.Fatal(err)
+	}
+	assertStamps(t, m, id, start, start.Add(time.Minute), nil)
+
+	clock.Advance(time.Minute)
+	if _, err := m.BatchDocs([]any{[]any{"name", "==", "Jane Doe"}}, func(doc map[string]any) map[string]any {
+		doc["status"] = "done"
+		return doc
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertStamps(t, m, id, start, start.Add(2*time.Minute), nil)
+
+	deleted := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	clock.Set(deleted)
+	if _, err := m.DeleteDoc(id, true); err != nil {
+		t.Fatal(err)
+	}
+	assertStamps(t, m, id, start, deleted, deleted)
+}
+
+func TestDefaultClock(t *testing.T) {
+	frozen := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	defer func(clock Clock) { DefaultClock = clock }(DefaultClock)
+	DefaultClock = NewFakeClock(frozen)
+	m := NewMemoryCollection("tests")
+	id := "a"
+	if _, _, err := m.AddDocWithId(&id, nil, map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+	assertStamps(t, m, id, frozen, frozen, nil)
+
+	// a collection clock wins over the default one
+	own := frozen.Add(time.Hour)
+	m.Config().WithClock(NewFakeClock(own))
+	if _, err := m.UpdateDoc(id, map[string]any{"name": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	assertStamps(t, m, id, frozen, own, nil)
+}
+
+func assertStamps(t *testing.T, m *MemoryCollection, id string, createdAt, updatedAt time.Time, deletedAt any) {
+	t.Helper()
+	doc, err := m.GetDoc(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc[CreatedAtFieldName] != createdAt {
+		t.Errorf("createdAt = %v, want %v", doc[CreatedAtFieldName], createdAt)
+	}
+	if doc[UpdatedAtFieldName] != updatedAt {
+		t.Errorf("updatedAt = %v, want %v", doc[UpdatedAtFieldName], updatedAt)
+	}
+	if doc[DeletedAtFieldName] != deletedAt {
+		t.Errorf("deletedAt = %v, want %v", doc[DeletedAtFieldName], deletedAt)
+	}
+}
@@ -0,0 +1,221 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/iterator"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// FieldType describes how condition values for a field are coerced before
+// they reach Firestore, see Collection.WithFieldTypes.
+type FieldType int
+
+const (
+	FieldTypeAny FieldType = iota
+	FieldTypeString
+	FieldTypeInt
+	FieldTypeFloat
+	FieldTypeBool
+	FieldTypeTime
+)
+
+func (ft FieldType) String() string {
+	switch ft {
+	case FieldTypeString:
+		return "string"
+	case FieldTypeInt:
+		return "int"
+	case FieldTypeFloat:
+		return "float"
+	case FieldTypeBool:
+		return "bool"
+	case FieldTypeTime:
+		return "time"
+	default:
+		return "any"
+	}
+}
+
+// WithFieldTypes enables condition value coercion for the given fields.
+// Keys are dot paths, as used in conditions.
+func (coll *Collection) WithFieldTypes(types map[string]FieldType) *Collection {
+	coll.fieldTypesMu.Lock()
+	defer coll.fieldTypesMu.Unlock()
+	if coll.fieldTypes == nil {
+		coll.fieldTypes = make(map[string]FieldType)
+	}
+	for path, ft := range types {
+		coll.fieldTypes[path] = ft
+	}
+	return coll
+}
+
+// ProbeFieldTypes reads one document of the collection and registers the
+// types of its fields for coercion. Explicitly registered types are kept.
+func (coll *Collection) ProbeFieldTypes() error {
+	_, err := run(context.Background(), coll, call{Op: "ProbeFieldTypes"}, func(ctx context.Context, c *OperationCall) (any, error) {
+		return nil, coll.probeFieldTypes(ctx)
+	})
+	return err
+}
+
+func (coll *Collection) probeFieldTypes(ctx context.Context) error {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	iter := coll.ref.Limit(1).Documents(ctx)
+	defer iter.Stop()
+	doc, err := iter.Next()
+	if errors.Is(err, iterator.Done) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	probed := make(map[string]FieldType)
+	probeFieldTypes(doc.Data(), "", probed)
+	coll.fieldTypesMu.Lock()
+	defer coll.fieldTypesMu.Unlock()
+	if coll.fieldTypes == nil {
+		coll.fieldTypes = make(map[string]FieldType)
+	}
+	for path, ft := range probed {
+		if _, ok := coll.fieldTypes[path]; !ok {
+			coll.fieldTypes[path] = ft
+		}
+	}
+	return nil
+}
+
+func probeFieldTypes(data map[string]any, prefix string, out map[string]FieldType) {
+	for key, val := range data {
+		path := prefix + key
+		switch v := val.(type) {
+		case map[string]any:
+			probeFieldTypes(v, path+".", out)
+		case []any:
+			if len(v) > 0 {
+				if ft := fieldTypeOf(v[0]); ft != FieldTypeAny {
+					out[path] = ft
+				}
+			}
+		default:
+			if ft := fieldTypeOf(v); ft != FieldTypeAny {
+				out[path] = ft
+			}
+		}
+	}
+}
+
+func fieldTypeOf(v any) FieldType {
+	switch v.(type) {
+	case string:
+		return FieldTypeString
+	case int64:
+		return FieldTypeInt
+	case float64:
+		return FieldTypeFloat
+	case bool:
+		return FieldTypeBool
+	case time.Time:
+		return FieldTypeTime
+	default:
+		return FieldTypeAny
+	}
+}
+
+func (coll *Collection) coerceConditionValue(path string, op string, val any) (any, error) {
+	coll.fieldTypesMu.RLock()
+	ft, ok := coll.fieldTypes[path]
+	coll.fieldTypesMu.RUnlock()
+	if !ok || ft == FieldTypeAny || val == nil {
+		return val, nil
+	}
+	switch strings.ToLower(op) {
+	case "in", "not-in", "array-contains-any":
+		rv := reflect.ValueOf(val)
+		if rv.Kind() != reflect.Slice {
+			return nil, errors.New(fmt.Sprintf("field %s: %s expects a slice value, got %T", path, op, val))
+		}
+		out := make([]any, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			c, err := coerceValue(path, ft, rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	default:
+		return coerceValue(path, ft, val)
+	}
+}
+
+func coerceValue(path string, ft FieldType, val any) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	fail := func() (any, error) {
+		return nil, errors.New(fmt.Sprintf("field %s: cannot coerce %T(%v) to %s", path, val, val, ft))
+	}
+	switch ft {
+	case FieldTypeTime:
+		switch v := val.(type) {
+		case time.Time:
+			return v, nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return fail()
+			}
+			return t, nil
+		}
+	case FieldTypeBool:
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(v) {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+		}
+	case FieldTypeInt:
+		rv := reflect.ValueOf(val)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			f := rv.Float()
+			if f != math.Trunc(f) || math.IsInf(f, 0) || math.IsNaN(f) {
+				return fail()
+			}
+			return int64(f), nil
+		}
+	case FieldTypeFloat:
+		rv := reflect.ValueOf(val)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		}
+	case FieldTypeString:
+		if s, ok := val.(string); ok {
+			return s, nil
+		}
+	default:
+		return val, nil
+	}
+	return fail()
+}
@@ -0,0 +1,58 @@
+package cffirestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestFieldTypesConcurrentQueries(t *testing.T) {
+	coll := testCollection(t).WithFieldTypes(map[string]FieldType{"age": FieldTypeInt})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			coll.WithFieldTypes(map[string]FieldType{fmt.Sprintf("f%d", i): FieldTypeString})
+		}()
+		go func() {
+			defer wg.Done()
+			query, err := coll.MakeQueryE([]any{[]any{"age", "==", float64(18)}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if v := structuredQuery(t, query).GetWhere().GetFieldFilter().GetValue(); v.GetIntegerValue() != 18 {
+				t.Errorf("age value = %v, want 18", v)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestProbeFieldTypesConcurrentQueries(t *testing.T) {
+	coll := emulatorCollection(t)
+	seedDocs(t, coll, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := coll.ProbeFieldTypes(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := coll.ListDocsContext(context.Background(), []any{[]any{"n", "==", float64(0)}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if ft := coll.fieldTypes["n"]; ft != FieldTypeInt {
+		t.Errorf("probed n type = %v, want int", ft)
+	}
+}
@@ -7,10 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/samber/lo"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"reflect"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,38 +20,99 @@ var UidFieldName = "uid"
 var CreatedAtFieldName = "createdAt"
 var UpdatedAtFieldName = "updatedAt"
 var DeletedAtFieldName = "deletedAt"
+var SlugFieldName = "slug"
 
-type ICFFSCollection interface {
+type ICFFSReader interface {
 	Ref() *firestore.CollectionRef
-	AddDocData(v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error)
-	AddDoc(uid *string, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error)
-	AddDocWithId(id *string, uid *string, v map[string]any) (*firestore.DocumentRef, *firestore.WriteResult, error)
 	ListDocs(condition []any) ([]map[string]any, error)
 	FindDoc(condition []any) (map[string]any, error)
 	GetDoc(id string) (map[string]any, error)
-	UpdateDoc(id string, data map[string]any) (*firestore.WriteResult, error)
-	DeleteDoc(id string, isSoftDelete ...bool) (*firestore.WriteResult, error)
-	DeleteDocs(condition []any, isSoftDelete ...bool) ([]*firestore.WriteResult, error)
 	MakeQuery(condition []any) firestore.Query
 	CountDocs(condition []any) (int, error)
 	Paginate(condition []any, page int, perPage int) (map[string]any, error)
 	PaginateWithCount(condition []any, page int, perPage int) (map[string]any, error)
-	BatchDocs(condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error)
 	CheckExists(condition []any) (bool, error)
 }
 
+type ICFFSWriter interface {
+	AddDocData(v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error)
+	AddDoc(uid *string, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error)
+	AddDocWithId(id *string, uid *string, v map[string]any) (*firestore.DocumentRef, *firestore.WriteResult, error)
+	UpdateDoc(id string, data map[string]any) (*firestore.WriteResult, error)
+	DeleteDoc(id string, isSoftDelete ...bool) (*firestore.WriteResult, error)
+	DeleteDocs(condition []any, isSoftDelete ...bool) ([]*firestore.WriteResult, error)
+	BatchDocs(condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error)
+}
+
+type ICFFSCollection interface {
+	ICFFSReader
+	ICFFSWriter
+}
+
+var (
+	_ ICFFSReader     = (*Collection)(nil)
+	_ ICFFSWriter     = (*Collection)(nil)
+	_ ICFFSCollection = (*Collection)(nil)
+)
+
 type Collection struct {
 	Path   string
 	Client *firestore.Client
 	ref    *firestore.CollectionRef
+
+	// fieldTypesMu guards fieldTypes, which ProbeFieldTypes fills while
+	// queries read it.
+	fieldTypesMu     sync.RWMutex
+	fieldTypes       map[string]FieldType
+	normalizedFields []string
+
+	keywordFields  []string
+	keywordOptions KeywordOptions
+
+	softDeleteCascade     bool
+	cascadeSubcollections []string
+	excludeDeleted        bool
+
+	responseOptions *ResponseOptions
+	tracer          Tracer
+
+	schema        *jsonschema.Schema
+	schemaOptions SchemaOptions
+
+	encryptedFields []string
+	encryptionKeys  []fieldKey
+
+	uniqueFields []string
+
+	timeout time.Duration
+	logger  Logger
+
+	queryCache *queryCache
+	appendOnly bool
+	maxPerPage int
+	maxCount   int
+
+	inconsistentCount bool
+
+	maxDocs        int
+	truncationMode TruncationMode
+
+	ttlField string
+
+	protectedFieldsMode ProtectedFieldsMode
+	indexRecorder       *IndexRecorder
+	clock               Clock
+
+	middlewares []Middleware
 }
 
 func CollectionWithPath(client *firestore.Client, path string) *Collection {
 	ref := client.Collection(path)
 	return &Collection{
-		Path:   path,
-		Client: client,
-		ref:    ref,
+		Path:        path,
+		Client:      client,
+		ref:         ref,
+		middlewares: clientMiddlewares(client),
 	}
 }
 
@@ -59,26 +121,154 @@ func (coll *Collection) Ref() *firestore.CollectionRef {
 }
 
 func (coll *Collection) AddDocData(v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
-	return coll.AddDoc(nil, v, docIdPrefix...)
+	return coll.AddDocDataContext(context.Background(), v, docIdPrefix...)
+}
+
+// AddDocDataContext is AddDocData with ctx.
+func (coll *Collection) AddDocDataContext(ctx context.Context, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(ctx, coll, call{Op: "AddDocData", Data: v}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		ref, result, err = coll.addDoc(ctx, nil, c.Data, docIdPrefix...)
+		return result, err
+	})
+	return ref, result, err
 }
 
 func (coll *Collection) AddDoc(uid *string, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	return coll.AddDocContext(context.Background(), uid, v, docIdPrefix...)
+}
+
+// AddDocContext is AddDoc with ctx.
+func (coll *Collection) AddDocContext(ctx context.Context, uid *string, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(ctx, coll, call{Op: "AddDoc", Data: v}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		ref, result, err = coll.addDoc(ctx, uid, c.Data, docIdPrefix...)
+		return result, err
+	})
+	return ref, result, err
+}
+
+func (coll *Collection) addDoc(ctx context.Context, uid *string, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
 	ref := coll.ref.NewDoc()
 	idPrefix := ""
 	if len(docIdPrefix) > 0 {
 		idPrefix = docIdPrefix[0]
 	}
 	id := fmt.Sprintf("%s%s", idPrefix, ref.ID)
-	return coll.AddDocWithId(&id, uid, v)
+	return coll.addDocWithId(ctx, &id, uid, v, AddOptions{})
 }
 
 func (coll *Collection) AddDocWithId(id *string, uid *string, v map[string]any) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	return coll.AddDocWithIdContext(context.Background(), id, uid, v)
+}
+
+// AddDocWithIdContext is AddDocWithId with ctx.
+func (coll *Collection) AddDocWithIdContext(ctx context.Context, id *string, uid *string, v map[string]any) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(ctx, coll, call{Op: "AddDocWithId", DocID: lo.FromPtr(id), Data: v}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		ref, result, err = coll.addDocWithId(ctx, c.docIDPtr(), uid, c.Data, AddOptions{})
+		return result, err
+	})
+	return ref, result, err
+}
+
+// AddOptions changes how AddDocWithOptions writes a doc.
+type AddOptions struct {
+	// PreserveTimestamps keeps the createdAt and updatedAt given in v, they
+	// are only stamped when absent or nil, e.g. to import historical data.
+	PreserveTimestamps bool
+}
+
+// UpdateOptions changes how UpdateDocWithOptions writes a doc.
+type UpdateOptions struct {
+	// PreserveTimestamps keeps the updatedAt given in data, it is only
+	// stamped when absent or nil, e.g. for backfill jobs.
+	PreserveTimestamps bool
+}
+
+func (coll *Collection) AddDocWithOptions(id *string, uid *string, v map[string]any, opts AddOptions) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(context.Background(), coll, call{Op: "AddDocWithId", DocID: lo.FromPtr(id), Data: v}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		ref, result, err = coll.addDocWithId(ctx, c.docIDPtr(), uid, c.Data, opts)
+		return result, err
+	})
+	return ref, result, err
+}
+
+// stampTime sets v[field] to now, unless preserve is set and v has a value.
+func stampTime(v map[string]any, field string, now time.Time, preserve bool) {
+	if preserve && v[field] != nil {
+		return
+	}
+	v[field] = now
+}
+
+func (coll *Collection) addDocWithId(ctx context.Context, id *string, uid *string, v map[string]any, opts AddOptions) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	ref, stored, err := coll.prepareNewDoc(id, uid, v, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(coll.uniqueFields) > 0 {
+		result, err := coll.writeUnique(ctx, ref.ID, v, true, func(tx *firestore.Transaction, ref *firestore.DocumentRef) error {
+			return tx.Set(ref, stored)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return ref, result, nil
+	}
+	result, err := ref.Set(ctx, stored)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ref, result, nil
+}
+
+// AddStruct is AddDoc for a struct value, see ToDocMap.
+func (coll *Collection) AddStruct(uid *string, v any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(context.Background(), coll, call{Op: "AddStruct"}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		data, err := ToDocMap(v)
+		if err != nil {
+			return nil, err
+		}
+		ref, result, err = coll.addDoc(ctx, uid, data, docIdPrefix...)
+		return result, err
+	})
+	return ref, result, err
+}
+
+// AddStructWithId is AddDocWithId for a struct value, see ToDocMap.
+func (coll *Collection) AddStructWithId(id *string, uid *string, v any) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(context.Background(), coll, call{Op: "AddStructWithId", DocID: lo.FromPtr(id)}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		ref, result, err = coll.addStructWithId(ctx, c.docIDPtr(), uid, v)
+		return result, err
+	})
+	return ref, result, err
+}
+
+// prepareNewDoc stamps v for creation and returns its ref and the data to
+// store.
+func (coll *Collection) prepareNewDoc(id *string, uid *string, v map[string]any, opts AddOptions) (*firestore.DocumentRef, map[string]any, error) {
+	dropMetadata(v)
+	if err := encodeValues(v); err != nil {
+		return nil, nil, err
+	}
 	if uid != nil {
 		v[UidFieldName] = *uid
 	}
-	v[CreatedAtFieldName] = time.Now()
-	v[UpdatedAtFieldName] = time.Now()
+	now := coll.now()
+	stampTime(v, CreatedAtFieldName, now, opts.PreserveTimestamps)
+	stampTime(v, UpdatedAtFieldName, now, opts.PreserveTimestamps)
 	v[DeletedAtFieldName] = nil
+	coll.normalizeFields(v)
+	coll.setSearchKeywords(v)
+	if err := coll.validateDoc(v); err != nil {
+		return nil, nil, err
+	}
 
 	ref := coll.ref.NewDoc()
 	if id != nil {
@@ -88,26 +278,194 @@ func (coll *Collection) AddDocWithId(id *string, uid *string, v map[string]any)
 		v[IdFieldName] = ref.ID
 	}
 
-	result, err := ref.Set(context.Background(), v)
+	stored, err := coll.encryptFields(v)
 	if err != nil {
 		return nil, nil, err
 	}
-	return ref, result, nil
+	return ref, stored, nil
+}
+
+func (coll *Collection) addStructWithId(ctx context.Context, id *string, uid *string, v any) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	data, err := ToDocMap(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	return coll.addDocWithId(ctx, id, uid, data, AddOptions{})
 }
 
 func (coll *Collection) ListDocs(condition []any) ([]map[string]any, error) {
-	query := coll.MakeQuery(condition)
+	return coll.ListDocsContext(context.Background(), condition)
+}
 
-	docs, err := query.Documents(context.Background()).GetAll()
+// ListDocsContext is ListDocs with ctx.
+func (coll *Collection) ListDocsContext(ctx context.Context, condition []any) ([]map[string]any, error) {
+	return run(ctx, coll, call{Op: "ListDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		return cachedQuery(coll, "ListDocs", c.Condition, func() ([]map[string]any, error) {
+			return coll.listDocs(ctx, c.Condition)
+		})
+	})
+}
 
+func (coll *Collection) listDocs(ctx context.Context, condition []any) ([]map[string]any, error) {
+	docs, err := coll.listRawDocs(ctx, condition)
+	if err != nil && !errors.Is(err, ErrResultTruncated) {
+		return nil, err
+	}
+	return coll.shapeDocs(docs), err
+}
+
+// listRawDocs lists docs without applying the response options.
+func (coll *Collection) listRawDocs(ctx context.Context, condition []any) ([]map[string]any, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	query, plan, err := coll.makeFilteredQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	max := coll.maxDocsCap(condition)
+	if max > 0 {
+		// one more doc tells whether the result was truncated
+		query = query.Limit(max + 1)
+	}
+
+	iter := query.Documents(ctx)
+	if tx := readTx(ctx); tx != nil {
+		iter = tx.Documents(query)
+	}
+	snaps, err := iter.GetAll()
+
+	if err != nil {
+		return nil, err
+	}
+	docs, err := coll.decryptDocs(coll.docResponses(snaps))
 	if err != nil {
 		return nil, err
 	}
-	return docSnapsDataToMap(docs), nil
+	docs = lo.Filter(docs, func(doc map[string]any, _ int) bool { return plan.keep(doc) })
+	return coll.truncateDocs(docs, max)
+}
 
+// bulkPageSize is how many docs bulk operations read per page.
+const bulkPageSize = 500
+
+// checkBulkOptions rejects the options of condition that would page op
+// through the docs by another order than eachPage's default one.
+func (coll *Collection) checkBulkOptions(op string, condition []any) error {
+	plan, err := coll.planQuery(condition)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"orderBy", "limit", "limitToLast", "offset", "startAt", "startAfter", "endAt", "endBefore"} {
+		if _, ok := plan.option(name); ok {
+			return errors.New(fmt.Sprintf("%s does not take the %s option", op, name))
+		}
+	}
+	return nil
+}
+
+// eachPage calls fn with the docs matching condition pageSize at a time, so
+// large results aren't held in memory. Pages follow each other with
+// StartAfter cursors, ordered by the fields of inequality filters then by
+// document ID unless condition orders them, which keeps them stable while fn
+// writes fields other than the inequality ones. A non nil fields selects only
+// those fields, none for keys-only reads.
+func (coll *Collection) eachPage(ctx context.Context, condition []any, fields []string, pageSize int, fn func(snaps []*firestore.DocumentSnapshot) error) error {
+	query, plan, err := coll.makeFilteredQuery(condition)
+	if err != nil {
+		return err
+	}
+	if orderBys, _ := plan.fullOrderBys(); len(orderBys) == 0 {
+		query = query.OrderBy(firestore.DocumentID, firestore.Asc)
+	}
+	if fields != nil {
+		query = query.Select(append(fields[:len(fields):len(fields)], plan.missing...)...)
+	}
+	remaining := -1
+	if val, ok := plan.option("limit"); ok {
+		if remaining, err = intOption("limit", val); err != nil {
+			return err
+		}
+	}
+	var last *firestore.DocumentSnapshot
+	for remaining != 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		size := pageSize
+		if remaining > 0 && remaining < size {
+			size = remaining
+		}
+		page := query.Limit(size)
+		if last != nil {
+			page = page.StartAfter(last).Offset(0)
+		}
+		pageCtx, cancel := coll.withTimeout(ctx)
+		snaps, err := page.Documents(pageCtx).GetAll()
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(snaps) == 0 {
+			return nil
+		}
+		kept := lo.Filter(snaps, func(snap *firestore.DocumentSnapshot, _ int) bool { return plan.keep(snap.Data()) })
+		if len(kept) > 0 {
+			if err := fn(kept); err != nil {
+				return err
+			}
+		}
+		if len(snaps) < size {
+			return nil
+		}
+		last = snaps[len(snaps)-1]
+		if remaining > 0 {
+			remaining -= len(snaps)
+		}
+	}
+	return nil
+}
+
+// ListDocsInRange lists docs whose field is in [from, to). A zero from or to
+// leaves that side of the range open.
+func (coll *Collection) ListDocsInRange(ctx context.Context, field string, from, to time.Time, condition []any) ([]map[string]any, error) {
+	var fromVal, toVal any
+	if !from.IsZero() {
+		fromVal = from
+	}
+	if !to.IsZero() {
+		toVal = to
+	}
+	condition = append([]any{Between(field, fromVal, toVal)}, condition...)
+	return run(ctx, coll, call{Op: "ListDocsInRange", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		return coll.listDocs(ctx, c.Condition)
+	})
+}
+
+// SearchByPrefix lists up to limit docs whose field starts with prefix.
+func (coll *Collection) SearchByPrefix(ctx context.Context, field string, prefix string, limit int) ([]map[string]any, error) {
+	condition := []any{StartsWith(field, prefix)}
+	if limit > 0 {
+		condition = append(condition, map[string]any{
+			"limit": limit,
+		})
+	}
+	return run(ctx, coll, call{Op: "SearchByPrefix", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		return coll.listDocs(ctx, c.Condition)
+	})
 }
 
 func (coll *Collection) FindDoc(condition []any) (map[string]any, error) {
+	return coll.FindDocContext(context.Background(), condition)
+}
+
+// FindDocContext is FindDoc with ctx.
+func (coll *Collection) FindDocContext(ctx context.Context, condition []any) (map[string]any, error) {
+	return run(ctx, coll, call{Op: "FindDoc", Condition: condition}, func(ctx context.Context, c *OperationCall) (map[string]any, error) {
+		return coll.findDoc(ctx, c.Condition)
+	})
+}
+
+func (coll *Collection) findDoc(ctx context.Context, condition []any) (map[string]any, error) {
 	lastCond, err := lo.Last(condition)
 	if err != nil {
 		return nil, err
@@ -120,12 +478,15 @@ func (coll *Collection) FindDoc(condition []any) (map[string]any, error) {
 		})
 	case reflect.Map:
 		//	append map[string]any{limit:1} condition
-		lastCondMap := lastCond.(map[string]any)
+		lastCondMap, ok := lastCond.(map[string]any)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("unhandled condition element: %T", lastCond))
+		}
 		lastCondMap["limit"] = 1
 		condition[len(condition)-1] = lastCondMap
 	default:
 	}
-	docs, err := coll.ListDocs(condition)
+	docs, err := coll.listDocs(ctx, condition)
 	if err != nil {
 		return nil, err
 	}
@@ -136,372 +497,637 @@ func (coll *Collection) FindDoc(condition []any) (map[string]any, error) {
 }
 
 func (coll *Collection) GetDoc(id string) (map[string]any, error) {
-	doc, err := coll.ref.Doc(id).Get(context.Background())
+	return coll.GetDocContext(context.Background(), id)
+}
+
+// GetDocContext is GetDoc with ctx.
+func (coll *Collection) GetDocContext(ctx context.Context, id string) (map[string]any, error) {
+	return run(ctx, coll, call{Op: "GetDoc", DocID: id}, func(ctx context.Context, c *OperationCall) (map[string]any, error) {
+		return coll.getDoc(ctx, c.DocID)
+	})
+}
+
+func (coll *Collection) getDoc(ctx context.Context, id string) (map[string]any, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	doc, err := coll.ref.Doc(id).Get(ctx)
 	if err != nil {
 		if status.Code(err) == codes.NotFound {
-			return nil, errors.New(fmt.Sprintf("doc not found: %s", id))
+			return nil, docNotFound(id)
 		}
 		return nil, err
 	}
 
-	return makeDocResponse(doc), nil
+	data := coll.docResponse(doc)
+	if err := coll.decryptFields(data); err != nil {
+		return nil, err
+	}
+	return coll.shapeDoc(data), nil
 }
 
 func (coll *Collection) UpdateDoc(id string, data map[string]any) (*firestore.WriteResult, error) {
-	data[UpdatedAtFieldName] = time.Now()
-	return coll.ref.Doc(id).Set(context.Background(), data, firestore.MergeAll)
+	return coll.UpdateDocContext(context.Background(), id, data)
+}
+
+// UpdateDocContext is UpdateDoc with ctx.
+func (coll *Collection) UpdateDocContext(ctx context.Context, id string, data map[string]any) (*firestore.WriteResult, error) {
+	return run(ctx, coll, call{Op: "UpdateDoc", DocID: id, Data: data}, func(ctx context.Context, c *OperationCall) (*firestore.WriteResult, error) {
+		data, err := coll.protectUpdate(c.Data, false)
+		if err != nil {
+			return nil, err
+		}
+		return coll.updateDoc(ctx, c.DocID, data, UpdateOptions{})
+	})
+}
+
+func (coll *Collection) UpdateDocWithOptions(id string, data map[string]any, opts UpdateOptions) (*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "UpdateDoc", DocID: id, Data: data}, func(ctx context.Context, c *OperationCall) (*firestore.WriteResult, error) {
+		data, err := coll.protectUpdate(c.Data, opts.PreserveTimestamps)
+		if err != nil {
+			return nil, err
+		}
+		return coll.updateDoc(ctx, c.DocID, data, opts)
+	})
+}
+
+func (coll *Collection) updateDoc(ctx context.Context, id string, data map[string]any, opts UpdateOptions) (*firestore.WriteResult, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	stored, err := coll.prepareUpdate(ctx, id, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(coll.uniqueFields) > 0 {
+		return coll.writeUnique(ctx, id, data, false, func(tx *firestore.Transaction, ref *firestore.DocumentRef) error {
+			return tx.Set(ref, stored, firestore.MergeAll)
+		})
+	}
+	return coll.ref.Doc(id).Set(ctx, stored, firestore.MergeAll)
+}
+
+// prepareUpdate stamps data for a merge into doc id and returns the data to
+// store.
+func (coll *Collection) prepareUpdate(ctx context.Context, id string, data map[string]any, opts UpdateOptions) (map[string]any, error) {
+	dropMetadata(data)
+	if err := encodeValues(data); err != nil {
+		return nil, err
+	}
+	stampTime(data, UpdatedAtFieldName, coll.now(), opts.PreserveTimestamps)
+	coll.normalizeFields(data)
+	if err := coll.updateSearchKeywords(ctx, id, data); err != nil {
+		return nil, err
+	}
+	if err := coll.validateUpdate(ctx, id, data); err != nil {
+		return nil, err
+	}
+	return coll.encryptFields(data)
 }
 
+// BatchDocs writes the changes batchFn makes to each doc matching
+// condition. Docs are read a page at a time by document ID, after the fields
+// of inequality filters, so pages stay stable while they are written;
+// condition cannot take orderBy, limit, offset or cursor options.
 func (coll *Collection) BatchDocs(condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error) {
-	docs, err := coll.ListDocs(condition)
+	return coll.BatchDocsContext(context.Background(), condition, batchFn)
+}
+
+// BatchDocsContext is BatchDocs with ctx.
+func (coll *Collection) BatchDocsContext(ctx context.Context, condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error) {
+	return run(ctx, coll, call{Op: "BatchDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]*firestore.WriteResult, error) {
+		return coll.batchDocs(ctx, c.Condition, batchFn)
+	})
+}
+
+func (coll *Collection) BatchDocsWithReport(condition []any, batchFn func(map[string]any) map[string]any) (*BulkResult, error) {
+	return coll.BatchDocsWithReportContext(context.Background(), condition, batchFn)
+}
+
+// BatchDocsWithReportContext is BatchDocsWithReport with ctx.
+func (coll *Collection) BatchDocsWithReportContext(ctx context.Context, condition []any, batchFn func(map[string]any) map[string]any) (*BulkResult, error) {
+	return run(ctx, coll, call{Op: "BatchDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.batchDocsReport(ctx, c.Condition, batchFn, false)
+	})
+}
+
+// BatchDocsSet is like BatchDocs but writes each changed doc whole with a
+// Set, so the keys batchFn drops are removed from it. createdAt is kept from
+// the original doc and updatedAt stamped.
+func (coll *Collection) BatchDocsSet(condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error) {
+	report, err := coll.BatchDocsSetWithReport(condition, batchFn)
 	if err != nil {
 		return nil, err
 	}
-	if len(docs) == 0 {
-		return nil, errors.New("no docs to batch")
+	return report.Results(), report.Err()
+}
+
+func (coll *Collection) BatchDocsSetWithReport(condition []any, batchFn func(map[string]any) map[string]any) (*BulkResult, error) {
+	return run(context.Background(), coll, call{Op: "BatchDocsSet", Condition: condition}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.batchDocsReport(ctx, c.Condition, batchFn, true)
+	})
+}
+
+func (coll *Collection) batchDocs(ctx context.Context, condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error) {
+	report, err := coll.batchDocsReport(ctx, condition, batchFn, false)
+	if err != nil {
+		return nil, err
 	}
+	return report.Results(), report.Err()
+}
 
-	errs := make([]error, 0)
-	batchResults := make([]*firestore.WriteResult, 0)
+// batchDocsReport writes the changes of batchFn as field updates, or the
+// whole transformed docs with replace, see BatchDocsSet.
+func (coll *Collection) batchDocsReport(ctx context.Context, condition []any, batchFn func(map[string]any) map[string]any, replace bool) (*BulkResult, error) {
+	op := "BatchDocs"
+	if replace {
+		op = "BatchDocsSet"
+	}
+	if err := coll.checkBulkOptions(op, condition); err != nil {
+		return nil, err
+	}
+	batchFn = coll.normalizingBatchFn(batchFn)
 
-	_500Docs := lo.Chunk(docs, 500)
-	for _, docs := range _500Docs {
-		results, err := batchEach500Docs(coll, docs, batchFn)
+	report := &BulkResult{}
+	found := false
+	err := coll.eachPage(ctx, condition, nil, bulkPageSize, func(snaps []*firestore.DocumentSnapshot) error {
+		docs, err := coll.decryptDocs(docSnapsDataToMap(snaps))
 		if err != nil {
-			errs = append(errs, err)
-			continue
+			return err
 		}
-		batchResults = append(batchResults, results...)
+		found = true
+		chunkCtx, span := coll.startSpan(ctx, "BatchDocs.chunk", "", nil)
+		chunkReport := batchEach500Docs(chunkCtx, coll, docs, batchFn, replace)
+		span.End(chunkReport.Succeeded, chunkReport.Err())
+		report.merge(chunkReport)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return batchResults, errors.Join(errs...)
+	if !found {
+		return nil, errors.New("no docs to batch")
+	}
+	return report, nil
 }
-func makeUpdateData(oldDoc map[string]any, batchFn func(map[string]any) map[string]any) []firestore.Update {
 
+func transformDoc(oldDoc map[string]any, batchFn func(map[string]any) map[string]any) map[string]any {
 	var afterDoc = deepCopyMap(oldDoc).(map[string]any)
 	if batchFn != nil {
 		afterDoc = batchFn(afterDoc)
 	}
-	updateData := make([]firestore.Update, 0)
+	return afterDoc
+}
 
-	for key, oldVal := range oldDoc {
-		if key == IdFieldName {
+// makeUpdateData returns an update of each top level key of afterDoc under
+// which DiffDocs finds a change from oldDoc, a key afterDoc drops being
+// set to nil.
+func makeUpdateData(oldDoc map[string]any, afterDoc map[string]any) []firestore.Update {
+	updateData := make([]firestore.Update, 0)
+	for _, key := range lo.Union(sortedKeys(oldDoc), sortedKeys(afterDoc)) {
+		if key == IdFieldName || key == CreatedAtFieldName || isMetadataKey(key) {
 			continue
 		}
-		newVal := afterDoc[key]
-
-		if newVal != oldVal {
-			//debug.Info("changes", key, oldVal, newVal)
-			updateData = append(
-				updateData,
-				firestore.Update{
-					Path:  key,
-					Value: newVal,
-				},
-			)
+		oldVal, inOld := oldDoc[key]
+		newVal, inAfter := afterDoc[key]
+		if !inAfter && oldVal == nil {
+			continue
+		}
+		before, after := map[string]any{}, map[string]any{}
+		if inOld {
+			before[key] = oldVal
+		}
+		if inAfter {
+			after[key] = newVal
+		}
+		if len(DiffDocs(before, after)) > 0 {
+			updateData = append(updateData, firestore.Update{
+				Path:  key,
+				Value: newVal,
+			})
 		}
 	}
 	return updateData
 }
-func batchEach500Docs(coll *Collection, docs []map[string]any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error) {
-	if len(docs) == 0 {
-		return nil, errors.New("no docs to batch")
+func batchEach500Docs(ctx context.Context, coll *Collection, docs []map[string]any, batchFn func(map[string]any) map[string]any, replace bool) *BulkResult {
+	op := "update"
+	if replace {
+		op = "set"
 	}
+	report := &BulkResult{}
 	docs = lo.Chunk(docs, 500)[0]
-	errs := make([]error, 0)
-	jobs := make([]*firestore.BulkWriterJob, 0)
-	batch := coll.Client.BulkWriter(context.Background())
+	jobs := &bulkJobs{}
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	batch := coll.Client.BulkWriter(ctx)
 
 	for _, doc := range docs {
-		docRef := coll.ref.Doc(doc[IdFieldName].(string))
+		docId, ok := bulkDocID(doc)
+		if !ok {
+			report.add("", op, nil, errors.New(fmt.Sprintf("doc without _id or %s field", IdFieldName)))
+			continue
+		}
+		docRef := coll.ref.Doc(docId)
 
-		updateData := makeUpdateData(doc, batchFn)
+		afterDoc := transformDoc(doc, batchFn)
+		updateData := makeUpdateData(doc, afterDoc)
 		if len(updateData) == 0 {
 			continue
 		}
+		if err := coll.validateDoc(afterDoc); err != nil {
+			if !coll.schemaOptions.SkipInvalidBatchDocs {
+				report.add(docId, op, nil, err)
+			}
+			continue
+		}
 
-		//
-		updateData = append(
-			updateData,
-			firestore.Update{
-				Path:  UpdatedAtFieldName,
-				Value: time.Now(),
-			},
-		)
-
-		job, err := batch.Update(
-			docRef,
-			updateData,
-		)
-		if err != nil {
-			errs = append(errs, err)
+		if field, ok := coll.uniqueUpdateField(updateData); ok {
+			report.add(docId, op, nil, errors.New(fmt.Sprintf("unique field %s cannot be changed by BatchDocs, use UpdateDoc", field)))
 			continue
 		}
-		jobs = append(jobs, job)
-	}
 
-	results := make([]*firestore.WriteResult, 0)
-	for _, job := range jobs {
-		r, err := job.Results()
+		var job *firestore.BulkWriterJob
+		var err error
+		if replace {
+			job, err = coll.setBatchDoc(batch, docRef, doc, afterDoc)
+		} else {
+			job, err = coll.updateBatchDoc(batch, docRef, updateData)
+		}
 		if err != nil {
-			errs = append(errs, err)
+			report.add(docId, op, nil, err)
 			continue
 		}
-		results = append(results, r)
+		jobs.add(docId, job)
 	}
+	batch.End()
 
-	return results, errors.Join(errs...)
+	report.merge(jobs.report(op))
+	return report
+}
+
+func (coll *Collection) updateBatchDoc(batch *firestore.BulkWriter, docRef *firestore.DocumentRef, updateData []firestore.Update) (*firestore.BulkWriterJob, error) {
+	updateData, err := coll.encryptUpdates(updateData)
+	if err != nil {
+		return nil, err
+	}
+	updateData = append(
+		updateData,
+		firestore.Update{
+			Path:  UpdatedAtFieldName,
+			Value: coll.now(),
+		},
+	)
+	return batch.Update(docRef, updateData)
+}
+
+// setBatchDoc replaces the doc with afterDoc, keeping the id and createdAt
+// fields of the original doc.
+func (coll *Collection) setBatchDoc(batch *firestore.BulkWriter, docRef *firestore.DocumentRef, doc map[string]any, afterDoc map[string]any) (*firestore.BulkWriterJob, error) {
+	dropMetadata(afterDoc)
+	for _, key := range []string{IdFieldName, CreatedAtFieldName} {
+		if val, ok := doc[key]; ok {
+			afterDoc[key] = val
+		} else {
+			delete(afterDoc, key)
+		}
+	}
+	afterDoc[UpdatedAtFieldName] = coll.now()
+	stored, err := coll.encryptFields(afterDoc)
+	if err != nil {
+		return nil, err
+	}
+	return batch.Set(docRef, stored)
 }
 
 func (coll *Collection) DeleteDoc(id string, isSoftDelete ...bool) (*firestore.WriteResult, error) {
-	if len(isSoftDelete) > 0 && isSoftDelete[0] {
-		return coll.UpdateDoc(id, map[string]any{
-			DeletedAtFieldName: time.Now(),
+	return coll.DeleteDocContext(context.Background(), id, isSoftDelete...)
+}
+
+// DeleteDocContext is DeleteDoc with ctx.
+func (coll *Collection) DeleteDocContext(ctx context.Context, id string, isSoftDelete ...bool) (*firestore.WriteResult, error) {
+	return coll.DeleteDocWithOptionsContext(ctx, id, DeleteOptions{SoftDelete: len(isSoftDelete) > 0 && isSoftDelete[0]})
+}
+
+// DeleteOptions changes how DeleteDocWithOptions deletes a doc.
+type DeleteOptions struct {
+	SoftDelete bool
+	// IgnoreMissing makes deleting a missing doc succeed with a nil result
+	// instead of returning ErrDocNotFound.
+	IgnoreMissing bool
+	// Recursive also deletes every doc of the subcollections of the doc, at
+	// any depth, before the doc itself. It cannot be combined with
+	// SoftDelete, see WithSoftDeleteCascade.
+	Recursive bool
+	// Progress is called with the number of docs deleted so far, every
+	// few hundred deletes of a recursive or bulk delete.
+	Progress func(deleted int)
+}
+
+func (coll *Collection) DeleteDocWithOptions(id string, opts DeleteOptions) (*firestore.WriteResult, error) {
+	return coll.DeleteDocWithOptionsContext(context.Background(), id, opts)
+}
+
+// DeleteDocWithOptionsContext is DeleteDocWithOptions with ctx.
+func (coll *Collection) DeleteDocWithOptionsContext(ctx context.Context, id string, opts DeleteOptions) (*firestore.WriteResult, error) {
+	return run(ctx, coll, call{Op: "DeleteDoc", DocID: id}, func(ctx context.Context, c *OperationCall) (*firestore.WriteResult, error) {
+		if opts.Recursive {
+			if opts.SoftDelete {
+				return nil, errRecursiveSoftDelete
+			}
+			if err := coll.deleteTree(ctx, coll.ref.Doc(c.DocID), opts.Progress); err != nil {
+				return nil, err
+			}
+		}
+		result, err := coll.deleteDoc(ctx, c.DocID, opts.SoftDelete)
+		if opts.IgnoreMissing && errors.Is(err, ErrDocNotFound) {
+			return nil, nil
+		}
+		return result, err
+	})
+}
+
+// deleteDoc returns ErrDocNotFound when doc id is missing, a soft delete
+// never creates it.
+func (coll *Collection) deleteDoc(ctx context.Context, id string, softDelete bool) (*firestore.WriteResult, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	if softDelete {
+		now := coll.now()
+		result, err := coll.ref.Doc(id).Update(ctx, []firestore.Update{
+			{Path: DeletedAtFieldName, Value: now},
+			{Path: UpdatedAtFieldName, Value: now},
 		})
+		if status.Code(err) == codes.NotFound {
+			return nil, docNotFound(id)
+		}
+		if err != nil || !coll.softDeleteCascade {
+			return result, err
+		}
+		return result, coll.cascadeSoftDeleteDoc(ctx, coll.ref.Doc(id), nil, now)
+	}
+	if len(coll.uniqueFields) == 0 {
+		result, err := coll.ref.Doc(id).Delete(ctx, firestore.Exists)
+		if status.Code(err) == codes.NotFound {
+			return nil, docNotFound(id)
+		}
+		return result, err
+	}
+	snap, err := coll.ref.Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, docNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, err := coll.ref.Doc(id).Delete(ctx, firestore.Exists)
+	if status.Code(err) == codes.NotFound {
+		return nil, docNotFound(id)
+	}
+	if err != nil {
+		return result, err
 	}
-	return coll.ref.Doc(id).Delete(context.Background())
+	return result, coll.releaseUnique(ctx, id, snap.Data())
 }
 
 func (coll *Collection) DeleteDocs(condition []any, isSoftDelete ...bool) ([]*firestore.WriteResult, error) {
+	return coll.DeleteDocsContext(context.Background(), condition, isSoftDelete...)
+}
+
+// DeleteDocsContext is DeleteDocs with ctx.
+func (coll *Collection) DeleteDocsContext(ctx context.Context, condition []any, isSoftDelete ...bool) ([]*firestore.WriteResult, error) {
+	return run(ctx, coll, call{Op: "DeleteDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]*firestore.WriteResult, error) {
+		return coll.deleteDocs(ctx, c.Condition, isSoftDelete...)
+	})
+}
+
+func (coll *Collection) DeleteDocsWithReport(condition []any, isSoftDelete ...bool) (*BulkResult, error) {
+	return coll.DeleteDocsWithReportContext(context.Background(), condition, isSoftDelete...)
+}
 
-	docs, err := coll.ListDocs(condition)
+// DeleteDocsWithReportContext is DeleteDocsWithReport with ctx.
+func (coll *Collection) DeleteDocsWithReportContext(ctx context.Context, condition []any, isSoftDelete ...bool) (*BulkResult, error) {
+	return run(ctx, coll, call{Op: "DeleteDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.deleteDocsReport(ctx, c.Condition, DeleteOptions{SoftDelete: len(isSoftDelete) > 0 && isSoftDelete[0]})
+	})
+}
+
+func (coll *Collection) deleteDocs(ctx context.Context, condition []any, isSoftDelete ...bool) ([]*firestore.WriteResult, error) {
+	report, err := coll.deleteDocsReport(ctx, condition, DeleteOptions{SoftDelete: len(isSoftDelete) > 0 && isSoftDelete[0]})
 	if err != nil {
 		return nil, err
 	}
-	if len(docs) == 0 {
+	return report.Results(), report.Err()
+}
+
+// deleteDocsReport reads only the ids of the docs to delete, plus the
+// unique fields whose reservations a hard delete releases.
+func (coll *Collection) deleteDocsReport(ctx context.Context, condition []any, opts DeleteOptions) (*BulkResult, error) {
+	if opts.Recursive && opts.SoftDelete {
+		return nil, errRecursiveSoftDelete
+	}
+	now := coll.now()
+	progress := &deleteProgress{fn: opts.Progress}
+	fields := []string{}
+	if !opts.SoftDelete {
+		fields = append(fields, coll.uniqueFields...)
+	}
+
+	report := &BulkResult{}
+	found := false
+	err := coll.eachPage(ctx, condition, fields, bulkPageSize, func(snaps []*firestore.DocumentSnapshot) error {
+		docs := make([]map[string]any, len(snaps))
+		for i, snap := range snaps {
+			docs[i] = lo.Assign(snap.Data(), map[string]any{IdFieldName: snap.Ref.ID})
+		}
+		docs, err := coll.decryptDocs(docs)
+		if err != nil {
+			return err
+		}
+		found = true
+		chunkCtx, span := coll.startSpan(ctx, "DeleteDocs.chunk", "", nil)
+		chunkReport := deleteEach500Docs(chunkCtx, coll, docs, opts, now, progress)
+		span.End(chunkReport.Succeeded, chunkReport.Err())
+		report.merge(chunkReport)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
 		return nil, errors.New("not found")
 	}
-	var softDelete bool = (len(isSoftDelete) > 0) && isSoftDelete[0]
+	return report, nil
+}
 
-	batch := coll.Client.BulkWriter(context.Background())
+func deleteEach500Docs(ctx context.Context, coll *Collection, docs []map[string]any, opts DeleteOptions, now time.Time, progress *deleteProgress) *BulkResult {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	batch := coll.Client.BulkWriter(ctx)
+	softDelete := opts.SoftDelete
 
-	jobs := make([]*firestore.BulkWriterJob, 0)
-	errs := make([]error, 0)
+	op := "delete"
+	if softDelete {
+		op = "softDelete"
+	}
+	report := &BulkResult{}
+	jobs := &bulkJobs{}
+	jobDocs := make(map[string]map[string]any)
+	cascadeJobs := &bulkJobs{}
+	treeJobs := &bulkJobs{}
 	for _, doc := range docs {
-		docId := doc[IdFieldName].(string)
+		docId, ok := bulkDocID(doc)
+		if !ok {
+			report.add("", op, nil, errors.New(fmt.Sprintf("doc without _id or %s field", IdFieldName)))
+			continue
+		}
 		var job *firestore.BulkWriterJob
 		var err error
 		if !softDelete {
+			if opts.Recursive {
+				if treeErr := coll.deleteSubtree(ctx, batch, treeJobs, coll.ref.Doc(docId), progress); treeErr != nil {
+					report.add(docId, "deleteTree", nil, treeErr)
+				}
+			}
 			job, err = batch.Delete(coll.ref.Doc(docId))
 		} else {
 			job, err = batch.Update(coll.ref.Doc(docId), []firestore.Update{
 				{
 					Path:  DeletedAtFieldName,
-					Value: time.Now(),
+					Value: now,
 				},
 				{
 					Path:  UpdatedAtFieldName,
-					Value: time.Now(),
+					Value: now,
 				}})
+			if err == nil && coll.softDeleteCascade {
+				if cascadeErr := coll.cascadeDeletedAt(ctx, batch, cascadeJobs, coll.ref.Doc(docId), nil, now, now); cascadeErr != nil {
+					report.add(docId, "cascade", nil, cascadeErr)
+				}
+			}
 		}
 		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		jobs = append(jobs, job)
-	}
-
-	results := make([]*firestore.WriteResult, 0)
-	for _, job := range jobs {
-		result, err := job.Results()
-		if err != nil {
-			errs = append(errs, err)
+			report.add(docId, op, nil, err)
 			continue
 		}
-		results = append(results, result)
+		jobs.add(docId, job)
+		jobDocs[docId] = doc
+		progress.queued(batch)
 	}
-	return results, errors.Join(errs...)
+	batch.End()
+	progress.flushed()
 
-}
-
-func (coll *Collection) MakeQuery(condition []any) firestore.Query {
-	query := coll.ref.Query
-
-	if DebugEnabled {
-		debug(coll.ref.Path)
-	}
-
-	for idx, where := range condition {
-		switch v := reflect.ValueOf(where); v.Kind() {
-		case reflect.Slice:
-			// v = []any{"path", "op", "val"}
-			vSlide := v.Interface().([]any)
-			path := vSlide[0].(string)
-			op := vSlide[1].(string)
-			val := vSlide[2]
-			if DebugEnabled {
-				debug(path, op, val)
-			}
-
-			query = query.Where(
-				path,
-				op,
-				val,
-			)
-		case reflect.Map:
-			vMap := v.Interface().(map[string]any)
-			if DebugEnabled {
-				debug(vMap)
+	for _, entry := range jobs.report(op).Entries {
+		if entry.Err == nil && !softDelete && len(coll.uniqueFields) > 0 {
+			if err := coll.releaseUnique(ctx, entry.ID, jobDocs[entry.ID]); err != nil {
+				report.add(entry.ID, "releaseUnique", nil, err)
 			}
-			if idx != len(condition)-1 {
-				for key, val := range vMap {
-					query = query.Where(key, "==", val)
-				}
-			} else {
-				//	iter functions
-				for key, val := range vMap {
-					switch strings.ToLower(key) {
-					case "orderby":
-						// orderby = string | []string
-						switch reflect.TypeOf(val).Kind() {
-						case reflect.String:
-							orderBy := parseOrderBy(val.(string))
-							if orderBy != nil && len(orderBy.Field) > 0 {
-								query = query.OrderBy(orderBy.Field, orderBy.Direction)
-							}
-						case reflect.Slice:
-							obSlide := val.([]string)
-							for _, ob := range obSlide {
-								orderBy := parseOrderBy(ob)
-								if orderBy != nil && len(orderBy.Field) > 0 {
-									query = query.OrderBy(orderBy.Field, orderBy.Direction)
-								}
-							}
-						default:
-						}
-					case "limit":
-						query = query.Limit(val.(int))
-					case "offset":
-						query = query.Offset(val.(int))
-					case "startat":
-						query = query.StartAt(val)
-					case "startafter":
-						query = query.StartAfter(val)
-					case "endat":
-						query = query.EndAt(val)
-					case "endbefore":
-						query = query.EndBefore(val)
-					}
-				}
-			}
-		default:
-			panic("unhandled default case")
 		}
+		report.add(entry.ID, entry.Op, entry.Result, entry.Err)
 	}
-	if DebugEnabled {
-		debug("--------------------")
+	for _, entry := range append(cascadeJobs.report("cascade").Entries, treeJobs.report("deleteTree").Entries...) {
+		if entry.Err != nil {
+			report.add(entry.ID, entry.Op, nil, entry.Err)
+		}
 	}
-	return query
+	return report
 }
 
+// CountDocs counts the docs matching condition with an aggregation query,
+// which can't take FieldMissing, see GroupByCount.
 func (coll *Collection) CountDocs(condition []any) (int, error) {
+	return coll.CountDocsContext(context.Background(), condition)
+}
+
+// CountDocsContext is CountDocs with ctx.
+func (coll *Collection) CountDocsContext(ctx context.Context, condition []any) (int, error) {
+	return run(ctx, coll, call{Op: "CountDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (int, error) {
+		return cachedQuery(coll, "CountDocs", c.Condition, func() (int, error) {
+			return coll.countDocs(ctx, c.Condition)
+		})
+	})
+}
+
+func (coll *Collection) countDocs(ctx context.Context, condition []any) (int, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
 
 	//remove last condition if it is a map
-	lastCond, err := lo.Last(condition)
-	if err != nil {
-		return 0, err
-	}
-	switch reflect.TypeOf(lastCond).Kind() {
-	case reflect.Map:
+	if lastCond, err := lo.Last(condition); err == nil && reflect.TypeOf(lastCond).Kind() == reflect.Map {
 		condition = condition[:len(condition)-1]
-	default:
 	}
-	query := coll.MakeQuery(condition)
-
-	aggregationQuery := query.NewAggregationQuery().WithCount("all")
-	results, err := aggregationQuery.Get(context.Background())
+	query, err := coll.makeQuery(condition)
 	if err != nil {
 		return 0, err
 	}
 
-	count, ok := results["all"]
-	if !ok {
-		return 0, errors.New("firestore: couldn't get alias for COUNT from results")
-	}
-
-	countValue := count.(*firestorepb.Value)
-	return int(countValue.GetIntegerValue()), nil
+	return countQuery(ctx, query)
 }
 
-var DefaultPaginatePerPage = 25
-
-type PaginateQueryParams struct {
-	Page    int    `query:"page" form:"page" json:"page"`
-	PerPage int    `query:"perPage" form:"perPage" json:"perPage"`
-	Sort    string `query:"sort" form:"sort" json:"sort"`
+// CountDocsUpTo counts the docs matching condition but stops at max, the
+// bool reports whether there are more, e.g. to show "500+".
+func (coll *Collection) CountDocsUpTo(ctx context.Context, condition []any, max int) (int, bool, error) {
+	var capped bool
+	count, err := run(ctx, coll, call{Op: "CountDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (int, error) {
+		var count int
+		var err error
+		count, capped, err = coll.countDocsUpTo(ctx, c.Condition, max)
+		return count, err
+	})
+	return count, capped, err
 }
 
-func (coll *Collection) Paginate(condition []any, page int, perPage int) (map[string]any, error) {
-	if page == 0 {
-		page = 1
-	}
-	if perPage == 0 {
-		perPage = DefaultPaginatePerPage
+func (coll *Collection) countDocsUpTo(ctx context.Context, condition []any, max int) (int, bool, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+
+	if lastCond, err := lo.Last(condition); err == nil && reflect.TypeOf(lastCond).Kind() == reflect.Map {
+		condition = condition[:len(condition)-1]
 	}
-	lastCond := condition[len(condition)-1]
-	switch reflect.TypeOf(lastCond).Kind() {
-	case reflect.Slice:
-		condition = append(condition, map[string]any{
-			"limit":  perPage,
-			"offset": (page - 1) * perPage,
-		})
-	case reflect.Map:
-		lastCondMap := lastCond.(map[string]any)
-		condition[len(condition)-1] = lo.Assign(
-			lastCondMap,
-			map[string]any{
-				"limit":  perPage,
-				"offset": (page - 1) * perPage,
-			},
-		)
-	default:
+	query, err := coll.makeQuery(condition)
+	if err != nil {
+		return 0, false, err
 	}
-
-	docs, err := coll.ListDocs(condition)
+	count, err := countQuery(ctx, query.Limit(max+1))
 	if err != nil {
-		return nil, err
+		return 0, false, err
 	}
-
-	result := map[string]any{
-		"docs":    docs,
-		"page":    page,
-		"perPage": perPage,
+	if count > max {
+		return max, true, nil
 	}
-
-	return result, nil
+	return count, false, nil
 }
 
-func (coll *Collection) PaginateWithCount(condition []any, page int, perPage int) (map[string]any, error) {
-	if perPage == 0 {
-		perPage = DefaultPaginatePerPage
-	}
-	if page == 0 {
-		page = 1
+func countQuery(ctx context.Context, query firestore.Query) (int, error) {
+	aggregationQuery := query.NewAggregationQuery().WithCount("all")
+	if tx := readTx(ctx); tx != nil {
+		aggregationQuery = aggregationQuery.Transaction(tx)
 	}
-	val, err := coll.Paginate(condition, page, perPage)
+	results, err := aggregationQuery.Get(ctx)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	count, err := coll.CountDocs(condition)
-	if err != nil {
-		return nil, err
-	}
-	totalPage := 0
-	if count%perPage == 0 {
-		totalPage = count / perPage
-	} else {
-		totalPage = count/perPage + 1
+	count, ok := results["all"]
+	if !ok {
+		return 0, errors.New("firestore: couldn't get alias for COUNT from results")
 	}
-	return lo.Assign(val, map[string]any{
-		"count":     count,
-		"totalPage": totalPage,
-	}), nil
+
+	countValue := count.(*firestorepb.Value)
+	return int(countValue.GetIntegerValue()), nil
 }
 
 func (coll *Collection) CheckExists(condition []any) (bool, error) {
-	docs, err := coll.ListDocs(condition)
-	if err != nil {
+	return coll.CheckExistsContext(context.Background(), condition)
+}
+
+// CheckExistsContext is CheckExists with ctx.
+func (coll *Collection) CheckExistsContext(ctx context.Context, condition []any) (bool, error) {
+	return run(ctx, coll, call{Op: "CheckExists", Condition: condition}, func(ctx context.Context, c *OperationCall) (bool, error) {
+		return coll.checkExists(ctx, c.Condition)
+	})
+}
+
+func (coll *Collection) checkExists(ctx context.Context, condition []any) (bool, error) {
+	docs, err := coll.listDocs(ctx, condition)
+	if err != nil && !errors.Is(err, ErrResultTruncated) {
 		return false, err
 	}
 	return len(docs) > 0, nil
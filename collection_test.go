@@ -0,0 +1,160 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"github.com/samber/lo"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchDocsRejectsPagingOptions(t *testing.T) {
+	coll := testCollection(t)
+	m := NewMemoryCollection("tests")
+	batchFn := func(doc map[string]any) map[string]any { return doc }
+	for _, name := range []string{"orderBy", "limit", "offset", "limitToLast", "startAfter"} {
+		condition := []any{[]any{"status", "==", "open"}, map[string]any{name: 1}}
+		want := "does not take the " + name + " option"
+		if _, err := coll.BatchDocs(condition, batchFn); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("BatchDocs with %s: error = %v", name, err)
+		}
+		if _, err := coll.BatchDocsSetWithReport(condition, batchFn); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("BatchDocsSet with %s: error = %v", name, err)
+		}
+		if _, err := m.BatchDocs(condition, batchFn); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("MemoryCollection.BatchDocs with %s: error = %v", name, err)
+		}
+	}
+}
+
+func TestBatchDocsPages(t *testing.T) {
+	coll := emulatorCollection(t)
+	tracer := &recordingTracer{}
+	coll.WithTracer(tracer)
+	seedDocs(t, coll, 2500)
+
+	// moving every doc after the others must neither skip nor repeat any
+	results, err := coll.BatchDocs([]any{[]any{"n", ">=", 0}}, func(doc map[string]any) map[string]any {
+		doc["n"] = doc["n"].(int64) + 10000
+		doc["visits"] = 1
+		return doc
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2500 {
+		t.Errorf("BatchDocs wrote %d docs, want 2500", len(results))
+	}
+	if pages := tracer.counts("cffirestore.BatchDocs.chunk"); !reflect.DeepEqual(pages, []int{500, 500, 500, 500, 500}) {
+		t.Errorf("BatchDocs pages = %v, want 5 of 500", pages)
+	}
+	count, err := coll.CountDocs([]any{[]any{"visits", "==", 1}, []any{"n", ">=", 10000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2500 {
+		t.Errorf("%d docs were updated once, want 2500", count)
+	}
+
+	pages := make([]int, 0)
+	err = coll.eachPage(context.Background(), []any{[]any{"group", "==", 0}}, nil, 300, func(snaps []*firestore.DocumentSnapshot) error {
+		pages = append(pages, len(snaps))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{300, 300, 234}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("eachPage pages = %v, want %v", pages, want)
+	}
+	if total := lo.Sum(pages); total != 834 {
+		t.Errorf("eachPage read %d docs, want 834", total)
+	}
+}
+
+func TestMemoryCollectionBatchDocsSet(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(created)
+	m := NewMemoryCollection("tests")
+	m.Config().WithClock(clock)
+	for _, id := range []string{"a", "b"} {
+		id := id
+		if _, _, err := m.AddDocWithId(&id, nil, map[string]any{"name": id, "legacy": true, "old": map[string]any{"x": 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Hour)
+	results, err := m.BatchDocsSet(nil, func(doc map[string]any) map[string]any {
+		if doc["name"] == "b" {
+			return doc
+		}
+		return map[string]any{
+			"_id":              doc["_id"],
+			"_ref":             doc["_ref"],
+			"name":             "A",
+			CreatedAtFieldName: time.Time{},
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("BatchDocsSet wrote %d docs, want only the changed one", len(results))
+	}
+	m.mu.RLock()
+	stored := deepCopyMap(m.docs["a"]).(map[string]any)
+	m.mu.RUnlock()
+	want := map[string]any{
+		"name":             "A",
+		IdFieldName:        "a",
+		CreatedAtFieldName: created,
+		UpdatedAtFieldName: created.Add(time.Hour),
+	}
+	if !reflect.DeepEqual(stored, want) {
+		t.Errorf("stored doc = %v, want %v", stored, want)
+	}
+	doc, err := m.GetDoc("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["legacy"] != true || doc[UpdatedAtFieldName] != created {
+		t.Errorf("unchanged doc = %v, want it untouched", doc)
+	}
+}
+
+func TestBatchDocsSet(t *testing.T) {
+	coll := emulatorCollection(t)
+	id := "a"
+	if _, _, err := coll.AddDocWithId(&id, nil, map[string]any{"name": "a", "legacy": true, "old": map[string]any{"x": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := coll.GetDoc(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coll.BatchDocsSet(nil, func(doc map[string]any) map[string]any {
+		return map[string]any{"_id": doc["_id"], "_ref": doc["_ref"], "name": "A"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := coll.GetDoc(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := lo.Keys(lo.OmitBy(after, func(key string, _ any) bool { return isMetadataKey(key) }))
+	sort.Strings(keys)
+	want := []string{CreatedAtFieldName, IdFieldName, "name", UpdatedAtFieldName}
+	sort.Strings(want)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("stored keys = %v, want %v", keys, want)
+	}
+	if !after[CreatedAtFieldName].(time.Time).Equal(before[CreatedAtFieldName].(time.Time)) {
+		t.Errorf("createdAt = %v, want %v", after[CreatedAtFieldName], before[CreatedAtFieldName])
+	}
+	if !after[UpdatedAtFieldName].(time.Time).After(before[UpdatedAtFieldName].(time.Time)) {
+		t.Errorf("updatedAt %v is not after %v", after[UpdatedAtFieldName], before[UpdatedAtFieldName])
+	}
+}
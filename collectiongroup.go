@@ -0,0 +1,34 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"github.com/samber/lo"
+	"reflect"
+)
+
+type CollectionGroupOptions struct {
+	// ExcludeDeleted leaves out the soft deleted docs.
+	ExcludeDeleted bool
+}
+
+// CountCollectionGroup counts the docs matching condition in every
+// collection named collectionID, e.g. the comments of all posts. Like
+// CountDocs, the options map of condition is ignored.
+func CountCollectionGroup(ctx context.Context, client *firestore.Client, collectionID string, condition []any, opts ...CollectionGroupOptions) (int, error) {
+	coll := &Collection{Path: collectionID, Client: client, middlewares: clientMiddlewares(client)}
+	return run(ctx, coll, call{Op: "CountCollectionGroup", Condition: condition}, func(ctx context.Context, c *OperationCall) (int, error) {
+		condition := c.Condition
+		if lastCond, err := lo.Last(condition); err == nil && reflect.TypeOf(lastCond).Kind() == reflect.Map {
+			condition = condition[:len(condition)-1]
+		}
+		if len(opts) > 0 && opts[0].ExcludeDeleted {
+			condition = append([]any{IsNull(DeletedAtFieldName)}, condition...)
+		}
+		query, err := coll.buildQuery(client.CollectionGroup(collectionID).Query, condition)
+		if err != nil {
+			return 0, err
+		}
+		return countQuery(ctx, query)
+	})
+}
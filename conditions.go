@@ -0,0 +1,252 @@
+package cffirestore
+
+import (
+	"errors"
+	"fmt"
+)
+
+type betweenCondition struct {
+	field     string
+	from      any
+	to        any
+	inclusive bool
+}
+
+// Between matches field >= from and field < to. A nil bound leaves that
+// side of the range open. An orderBy on field is added when missing.
+func Between(field string, from, to any) Condition {
+	return betweenCondition{field: field, from: from, to: to}
+}
+
+// BetweenInclusive is like Between but also matches field == to.
+func BetweenInclusive(field string, from, to any) Condition {
+	return betweenCondition{field: field, from: from, to: to, inclusive: true}
+}
+
+func (c betweenCondition) canonical() []any {
+	return []any{c.field, c.from, c.to, c.inclusive}
+}
+
+func (c betweenCondition) expand(coll *Collection, plan *queryPlan) error {
+	if c.from == nil && c.to == nil {
+		return errors.New(fmt.Sprintf("between %s: at least one bound is required", c.field))
+	}
+	from, err := coll.coerceConditionValue(c.field, ">=", c.from)
+	if err != nil {
+		return err
+	}
+	to, err := coll.coerceConditionValue(c.field, "<", c.to)
+	if err != nil {
+		return err
+	}
+	if from != nil && to != nil {
+		order, ok := compareValues(from, to)
+		if !ok {
+			return errors.New(fmt.Sprintf("between %s: bounds %T and %T are not comparable", c.field, from, to))
+		}
+		if order > 0 {
+			return errors.New(fmt.Sprintf("between %s: from %v is after to %v", c.field, from, to))
+		}
+	}
+	if from != nil {
+		plan.where(c.field, ">=", from)
+	}
+	if to != nil {
+		if c.inclusive {
+			plan.where(c.field, "<=", to)
+		} else {
+			plan.where(c.field, "<", to)
+		}
+	}
+	plan.requireOrderBy(c.field)
+	return nil
+}
+
+type startsWithCondition struct {
+	field  string
+	prefix string
+}
+
+// StartsWith matches string fields beginning with prefix. An orderBy on
+// field is added when missing.
+func StartsWith(field string, prefix string) Condition {
+	return startsWithCondition{field, prefix}
+}
+
+func (c startsWithCondition) canonical() []any {
+	return []any{c.field, c.prefix}
+}
+
+func (c startsWithCondition) expand(coll *Collection, plan *queryPlan) error {
+	if c.prefix == "" {
+		return errors.New(fmt.Sprintf("starts with %s: prefix is empty", c.field))
+	}
+	plan.where(c.field, ">=", c.prefix)
+	plan.where(c.field, "<", c.prefix+"\uf8ff")
+	plan.requireOrderBy(c.field)
+	return nil
+}
+
+type fieldExistsCondition struct {
+	field string
+}
+
+// FieldExists matches docs having field, null or not, by ordering on it:
+// Firestore leaves out docs missing an orderBy field. An inequality on
+// another field can't be combined with it.
+func FieldExists(field string) Condition {
+	return fieldExistsCondition{field}
+}
+
+func (c fieldExistsCondition) canonical() []any {
+	return []any{c.field}
+}
+
+func (c fieldExistsCondition) expand(coll *Collection, plan *queryPlan) error {
+	plan.requireOrderBy(c.field)
+	plan.exists = append(plan.exists, c.field)
+	return nil
+}
+
+type fieldMissingCondition struct {
+	field string
+}
+
+// FieldMissing matches docs without field. Firestore can't filter on
+// absent fields, a != sentinel clause or an orderBy only ever keeps the docs
+// having them, so the docs read are filtered instead, by ListDocs, FindDoc,
+// ForEachDoc, Paginate, PaginateWithToken and the bulk operations. Their
+// limit, offset and pages apply before that filter and can come short, and
+// counts, aggregations and listeners reject it.
+func FieldMissing(field string) Condition {
+	return fieldMissingCondition{field}
+}
+
+func (c fieldMissingCondition) canonical() []any {
+	return []any{c.field}
+}
+
+func (c fieldMissingCondition) expand(coll *Collection, plan *queryPlan) error {
+	plan.missing = append(plan.missing, c.field)
+	return nil
+}
+
+type isNullCondition struct {
+	field string
+}
+
+// IsNull matches docs whose field is null, not those missing it. With
+// soft deletes, []any{IsNull(DeletedAtFieldName)} lists the live docs and
+// []any{IsNotNull(DeletedAtFieldName)} the deleted ones.
+func IsNull(field string) Condition {
+	return isNullCondition{field}
+}
+
+func (c isNullCondition) canonical() []any {
+	return []any{c.field}
+}
+
+func (c isNullCondition) expand(coll *Collection, plan *queryPlan) error {
+	plan.where(c.field, "==", nil)
+	return nil
+}
+
+// IsNotNull is NotNull.
+func IsNotNull(field string) Condition {
+	return NotNull(field)
+}
+
+type notNullCondition struct {
+	field string
+}
+
+// NotNull matches docs having field set to a non null value. It orders on
+// field and starts the query right after its nulls, so field must be the
+// only orderBy and the query can't have its own start or end cursor.
+func NotNull(field string) Condition {
+	return notNullCondition{field}
+}
+
+func (c notNullCondition) canonical() []any {
+	return []any{c.field}
+}
+
+func (c notNullCondition) expand(coll *Collection, plan *queryPlan) error {
+	plan.requireOrderBy(c.field)
+	plan.notNull = append(plan.notNull, c.field)
+	return nil
+}
+
+type compositeCondition struct {
+	or      bool
+	clauses []any
+}
+
+// Or matches docs matching any of clauses. A clause is a where clause like
+// []any{"status", "==", "open"}, an equality map or a Condition, And to
+// require several at once. Unlike Between at the top level, ranges in Or
+// add no orderBy, and NotNull cannot be nested.
+func Or(clauses ...any) Condition {
+	return compositeCondition{or: true, clauses: clauses}
+}
+
+// And matches docs matching every clause, to group clauses in Or.
+func And(clauses ...any) Condition {
+	return compositeCondition{clauses: clauses}
+}
+
+func (c compositeCondition) name() string {
+	if c.or {
+		return "or"
+	}
+	return "and"
+}
+
+func (c compositeCondition) canonical() []any {
+	return []any{c.or, c.clauses}
+}
+
+func (c compositeCondition) expand(coll *Collection, plan *queryPlan) error {
+	node, err := c.node(coll)
+	if err != nil {
+		return err
+	}
+	plan.filters = append(plan.filters, node)
+	return nil
+}
+
+func (c compositeCondition) node(coll *Collection) (filterNode, error) {
+	node := filterNode{or: c.or}
+	if len(c.clauses) == 0 {
+		return node, errors.New(fmt.Sprintf("%s: at least one clause is required", c.name()))
+	}
+	for _, clause := range c.clauses {
+		if nested, ok := clause.(compositeCondition); ok {
+			child, err := nested.node(coll)
+			if err != nil {
+				return node, err
+			}
+			node.children = append(node.children, child)
+			continue
+		}
+		sub := &queryPlan{}
+		if err := coll.planElement(sub, clause, false); err != nil {
+			return node, err
+		}
+		if len(sub.notNull) > 0 {
+			return node, errors.New(fmt.Sprintf("%s: not null %s cannot be nested", c.name(), sub.notNull[0]))
+		}
+		if len(sub.exists) > 0 {
+			return node, errors.New(fmt.Sprintf("%s: field exists %s cannot be nested", c.name(), sub.exists[0]))
+		}
+		if len(sub.missing) > 0 {
+			return node, errors.New(fmt.Sprintf("%s: field missing %s cannot be nested", c.name(), sub.missing[0]))
+		}
+		if len(sub.wheres) > 1 && c.or {
+			node.children = append(node.children, filterNode{wheres: sub.wheres})
+			continue
+		}
+		node.wheres = append(node.wheres, sub.wheres...)
+	}
+	return node, nil
+}
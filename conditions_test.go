@@ -0,0 +1,181 @@
+package cffirestore
+
+import (
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"fmt"
+	"github.com/samber/lo"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMakeQueryNullComparisons(t *testing.T) {
+	coll := testCollection(t)
+	for _, condition := range [][]any{
+		{[]any{"deletedAt", "==", nil}},
+		{map[string]any{"deletedAt": nil}, map[string]any{}},
+		{IsNull("deletedAt")},
+	} {
+		query, err := coll.MakeQueryE(condition)
+		if err != nil {
+			t.Fatalf("%v: %v", condition, err)
+		}
+		sq := structuredQuery(t, query)
+		filter := sq.GetWhere().GetUnaryFilter()
+		if filter.GetOp() != pb.StructuredQuery_UnaryFilter_IS_NULL || filter.GetField().GetFieldPath() != "deletedAt" {
+			t.Errorf("%v: where = %v, want deletedAt is null", condition, sq.GetWhere())
+		}
+		if len(sq.GetOrderBy()) != 0 || sq.GetStartAt() != nil {
+			t.Errorf("%v: orderBy %v, startAt %v, want none", condition, sq.GetOrderBy(), sq.GetStartAt())
+		}
+	}
+
+	for _, condition := range [][]any{
+		{[]any{"deletedAt", "!=", nil}},
+		{IsNotNull("deletedAt")},
+	} {
+		query, err := coll.MakeQueryE(condition)
+		if err != nil {
+			t.Fatalf("%v: %v", condition, err)
+		}
+		sq := structuredQuery(t, query)
+		if sq.GetWhere() != nil {
+			t.Errorf("%v: where = %v, want none", condition, sq.GetWhere())
+		}
+		if got, want := orderByKeys(sq), []string{"deletedAt:asc"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: orderBy = %v, want %v", condition, got, want)
+		}
+		start := sq.GetStartAt()
+		if start.GetBefore() || len(start.GetValues()) != 1 || start.GetValues()[0].GetNullValue() != 0 || start.GetValues()[0].GetValueType() == nil {
+			t.Errorf("%v: startAt = %v, want after null", condition, start)
+		}
+	}
+
+	// a null equality combines with ordering on another field
+	query, err := coll.MakeQueryE([]any{IsNull("deletedAt"), map[string]any{"orderBy": "createdAt:desc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := orderByKeys(structuredQuery(t, query)), []string{"createdAt:desc", "__name__:desc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("orderBy = %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		condition []any
+		err       string
+	}{
+		{[]any{[]any{"deletedAt", ">", nil}}, "field deletedAt: nil can only be compared with == or !=, not >"},
+		{[]any{IsNotNull("deletedAt"), map[string]any{"orderBy": "createdAt:desc"}}, "cannot be combined with an orderBy on createdAt"},
+		{[]any{IsNotNull("deletedAt"), FieldExists("name")}, "cannot be combined with an orderBy on name"},
+		{[]any{FieldExists("name"), IsNotNull("deletedAt")}, "deletedAt must be the first orderBy"},
+		{[]any{IsNotNull("deletedAt"), IsNotNull("archivedAt")}, "only one field per query can be not null"},
+	} {
+		if _, err := coll.MakeQueryE(tc.condition); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: error = %v, want %q", tc.condition, err, tc.err)
+		}
+	}
+}
+
+func TestMemoryCollectionSoftDeleteFilters(t *testing.T) {
+	m := NewMemoryCollection("tests")
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprint(i)
+		if _, _, err := m.AddDocWithId(&id, nil, map[string]any{"n": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"1", "3"} {
+		if _, err := m.DeleteDoc(id, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		condition []any
+		want      []string
+	}{
+		{[]any{IsNull(DeletedAtFieldName)}, []string{"0", "2"}},
+		{[]any{[]any{DeletedAtFieldName, "==", nil}}, []string{"0", "2"}},
+		{[]any{map[string]any{DeletedAtFieldName: nil}, map[string]any{}}, []string{"0", "2"}},
+		{[]any{IsNotNull(DeletedAtFieldName)}, []string{"1", "3"}},
+		{[]any{[]any{DeletedAtFieldName, "!=", nil}}, []string{"1", "3"}},
+		{[]any{IsNull(DeletedAtFieldName), map[string]any{"orderBy": "n:desc"}}, []string{"2", "0"}},
+	} {
+		docs, err := m.ListDocs(tc.condition)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.condition, err)
+		}
+		got := lo.Map(docs, func(doc map[string]any, _ int) string { return doc["_id"].(string) })
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: docs = %v, want %v", tc.condition, got, tc.want)
+		}
+	}
+}
+
+func TestFieldExistsAndNotNullInequalities(t *testing.T) {
+	coll := testCollection(t)
+	for _, condition := range [][]any{
+		{FieldExists("email"), []any{"age", ">", 18}},
+		{[]any{"age", "!=", 18}, FieldExists("email")},
+		{FieldExists("email"), Between("age", 18, 65)},
+		{FieldExists("email"), Or([]any{"age", "<", 18}, []any{"status", "==", "open"})},
+		{NotNull("email"), []any{"age", ">", 18}},
+		{NotNull("email"), []any{"age", "not-in", []any{1, 2}}},
+	} {
+		_, err := coll.MakeQueryE(condition)
+		if err == nil || !strings.Contains(err.Error(), "cannot be combined with the inequality on age") {
+			t.Errorf("%v: error = %v", condition, err)
+		}
+	}
+	for _, condition := range [][]any{
+		{FieldExists("age"), []any{"age", ">", 18}},
+		{FieldExists("email"), []any{"age", "==", 18}},
+		{NotNull("age"), []any{"age", ">", 18}},
+	} {
+		if _, err := coll.MakeQueryE(condition); err != nil {
+			t.Errorf("%v: %v", condition, err)
+		}
+	}
+	if _, err := coll.MakeQueryE([]any{Or(FieldExists("email"), []any{"age", "==", 1})}); err == nil {
+		t.Error("nested FieldExists: no error")
+	}
+}
+
+func TestFieldMissing(t *testing.T) {
+	coll := testCollection(t)
+	_, err := coll.MakeQueryE([]any{FieldMissing("email")})
+	if err == nil || !strings.Contains(err.Error(), "field missing email") {
+		t.Errorf("MakeQueryE error = %v", err)
+	}
+	if _, err := coll.MakeQueryE([]any{Or(FieldMissing("email"), []any{"age", "==", 1})}); err == nil {
+		t.Error("nested FieldMissing: no error")
+	}
+
+	m := NewMemoryCollection("tests")
+	for id, doc := range map[string]map[string]any{
+		"a": {"email": "a@example.com"},
+		"b": {"email": nil},
+		"c": {"profile": map[string]any{"email": "c@example.com"}},
+		"d": {},
+	} {
+		id := id
+		if _, _, err := m.AddDocWithId(&id, nil, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for field, want := range map[string][]string{
+		"email":         {"c", "d"},
+		"profile.email": {"a", "b", "d"},
+	} {
+		docs, err := m.ListDocs([]any{FieldMissing(field)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := lo.Map(docs, func(doc map[string]any, _ int) string { return doc["_id"].(string) })
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FieldMissing(%s) = %v, want %v", field, got, want)
+		}
+	}
+	if _, err := m.CountDocs([]any{FieldMissing("email")}); err == nil {
+		t.Error("CountDocs with FieldMissing: no error")
+	}
+}
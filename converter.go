@@ -0,0 +1,257 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type converter struct {
+	toFirestore   func(any) (any, error)
+	fromFirestore func(any) (any, error)
+}
+
+var (
+	convertersMu sync.RWMutex
+	converters   = make(map[reflect.Type]converter)
+)
+
+// RegisterConverter makes writes store values of type t as toFirestore
+// returns them, inside nested maps, slices and structs too. fromFirestore
+// turns a stored value back into a t when reading with ListDocsAs or
+// GetDocAs; map reads return the stored form since they carry no Go type.
+func RegisterConverter(t reflect.Type, toFirestore func(any) (any, error), fromFirestore func(any) (any, error)) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[t] = converter{toFirestore, fromFirestore}
+}
+
+func lookupConverter(t reflect.Type) (converter, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	c, ok := converters[t]
+	return c, ok
+}
+
+func hasConverters() bool {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	return len(converters) > 0
+}
+
+// encodeValues replaces the values of v having a registered converter.
+func encodeValues(v map[string]any) error {
+	if !hasConverters() {
+		return nil
+	}
+	for key, val := range v {
+		encoded, err := encodeValue(val, key)
+		if err != nil {
+			return err
+		}
+		v[key] = encoded
+	}
+	return nil
+}
+
+func encodeValue(val any, path string) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	if c, ok := lookupConverter(reflect.TypeOf(val)); ok && c.toFirestore != nil {
+		encoded, err := c.toFirestore(val)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("field %s: %v", path, err))
+		}
+		return encoded, nil
+	}
+	switch v := val.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			encoded, err := encodeValue(item, path+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = encoded
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			encoded, err := encodeValue(item, fmt.Sprintf("%s.%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = encoded
+		}
+		return out, nil
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if _, ok := lookupConverter(rv.Type().Elem()); ok {
+			return toDocValue(rv, path)
+		}
+	}
+	return val, nil
+}
+
+// ListDocsAs lists the docs matching condition decoded into T, a struct
+// named by firestore or json tags like AddStruct writes, or a map.
+func ListDocsAs[T any](coll *Collection, condition []any) ([]T, error) {
+	return run(context.Background(), coll, call{Op: "ListDocsAs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]T, error) {
+		docs, listErr := coll.listDocs(ctx, c.Condition)
+		if listErr != nil && !errors.Is(listErr, ErrResultTruncated) {
+			return nil, listErr
+		}
+		out := make([]T, len(docs))
+		for i, doc := range docs {
+			if err := decodeDoc(doc, &out[i]); err != nil {
+				id, _ := doc["_id"].(string)
+				return nil, docErr(id, err)
+			}
+		}
+		return out, listErr
+	})
+}
+
+// GetDocAs gets doc id decoded into T, see ListDocsAs.
+func GetDocAs[T any](coll *Collection, id string) (*T, error) {
+	return run(context.Background(), coll, call{Op: "GetDocAs", DocID: id}, func(ctx context.Context, c *OperationCall) (*T, error) {
+		doc, err := coll.getDoc(ctx, c.DocID)
+		if err != nil {
+			return nil, err
+		}
+		out := new(T)
+		if err := decodeDoc(doc, out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	})
+}
+
+func decodeDoc(doc map[string]any, out any) error {
+	return decodeValue(doc, reflect.ValueOf(out).Elem(), "")
+}
+
+func decodeValue(val any, dst reflect.Value, path string) error {
+	if c, ok := lookupConverter(dst.Type()); ok && c.fromFirestore != nil && val != nil {
+		decoded, err := c.fromFirestore(val)
+		if err != nil {
+			return errors.New(fmt.Sprintf("field %s: %v", path, err))
+		}
+		return assignValue(decoded, dst, path)
+	}
+	if val == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Pointer {
+		if rv := reflect.ValueOf(val); rv.Type().AssignableTo(dst.Type()) {
+			dst.Set(rv)
+			return nil
+		}
+		elem := reflect.New(dst.Type().Elem())
+		if err := decodeValue(val, elem.Elem(), path); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+	if keepAsIs(dst.Type()) {
+		return assignValue(val, dst, path)
+	}
+	switch dst.Kind() {
+	case reflect.Struct:
+		m, ok := val.(map[string]any)
+		if !ok {
+			return errors.New(fmt.Sprintf("field %s: cannot decode %T into %s", path, val, dst.Type()))
+		}
+		return decodeStruct(m, dst, path)
+	case reflect.Map:
+		m, ok := val.(map[string]any)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return assignValue(val, dst, path)
+		}
+		out := reflect.MakeMapWithSize(dst.Type(), len(m))
+		for key, item := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(item, elem, joinPath(path, key)); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(out)
+		return nil
+	case reflect.Slice:
+		items, ok := val.([]any)
+		if !ok {
+			return assignValue(val, dst, path)
+		}
+		out := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, out.Index(i), fmt.Sprintf("%s.%d", path, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(out)
+		return nil
+	}
+	return assignValue(val, dst, path)
+}
+
+func decodeStruct(m map[string]any, dst reflect.Value, path string) error {
+	rt := dst.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, tagged := structFieldName(field)
+		if name == "" {
+			continue
+		}
+		fv := dst.Field(i)
+		if field.Anonymous && !tagged && fv.Kind() == reflect.Struct && !keepAsIs(fv.Type()) {
+			if err := decodeStruct(m, fv, path); err != nil {
+				return err
+			}
+			continue
+		}
+		val, ok := m[name]
+		if !ok {
+			continue
+		}
+		if err := decodeValue(val, fv, joinPath(path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func assignValue(val any, dst reflect.Value, path string) error {
+	if val == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Type().AssignableTo(dst.Type()) {
+		dst.Set(rv)
+		return nil
+	}
+	if converted, ok := convertValue(val, dst.Type()); ok {
+		dst.Set(reflect.ValueOf(converted))
+		return nil
+	}
+	return errors.New(fmt.Sprintf("field %s: cannot decode %T into %s", path, val, dst.Type()))
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,32 @@
+package cffirestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeDocTimePointer(t *testing.T) {
+	type task struct {
+		Deadline *time.Time `firestore:"deadline"`
+	}
+	deadline := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)
+	for _, stored := range []map[string]any{
+		{"deadline": deadline},
+		{"deadline": &deadline},
+		{"deadline": nil},
+		{},
+	} {
+		var out task
+		if err := decodeDoc(stored, &out); err != nil {
+			t.Errorf("decodeDoc(%v): %v", stored, err)
+			continue
+		}
+		if stored["deadline"] == nil {
+			if out.Deadline != nil {
+				t.Errorf("decodeDoc(%v) deadline = %v, want nil", stored, out.Deadline)
+			}
+		} else if out.Deadline == nil || !out.Deadline.Equal(deadline) {
+			t.Errorf("decodeDoc(%v) deadline = %v, want %v", stored, out.Deadline, deadline)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/samber/lo"
+	"io"
+	"sort"
+	"time"
+)
+
+// CSVHeaderSampleSize is how many docs ExportCSV reads to derive the header
+// when no columns are given.
+var CSVHeaderSampleSize = 100
+
+// ExportCSV writes the docs matching condition to w as CSV and returns the
+// number of data rows written. columns are dot paths into the docs, without
+// them the header is _id followed by the sorted union of the data keys of
+// the first CSVHeaderSampleSize docs. Missing values are empty cells, times
+// are RFC3339 and maps and slices are JSON. Docs are read a page at a time.
+func (coll *Collection) ExportCSV(ctx context.Context, condition []any, w io.Writer, columns []string) (int, error) {
+	return run(ctx, coll, call{Op: "ExportCSV", Condition: condition}, func(ctx context.Context, c *OperationCall) (int, error) {
+		return coll.exportCSV(ctx, c.Condition, w, columns)
+	})
+}
+
+func (coll *Collection) exportCSV(ctx context.Context, condition []any, w io.Writer, columns []string) (int, error) {
+	writer := csv.NewWriter(w)
+	rows := 0
+	var sample []map[string]any
+	writeDocs := func(docs []map[string]any) error {
+		for _, doc := range docs {
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				val, _ := GetPathAny(doc, column)
+				cell, err := csvCell(val)
+				if err != nil {
+					return docErr(fmt.Sprint(doc["_id"]), err)
+				}
+				record[i] = cell
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+			rows++
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	writeHeader := func() error {
+		if len(columns) == 0 {
+			columns = csvColumns(sample)
+		}
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+		docs := sample
+		sample = nil
+		return writeDocs(docs)
+	}
+
+	headerDone := false
+	err := coll.eachPage(ctx, condition, nil, bulkPageSize, func(snaps []*firestore.DocumentSnapshot) error {
+		docs, err := coll.decryptDocs(docSnapsDataToMap(snaps))
+		if err != nil {
+			return err
+		}
+		docs = coll.shapeDocs(docs)
+		if headerDone {
+			return writeDocs(docs)
+		}
+		sample = append(sample, docs...)
+		if len(columns) > 0 || len(sample) >= CSVHeaderSampleSize {
+			headerDone = true
+			return writeHeader()
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	if !headerDone {
+		if err := writeHeader(); err != nil {
+			return rows, err
+		}
+	}
+	return rows, nil
+}
+
+// csvColumns is _id followed by the sorted union of the data keys of docs.
+func csvColumns(docs []map[string]any) []string {
+	keys := make([]string, 0)
+	for _, doc := range docs {
+		keys = append(keys, lo.Reject(lo.Keys(doc), func(key string, _ int) bool { return isMetadataKey(key) })...)
+	}
+	keys = lo.Uniq(keys)
+	sort.Strings(keys)
+	return append([]string{"_id"}, keys...)
+}
+
+func csvCell(val any) (string, error) {
+	switch v := val.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	case *firestore.DocumentRef:
+		if v == nil {
+			return "", nil
+		}
+		return v.Path, nil
+	case map[string]any, []any:
+		b, err := json.Marshal(exportValue(deepCopyMap(v)))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,202 @@
+package cffirestore
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+	"reflect"
+	"time"
+)
+
+type DiffOptions struct {
+	// IgnoreFields are dot paths left out of the comparison, e.g. updatedAt.
+	IgnoreFields []string
+	// TimePrecision truncates times before comparing them, defaults to
+	// Firestore's microsecond precision.
+	TimePrecision time.Duration
+	// AtomicSlices compares slices as a whole instead of element by element.
+	AtomicSlices bool
+}
+
+type DiffKind string
+
+const (
+	DiffAdded    DiffKind = "added"
+	DiffRemoved  DiffKind = "removed"
+	DiffModified DiffKind = "modified"
+)
+
+// FieldDiff is a change of the value at Path, a dot path whose slice
+// elements are indexes like "tags.0".
+type FieldDiff struct {
+	Path string   `json:"path"`
+	Kind DiffKind `json:"kind"`
+	Old  any      `json:"old,omitempty"`
+	New  any      `json:"new,omitempty"`
+}
+
+// DiffDocs lists the changes from before to after, sorted by path within
+// each map. Nested maps are compared key by key and slices element by
+// element, unless opts.AtomicSlices is set or their lengths differ. The
+// _id, _ref and snapshot time metadata keys are ignored.
+func DiffDocs(before, after map[string]any, opts ...DiffOptions) []FieldDiff {
+	opt := DiffOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.TimePrecision <= 0 {
+		opt.TimePrecision = time.Microsecond
+	}
+	diffs := make([]FieldDiff, 0)
+	diffValues(withoutMetadata(before), withoutMetadata(after), "", opt, &diffs)
+	return diffs
+}
+
+func withoutMetadata(doc map[string]any) map[string]any {
+	out := make(map[string]any, len(doc))
+	for key, val := range doc {
+		if !isMetadataKey(key) {
+			out[key] = val
+		}
+	}
+	return out
+}
+
+type DocDiff struct {
+	ID    string   `json:"id"`
+	Paths []string `json:"paths"`
+}
+
+type DiffReport struct {
+	OnlyInA   []string  `json:"onlyInA"`
+	OnlyInB   []string  `json:"onlyInB"`
+	Different []DocDiff `json:"different"`
+	// Same is the number of docs equal in both collections.
+	Same int `json:"same"`
+}
+
+// DiffCollections compares the docs of a and b by id, streaming both in id
+// order so memory stays bounded.
+func DiffCollections(ctx context.Context, a, b *Collection, opts DiffOptions) (DiffReport, error) {
+	report := DiffReport{OnlyInA: []string{}, OnlyInB: []string{}, Different: []DocDiff{}}
+	iterA := a.ref.OrderBy(firestore.DocumentID, firestore.Asc).Documents(ctx)
+	defer iterA.Stop()
+	iterB := b.ref.OrderBy(firestore.DocumentID, firestore.Asc).Documents(ctx)
+	defer iterB.Stop()
+
+	next := func(iter *firestore.DocumentIterator, coll *Collection) (*firestore.DocumentSnapshot, error) {
+		snap, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, coll.wrapErr("DiffCollections", err)
+		}
+		return snap, nil
+	}
+	snapA, err := next(iterA, a)
+	if err != nil {
+		return report, err
+	}
+	snapB, err := next(iterB, b)
+	if err != nil {
+		return report, err
+	}
+	for snapA != nil || snapB != nil {
+		switch {
+		case snapB == nil || (snapA != nil && snapA.Ref.ID < snapB.Ref.ID):
+			report.OnlyInA = append(report.OnlyInA, snapA.Ref.ID)
+			if snapA, err = next(iterA, a); err != nil {
+				return report, err
+			}
+		case snapA == nil || snapB.Ref.ID < snapA.Ref.ID:
+			report.OnlyInB = append(report.OnlyInB, snapB.Ref.ID)
+			if snapB, err = next(iterB, b); err != nil {
+				return report, err
+			}
+		default:
+			paths := lo.Map(DiffDocs(snapA.Data(), snapB.Data(), opts), func(d FieldDiff, _ int) string { return d.Path })
+			if len(paths) > 0 {
+				report.Different = append(report.Different, DocDiff{ID: snapA.Ref.ID, Paths: paths})
+			} else {
+				report.Same++
+			}
+			if snapA, err = next(iterA, a); err != nil {
+				return report, err
+			}
+			if snapB, err = next(iterB, b); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+func diffValues(a, b any, path string, opts DiffOptions, diffs *[]FieldDiff) {
+	if isIgnored(path, opts) {
+		return
+	}
+	mapA, okA := a.(map[string]any)
+	mapB, okB := b.(map[string]any)
+	if okA && okB {
+		for _, key := range sortedKeys(mapA) {
+			valB, ok := mapB[key]
+			if !ok {
+				if !isIgnored(joinPath(path, key), opts) {
+					*diffs = append(*diffs, FieldDiff{Path: joinPath(path, key), Kind: DiffRemoved, Old: mapA[key]})
+				}
+				continue
+			}
+			diffValues(mapA[key], valB, joinPath(path, key), opts, diffs)
+		}
+		for _, key := range sortedKeys(mapB) {
+			if _, ok := mapA[key]; !ok && !isIgnored(joinPath(path, key), opts) {
+				*diffs = append(*diffs, FieldDiff{Path: joinPath(path, key), Kind: DiffAdded, New: mapB[key]})
+			}
+		}
+		return
+	}
+	sliceA, okA := a.([]any)
+	sliceB, okB := b.([]any)
+	if okA && okB && len(sliceA) == len(sliceB) && !opts.AtomicSlices {
+		for i := range sliceA {
+			diffValues(sliceA[i], sliceB[i], fmt.Sprintf("%s.%d", path, i), opts, diffs)
+		}
+		return
+	}
+	if !valuesEqual(a, b, opts.TimePrecision) {
+		*diffs = append(*diffs, FieldDiff{Path: path, Kind: DiffModified, Old: a, New: b})
+	}
+}
+
+func isIgnored(path string, opts DiffOptions) bool {
+	for _, ignored := range opts.IgnoreFields {
+		if path == ignored {
+			return true
+		}
+	}
+	return false
+}
+
+func valuesEqual(a, b any, precision time.Duration) bool {
+	switch va := a.(type) {
+	case time.Time:
+		vb, ok := b.(time.Time)
+		return ok && va.Truncate(precision).Equal(vb.Truncate(precision))
+	case []byte:
+		vb, ok := b.([]byte)
+		return ok && bytes.Equal(va, vb)
+	case *firestore.DocumentRef:
+		vb, ok := b.(*firestore.DocumentRef)
+		return ok && (va == vb || (va != nil && vb != nil && va.Path == vb.Path))
+	}
+	if fa, ok := toFloat64(a); ok {
+		fb, ok := toFloat64(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,98 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DocumentID is the condition and orderBy path of the document ID, as in
+// []any{cffirestore.DocumentID, ">=", "INV-2024"}. Its values may be bare
+// IDs, document paths, full resource names or *firestore.DocumentRef.
+const DocumentID = firestore.DocumentID
+
+// docIDValue turns the value of a DocumentID where clause into the refs
+// Firestore compares document names with.
+func (coll *Collection) docIDValue(val any) (any, error) {
+	if vals, ok := val.([]any); ok {
+		out := make([]any, len(vals))
+		for i, v := range vals {
+			ref, err := coll.docIDRef(v)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = ref
+		}
+		return out, nil
+	}
+	return coll.docIDRef(val)
+}
+
+func (coll *Collection) docIDRef(val any) (*firestore.DocumentRef, error) {
+	switch v := val.(type) {
+	case *firestore.DocumentRef:
+		return v, nil
+	case string:
+		if !strings.Contains(v, "/") {
+			return coll.ref.Doc(v), nil
+		}
+		if coll.Client == nil {
+			return nil, errors.New(fmt.Sprintf("document id %s: a document path needs a client", v))
+		}
+		ref := coll.Client.Doc(relativeDocPath(v))
+		if ref == nil {
+			return nil, errors.New(fmt.Sprintf("document id %s: not a document path", v))
+		}
+		return ref, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("document id must be a string or *firestore.DocumentRef, got %T", val))
+	}
+}
+
+// docIDCursor turns the document paths at the DocumentID orderBys of a
+// cursor into refs, bare IDs being taken as is by Firestore.
+func (coll *Collection) docIDCursor(orderBys []OrderBy, vals []any) ([]any, error) {
+	out := make([]any, len(vals))
+	copy(out, vals)
+	for i := range out {
+		if i >= len(orderBys) || orderBys[i].Field != DocumentID {
+			continue
+		}
+		if s, ok := out[i].(string); ok && strings.Contains(s, "/") {
+			ref, err := coll.docIDRef(s)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = ref
+		}
+	}
+	return out, nil
+}
+
+// relativeDocPath strips the projects/p/databases/d/documents/ prefix of a
+// full resource name.
+func relativeDocPath(path string) string {
+	if _, rest, ok := strings.Cut(path, "/documents/"); ok {
+		return rest
+	}
+	return strings.TrimPrefix(path, "/")
+}
+
+// memoryDocID is the bare ID of a DocumentID condition value.
+func memoryDocID(val any) any {
+	switch v := val.(type) {
+	case *firestore.DocumentRef:
+		return v.ID
+	case string:
+		return v[strings.LastIndex(v, "/")+1:]
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = memoryDocID(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
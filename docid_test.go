@@ -0,0 +1,129 @@
+package cffirestore
+
+import (
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"fmt"
+	"github.com/samber/lo"
+	"reflect"
+	"testing"
+)
+
+const testDocsPrefix = "projects/" + testProject + "/databases/(default)/documents/tests/"
+
+func fieldFilters(sq *pb.StructuredQuery) []*pb.StructuredQuery_FieldFilter {
+	if filter := sq.GetWhere().GetFieldFilter(); filter != nil {
+		return []*pb.StructuredQuery_FieldFilter{filter}
+	}
+	return lo.Map(sq.GetWhere().GetCompositeFilter().GetFilters(), func(f *pb.StructuredQuery_Filter, _ int) *pb.StructuredQuery_FieldFilter {
+		return f.GetFieldFilter()
+	})
+}
+
+func TestMakeQueryDocumentIDEquals(t *testing.T) {
+	coll := testCollection(t)
+	for _, val := range []any{
+		"INV-1",
+		"tests/INV-1",
+		testDocsPrefix + "INV-1",
+		coll.Ref().Doc("INV-1"),
+	} {
+		query, err := coll.MakeQueryE([]any{[]any{DocumentID, "==", val}})
+		if err != nil {
+			t.Fatalf("%v: %v", val, err)
+		}
+		filter := structuredQuery(t, query).GetWhere().GetFieldFilter()
+		if filter.GetField().GetFieldPath() != "__name__" || filter.GetOp() != pb.StructuredQuery_FieldFilter_EQUAL ||
+			filter.GetValue().GetReferenceValue() != testDocsPrefix+"INV-1" {
+			t.Errorf("%v: filter = %v", val, filter)
+		}
+	}
+	if _, err := coll.MakeQueryE([]any{[]any{DocumentID, "==", 1}}); err == nil {
+		t.Error("int document id: no error")
+	}
+}
+
+func TestMakeQueryDocumentIDIn(t *testing.T) {
+	coll := testCollection(t)
+	for _, val := range []any{
+		[]string{"INV-1", "tests/INV-2"},
+		[]any{"INV-1", coll.Ref().Doc("INV-2")},
+	} {
+		query, err := coll.MakeQueryE([]any{[]any{DocumentID, "in", val}})
+		if err != nil {
+			t.Fatalf("%v: %v", val, err)
+		}
+		filter := structuredQuery(t, query).GetWhere().GetFieldFilter()
+		got := lo.Map(filter.GetValue().GetArrayValue().GetValues(), func(v *pb.Value, _ int) string {
+			return v.GetReferenceValue()
+		})
+		if want := []string{testDocsPrefix + "INV-1", testDocsPrefix + "INV-2"}; filter.GetOp() != pb.StructuredQuery_FieldFilter_IN || !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: filter = %v", val, filter)
+		}
+	}
+}
+
+func TestMakeQueryDocumentIDRange(t *testing.T) {
+	coll := testCollection(t)
+	query, err := coll.MakeQueryE([]any{
+		[]any{DocumentID, ">=", "INV-2024"},
+		[]any{DocumentID, "<", "INV-2025"},
+		map[string]any{"orderBy": DocumentID + ":desc", "startAfter": "tests/INV-2024-9"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sq := structuredQuery(t, query)
+	got := lo.Map(fieldFilters(sq), func(f *pb.StructuredQuery_FieldFilter, _ int) string {
+		return fmt.Sprint(f.GetField().GetFieldPath(), " ", f.GetOp(), " ", f.GetValue().GetReferenceValue())
+	})
+	want := []string{
+		"__name__ GREATER_THAN_OR_EQUAL " + testDocsPrefix + "INV-2024",
+		"__name__ LESS_THAN " + testDocsPrefix + "INV-2025",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filters = %v, want %v", got, want)
+	}
+	if got, want := orderByKeys(sq), []string{"__name__:desc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("orderBy = %v, want %v", got, want)
+	}
+	if cursor := sq.GetStartAt().GetValues(); len(cursor) != 1 || cursor[0].GetReferenceValue() != testDocsPrefix+"INV-2024-9" {
+		t.Errorf("startAt = %v", sq.GetStartAt())
+	}
+
+	query, err = coll.MakeQueryE([]any{map[string]any{"orderBy": DocumentID, "startAt": "INV-2024"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor := structuredQuery(t, query).GetStartAt().GetValues(); len(cursor) != 1 || cursor[0].GetReferenceValue() != testDocsPrefix+"INV-2024" {
+		t.Errorf("bare id startAt = %v", cursor)
+	}
+}
+
+func TestMemoryCollectionDocumentID(t *testing.T) {
+	m := NewMemoryCollection("tests")
+	for _, id := range []string{"INV-2023-1", "INV-2024-1", "INV-2024-2", "INV-2025-1"} {
+		id := id
+		if _, _, err := m.AddDocWithId(&id, nil, map[string]any{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		condition []any
+		want      []string
+	}{
+		{[]any{[]any{DocumentID, "==", "INV-2024-1"}}, []string{"INV-2024-1"}},
+		{[]any{[]any{DocumentID, "==", "tests/INV-2024-1"}}, []string{"INV-2024-1"}},
+		{[]any{[]any{DocumentID, "in", []string{"INV-2023-1", testDocsPrefix + "INV-2025-1"}}}, []string{"INV-2023-1", "INV-2025-1"}},
+		{[]any{[]any{DocumentID, ">=", "INV-2024"}, []any{DocumentID, "<", "INV-2025"}}, []string{"INV-2024-1", "INV-2024-2"}},
+		{[]any{map[string]any{"orderBy": DocumentID + ":desc"}}, []string{"INV-2025-1", "INV-2024-2", "INV-2024-1", "INV-2023-1"}},
+	} {
+		docs, err := m.ListDocs(tc.condition)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.condition, err)
+		}
+		got := lo.Map(docs, func(doc map[string]any, _ int) string { return doc["_id"].(string) })
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: docs = %v, want %v", tc.condition, got, tc.want)
+		}
+	}
+}
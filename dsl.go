@@ -0,0 +1,289 @@
+package cffirestore
+
+import (
+	"fmt"
+	"github.com/samber/lo"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ParseError is a ParseCondition syntax error at byte offset Pos.
+type ParseError struct {
+	Pos      int
+	Expected []string
+	Found    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse condition at %d: expected %s, found %s", e.Pos, strings.Join(e.Expected, " or "), e.Found)
+}
+
+var dslOperators = []string{"==", "!=", "<=", ">=", "<", ">", "in", "not-in", "array-contains", "array-contains-any"}
+
+type dslTokenKind int
+
+const (
+	dslEOF dslTokenKind = iota
+	dslWord
+	dslString
+	dslSymbol
+)
+
+type dslToken struct {
+	kind dslTokenKind
+	text string
+	pos  int
+}
+
+func (t dslToken) String() string {
+	switch t.kind {
+	case dslEOF:
+		return "end of input"
+	case dslString:
+		return t.text
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+// ParseCondition parses a condition string into the []any form MakeQuery
+// takes, e.g.
+//
+//	status == "active" && age >= 18 && tags array-contains "vip" order by createdAt desc limit 20
+//
+// Values are quoted strings, numbers, true, false, null, RFC3339 timestamps
+// and [..] lists. Clauses are joined with && or and.
+func ParseCondition(s string) ([]any, error) {
+	tokens, err := lexCondition(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &dslParser{tokens: tokens}
+	return p.parse()
+}
+
+func lexCondition(s string) ([]dslToken, error) {
+	tokens := make([]dslToken, 0)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, &ParseError{Pos: i, Expected: []string{"closing quote"}, Found: "end of input"}
+			}
+			tokens = append(tokens, dslToken{dslString, s[i : end+1], i})
+			i = end + 1
+		case strings.ContainsRune("[],", rune(c)):
+			tokens = append(tokens, dslToken{dslSymbol, string(c), i})
+			i++
+		case strings.ContainsRune("=!<>&", rune(c)):
+			end := i + 1
+			for end < len(s) && strings.ContainsRune("=!<>&", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, dslToken{dslSymbol, s[i:end], i})
+			i = end
+		default:
+			end := i
+			for end < len(s) && isDslWordByte(s[end]) {
+				end++
+			}
+			if end == i {
+				return nil, &ParseError{Pos: i, Expected: []string{"token"}, Found: strconv.Quote(string(c))}
+			}
+			tokens = append(tokens, dslToken{dslWord, s[i:end], i})
+			i = end
+		}
+	}
+	return append(tokens, dslToken{dslEOF, "", len(s)}), nil
+}
+
+func isDslWordByte(c byte) bool {
+	return c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || strings.ContainsRune("_.-+:", rune(c))
+}
+
+type dslParser struct {
+	tokens []dslToken
+	pos    int
+}
+
+func (p *dslParser) peek() dslToken {
+	return p.tokens[p.pos]
+}
+
+func (p *dslParser) next() dslToken {
+	t := p.tokens[p.pos]
+	if t.kind != dslEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *dslParser) fail(t dslToken, expected ...string) error {
+	return &ParseError{Pos: t.pos, Expected: expected, Found: t.String()}
+}
+
+func (p *dslParser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == dslWord && strings.EqualFold(t.text, word)
+}
+
+func (p *dslParser) parse() ([]any, error) {
+	condition := make([]any, 0)
+	options := make(map[string]any)
+	if !p.atClauseEnd() {
+		for {
+			where, err := p.parseWhere()
+			if err != nil {
+				return nil, err
+			}
+			condition = append(condition, where)
+			if t := p.peek(); (t.kind == dslSymbol && t.text == "&&") || p.isKeyword("and") {
+				p.next()
+				continue
+			}
+			break
+		}
+	}
+	if p.isKeyword("order") {
+		p.next()
+		if !p.isKeyword("by") {
+			return nil, p.fail(p.peek(), `"by"`)
+		}
+		p.next()
+		orderBy, err := p.parseOrderBys()
+		if err != nil {
+			return nil, err
+		}
+		options["orderBy"] = orderBy
+	}
+	for _, name := range []string{"limit", "offset"} {
+		if !p.isKeyword(name) {
+			continue
+		}
+		p.next()
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != dslWord || err != nil || n < 0 {
+			return nil, p.fail(t, "non negative integer")
+		}
+		options[name] = n
+	}
+	if t := p.peek(); t.kind != dslEOF {
+		return nil, p.fail(t, `"&&"`, `"order by"`, `"limit"`, `"offset"`, "end of input")
+	}
+	if len(options) > 0 {
+		condition = append(condition, options)
+	}
+	return condition, nil
+}
+
+func (p *dslParser) atClauseEnd() bool {
+	return p.peek().kind == dslEOF || p.isKeyword("order") || p.isKeyword("limit") || p.isKeyword("offset")
+}
+
+func (p *dslParser) parseWhere() ([]any, error) {
+	field := p.next()
+	if field.kind != dslWord {
+		return nil, p.fail(field, "field name")
+	}
+	opToken := p.next()
+	op := strings.ToLower(opToken.text)
+	if opToken.kind == dslEOF || !lo.Contains(dslOperators, op) {
+		return nil, p.fail(opToken, dslOperators...)
+	}
+	val, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return []any{field.text, op, val}, nil
+}
+
+func (p *dslParser) parseValue() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case dslString:
+		s, err := strconv.Unquote(t.text)
+		if err != nil {
+			return nil, p.fail(t, "valid string")
+		}
+		return s, nil
+	case dslSymbol:
+		if t.text != "[" {
+			break
+		}
+		list := make([]any, 0)
+		if t := p.peek(); t.kind == dslSymbol && t.text == "]" {
+			p.next()
+			return list, nil
+		}
+		for {
+			val, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+			sep := p.next()
+			if sep.kind == dslSymbol && sep.text == "]" {
+				return list, nil
+			}
+			if sep.kind != dslSymbol || sep.text != "," {
+				return nil, p.fail(sep, `","`, `"]"`)
+			}
+		}
+	case dslWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if i, err := strconv.Atoi(t.text); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return f, nil
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, t.text); err == nil {
+			return ts, nil
+		}
+	}
+	return nil, p.fail(t, "string", "number", "boolean", "null", "timestamp", "list")
+}
+
+func (p *dslParser) parseOrderBys() (any, error) {
+	orderBys := make([]string, 0)
+	for {
+		field := p.next()
+		if field.kind != dslWord {
+			return nil, p.fail(field, "field name")
+		}
+		dir := "asc"
+		if p.isKeyword("asc") || p.isKeyword("desc") {
+			dir = strings.ToLower(p.next().text)
+		}
+		orderBys = append(orderBys, field.text+":"+dir)
+		if t := p.peek(); t.kind == dslSymbol && t.text == "," {
+			p.next()
+			continue
+		}
+		break
+	}
+	if len(orderBys) == 1 {
+		return orderBys[0], nil
+	}
+	return orderBys, nil
+}
@@ -0,0 +1,158 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"strings"
+)
+
+var EncryptedValuePrefix = "enc:v1:"
+
+type fieldKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// WithEncryptedFields makes writes store the given top level string fields
+// AES-GCM encrypted and reads decrypt them. keys[0] encrypts, every key is
+// tried for decrypt, so prepend a new key to rotate.
+func (coll *Collection) WithEncryptedFields(keys [][]byte, fields ...string) (*Collection, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys")
+	}
+	fieldKeys := make([]fieldKey, 0, len(keys))
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		fieldKeys = append(fieldKeys, fieldKey{hex.EncodeToString(sum[:4]), aead})
+	}
+	coll.encryptionKeys = fieldKeys
+	coll.encryptedFields = lo.Uniq(append(coll.encryptedFields, fields...))
+	return coll, nil
+}
+
+func (coll *Collection) isEncryptedField(path string) bool {
+	for _, field := range coll.encryptedFields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptFields returns a copy of v with the encrypted fields replaced by
+// their ciphertext.
+func (coll *Collection) encryptFields(v map[string]any) (map[string]any, error) {
+	if len(coll.encryptedFields) == 0 {
+		return v, nil
+	}
+	out := lo.Assign(v)
+	for _, field := range coll.encryptedFields {
+		val, ok := v[field]
+		if !ok {
+			continue
+		}
+		encrypted, err := coll.encryptValue(field, val)
+		if err != nil {
+			return nil, err
+		}
+		out[field] = encrypted
+	}
+	return out, nil
+}
+
+func (coll *Collection) encryptUpdates(updates []firestore.Update) ([]firestore.Update, error) {
+	for i, update := range updates {
+		if !coll.isEncryptedField(update.Path) {
+			continue
+		}
+		encrypted, err := coll.encryptValue(update.Path, update.Value)
+		if err != nil {
+			return nil, err
+		}
+		updates[i].Value = encrypted
+	}
+	return updates, nil
+}
+
+func (coll *Collection) encryptValue(field string, val any) (any, error) {
+	switch s := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		key := coll.encryptionKeys[0]
+		nonce := make([]byte, key.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		sealed := key.aead.Seal(nonce, nonce, []byte(s), []byte(field))
+		return EncryptedValuePrefix + key.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("field %s: cannot encrypt %T, only strings", field, val))
+	}
+}
+
+func (coll *Collection) decryptDocs(docs []map[string]any) ([]map[string]any, error) {
+	for _, doc := range docs {
+		if err := coll.decryptFields(doc); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// decryptFields decrypts the encrypted fields of doc in place. Values written
+// before the field was encrypted are returned as they are.
+func (coll *Collection) decryptFields(doc map[string]any) error {
+	for _, field := range coll.encryptedFields {
+		s, ok := doc[field].(string)
+		if !ok || !strings.HasPrefix(s, EncryptedValuePrefix) {
+			continue
+		}
+		plain, err := coll.decryptValue(field, s)
+		if err != nil {
+			id, _ := doc["_id"].(string)
+			return docErr(id, err)
+		}
+		doc[field] = plain
+	}
+	return nil
+}
+
+func (coll *Collection) decryptValue(field string, s string) (string, error) {
+	keyID, encoded, ok := strings.Cut(strings.TrimPrefix(s, EncryptedValuePrefix), ":")
+	if !ok {
+		return "", errors.New(fmt.Sprintf("field %s: malformed encrypted value", field))
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("field %s: malformed encrypted value", field))
+	}
+	for _, key := range coll.encryptionKeys {
+		if key.id != keyID || len(sealed) < key.aead.NonceSize() {
+			continue
+		}
+		nonceSize := key.aead.NonceSize()
+		plain, err := key.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(field))
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("field %s: %v", field, err))
+		}
+		return string(plain), nil
+	}
+	return "", errors.New(fmt.Sprintf("field %s: no key %s to decrypt", field, keyID))
+}
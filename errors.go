@@ -0,0 +1,88 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+var ErrDocNotFound = errors.New("doc not found")
+
+// ErrTimeout is returned when a call runs past the collection timeout, see
+// Collection.WithTimeout. It wraps context.DeadlineExceeded.
+var ErrTimeout = fmt.Errorf("timeout: %w", context.DeadlineExceeded)
+
+// Sentinels of the Firestore status codes callers commonly handle, matched
+// with errors.Is while the original status error stays in the chain.
+var (
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrUnavailable        = errors.New("unavailable")
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrResourceExhausted  = errors.New("resource exhausted")
+	ErrFailedPrecondition = errors.New("failed precondition")
+)
+
+// ErrMissingIndex is the FailedPrecondition of a query lacking its composite
+// index. It wraps ErrFailedPrecondition.
+var ErrMissingIndex = fmt.Errorf("missing index: %w", ErrFailedPrecondition)
+
+var statusSentinels = map[codes.Code]error{
+	codes.PermissionDenied:   ErrPermissionDenied,
+	codes.Unavailable:        ErrUnavailable,
+	codes.InvalidArgument:    ErrInvalidArgument,
+	codes.ResourceExhausted:  ErrResourceExhausted,
+	codes.FailedPrecondition: ErrFailedPrecondition,
+}
+
+func docNotFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrDocNotFound, id)
+}
+
+// wrapErr prefixes err with the operation and collection path, e.g.
+// "cffirestore: ListDocs users: <cause>".
+func (coll *Collection) wrapErr(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	err = asStatusErr(asTimeout(err))
+	return fmt.Errorf("cffirestore: %s %s: %w", op, coll.Path, err)
+}
+
+func (coll *Collection) wrapDocErr(op string, id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	err = asStatusErr(asTimeout(err))
+	return fmt.Errorf("cffirestore: %s %s/%s: %w", op, coll.Path, id, err)
+}
+
+func docErr(id string, err error) error {
+	return fmt.Errorf("doc %s: %w", id, err)
+}
+
+func asTimeout(err error) error {
+	if errors.Is(err, ErrTimeout) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// asStatusErr wraps err with the sentinel of its status code, if any.
+func asStatusErr(err error) error {
+	code := status.Code(err)
+	sentinel, ok := statusSentinels[code]
+	if !ok || errors.Is(err, sentinel) {
+		return err
+	}
+	var missing *MissingIndexError
+	if code == codes.FailedPrecondition && (errors.As(err, &missing) || strings.Contains(strings.ToLower(err.Error()), "index")) {
+		sentinel = ErrMissingIndex
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
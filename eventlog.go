@@ -0,0 +1,105 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var EventSeqFieldName = "seq"
+
+// ErrAppendOnly is returned by the writes of a collection wrapped by
+// NewEventLog.
+var ErrAppendOnly = errors.New("collection is an append-only event log")
+
+type EventLog struct {
+	coll    *Collection
+	counter *firestore.DocumentRef
+}
+
+// NewEventLog makes coll an append-only log: events are only written by
+// Append, and every other write of coll, AddDoc included, fails with
+// ErrAppendOnly. The sequence counter is kept in "<path>__eventlog/seq".
+func NewEventLog(coll *Collection) *EventLog {
+	coll.appendOnly = true
+	return &EventLog{
+		coll:    coll,
+		counter: coll.Client.Collection(coll.Path + "__eventlog").Doc(EventSeqFieldName),
+	}
+}
+
+// Append stores event with the next sequence number and returns it. The
+// counter and the event are written in one transaction and the event is
+// created, never overwritten. Consumers should still not rely on sequences
+// being contiguous.
+func (l *EventLog) Append(ctx context.Context, event map[string]any) (int64, error) {
+	return run(ctx, l.coll, call{Op: "AppendEvent"}, func(ctx context.Context, c *OperationCall) (int64, error) {
+		return l.append(ctx, event)
+	})
+}
+
+func (l *EventLog) append(ctx context.Context, event map[string]any) (int64, error) {
+	ctx, cancel := l.coll.withTimeout(ctx)
+	defer cancel()
+	var seq int64
+	err := l.coll.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		seq = 0
+		snap, err := tx.Get(l.counter)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if snap != nil && snap.Exists() {
+			last, err := snap.DataAt(EventSeqFieldName)
+			if err != nil {
+				return err
+			}
+			current, ok := last.(int64)
+			if !ok {
+				return errors.New(fmt.Sprintf("event log counter holds %T", last))
+			}
+			seq = current
+		}
+		seq++
+
+		doc := lo.Assign(event)
+		if err := encodeValues(doc); err != nil {
+			return err
+		}
+		doc[EventSeqFieldName] = seq
+		doc[CreatedAtFieldName] = l.coll.now()
+		id := fmt.Sprintf("%020d", seq)
+		doc[IdFieldName] = id
+		if err := l.coll.validateDoc(doc); err != nil {
+			return err
+		}
+		stored, err := l.coll.encryptFields(doc)
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(l.counter, map[string]any{EventSeqFieldName: seq}); err != nil {
+			return err
+		}
+		return tx.Create(l.coll.ref.Doc(id), stored)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// ReadSince lists up to limit events with a sequence after seq, in order.
+// A limit of 0 reads them all.
+func (l *EventLog) ReadSince(ctx context.Context, seq int64, limit int) ([]map[string]any, error) {
+	options := map[string]any{"orderBy": EventSeqFieldName}
+	if limit > 0 {
+		options["limit"] = limit
+	}
+	condition := []any{[]any{EventSeqFieldName, ">", seq}, options}
+	return run(ctx, l.coll, call{Op: "ReadEvents", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		return l.coll.listDocs(ctx, c.Condition)
+	})
+}
@@ -0,0 +1,159 @@
+package cffirestore
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+type FixtureOptions struct {
+	// SkipExisting keeps docs that already exist instead of overwriting them.
+	SkipExisting bool
+}
+
+// LoadFixtures writes the docs found under dir in fsys. Each directory is a
+// collection and each .json, .yaml or .yml file in it a doc whose ID is the
+// file name. A directory named like a doc ID holds that doc's
+// subcollections:
+//
+//	users/alice.json
+//	users/alice/orders/o1.json
+//
+// String values "$now", "$now-24h", "$now+1h30m"... are replaced by the
+// matching time. Errors are reported per file.
+func LoadFixtures(ctx context.Context, client *firestore.Client, fsys fs.FS, dir string, opts ...FixtureOptions) error {
+	var opt FixtureOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			errs = append(errs, loadFixtureCollection(ctx, client, fsys, path.Join(dir, entry.Name()), entry.Name(), opt))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func loadFixtureCollection(ctx context.Context, client *firestore.Client, fsys fs.FS, dir string, collPath string, opt FixtureOptions) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, entry := range entries {
+		filePath := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			subEntries, err := fs.ReadDir(fsys, filePath)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, sub := range subEntries {
+				if sub.IsDir() {
+					errs = append(errs, loadFixtureCollection(ctx, client, fsys, path.Join(filePath, sub.Name()), path.Join(collPath, entry.Name(), sub.Name()), opt))
+				}
+			}
+			continue
+		}
+		ext := path.Ext(entry.Name())
+		if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		docId := strings.TrimSuffix(entry.Name(), ext)
+		if err := loadFixtureDoc(ctx, client.Collection(collPath).Doc(docId), fsys, filePath, opt); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filePath, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func loadFixtureDoc(ctx context.Context, ref *firestore.DocumentRef, fsys fs.FS, filePath string, opt FixtureOptions) error {
+	raw, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return err
+	}
+	data := make(map[string]any)
+	if path.Ext(filePath) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		err = decoder.Decode(&data)
+	} else {
+		err = yaml.Unmarshal(raw, &data)
+	}
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveFixtureValue(data, time.Now())
+	if err != nil {
+		return err
+	}
+	data = resolved.(map[string]any)
+
+	if opt.SkipExisting {
+		_, err = ref.Create(ctx, data)
+		if status.Code(err) == codes.AlreadyExists {
+			return nil
+		}
+		return err
+	}
+	_, err = ref.Set(ctx, data)
+	return err
+}
+
+func resolveFixtureValue(v any, now time.Time) (any, error) {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, item := range val {
+			resolved, err := resolveFixtureValue(item, now)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("%s: %v", key, err))
+			}
+			val[key] = resolved
+		}
+		return val, nil
+	case []any:
+		for i, item := range val {
+			resolved, err := resolveFixtureValue(item, now)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = resolved
+		}
+		return val, nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Float64()
+	case string:
+		if !strings.HasPrefix(val, "$now") {
+			return val, nil
+		}
+		offset := strings.TrimPrefix(val, "$now")
+		if offset == "" {
+			return now, nil
+		}
+		d, err := time.ParseDuration(strings.TrimPrefix(offset, "+"))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid time token %q", val))
+		}
+		return now.Add(d), nil
+	default:
+		return val, nil
+	}
+}
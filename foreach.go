@@ -0,0 +1,68 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+type ForEachOptions struct {
+	// Concurrency is how many docs fn processes at once, 1 when unset. Each
+	// doc is still passed to fn at most once.
+	Concurrency int
+}
+
+// ForEachDoc calls fn with every doc matching condition as the query streams
+// them, without listing them first. It stops at the first fn error, which
+// it returns, or when ctx is done.
+func (coll *Collection) ForEachDoc(ctx context.Context, condition []any, fn func(doc map[string]any) error, opts ...ForEachOptions) error {
+	_, err := run(ctx, coll, call{Op: "ForEachDoc", Condition: condition}, func(ctx context.Context, c *OperationCall) (any, error) {
+		return nil, coll.forEachDoc(ctx, c.Condition, fn, opts...)
+	})
+	return err
+}
+
+func (coll *Collection) forEachDoc(ctx context.Context, condition []any, fn func(doc map[string]any) error, opts ...ForEachOptions) error {
+	opt := ForEachOptions{Concurrency: 1}
+	if len(opts) > 0 && opts[0].Concurrency > 0 {
+		opt.Concurrency = opts[0].Concurrency
+	}
+	query, plan, err := coll.makeFilteredQuery(condition)
+	if err != nil {
+		return err
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opt.Concurrency)
+	iter := query.Documents(gctx)
+	defer iter.Stop()
+	for {
+		if err := gctx.Err(); err != nil {
+			break
+		}
+		snap, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			g.Go(func() error { return err })
+			break
+		}
+		doc := coll.docResponse(snap)
+		if !plan.keep(doc) {
+			continue
+		}
+		if err := coll.decryptFields(doc); err != nil {
+			g.Go(func() error { return docErr(snap.Ref.ID, err) })
+			break
+		}
+		doc = coll.shapeDoc(doc)
+		g.Go(func() error {
+			return fn(doc)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,81 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/iterator"
+	"strings"
+	"time"
+)
+
+// GroupNoneKey is the bucket for docs missing a grouping field.
+var GroupNoneKey = "(none)"
+
+// GroupKeySeparator joins the values of several key fields in GroupDocs.
+var GroupKeySeparator = "|"
+
+// GroupDocs buckets docs by the values at keyFields (dot paths).
+func GroupDocs(docs []map[string]any, keyFields []string) map[string][]map[string]any {
+	groups := make(map[string][]map[string]any)
+	for _, doc := range docs {
+		key := groupKeyOf(doc, keyFields)
+		groups[key] = append(groups[key], doc)
+	}
+	return groups
+}
+
+func groupKeyOf(doc map[string]any, keyFields []string) string {
+	parts := make([]string, 0, len(keyFields))
+	for _, field := range keyFields {
+		val, _ := GetPathAny(doc, field)
+		parts = append(parts, groupKey(val))
+	}
+	return strings.Join(parts, GroupKeySeparator)
+}
+
+func groupKey(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return GroupNoneKey
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// GroupByCount counts matching docs per distinct value of keyField. Only the
+// key field is read, one doc at a time, filtered like ListDocs, so unlike
+// CountDocs it takes FieldMissing.
+func (coll *Collection) GroupByCount(ctx context.Context, condition []any, keyField string) (map[string]int, error) {
+	return run(ctx, coll, call{Op: "GroupByCount", Condition: condition}, func(ctx context.Context, c *OperationCall) (map[string]int, error) {
+		return coll.groupByCount(ctx, c.Condition, keyField)
+	})
+}
+
+func (coll *Collection) groupByCount(ctx context.Context, condition []any, keyField string) (map[string]int, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	query, plan, err := coll.makeFilteredQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	iter := query.Select(append([]string{keyField}, plan.missing...)...).Documents(ctx)
+	defer iter.Stop()
+
+	counts := make(map[string]int)
+	for {
+		snap, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if doc := snap.Data(); plan.keep(doc) {
+			counts[groupKeyOf(doc, []string{keyField})]++
+		}
+	}
+	return counts, nil
+}
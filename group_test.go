@@ -0,0 +1,30 @@
+package cffirestore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGroupByCount(t *testing.T) {
+	coll := emulatorCollection(t)
+	seedDocs(t, coll, 10)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		condition []any
+		want      map[string]int
+	}{
+		{nil, map[string]int{"0": 4, "1": 3, "2": 3}},
+		{[]any{[]any{"n", ">=", 5}}, map[string]int{"0": 2, "1": 1, "2": 2}},
+		{[]any{FieldMissing("absent")}, map[string]int{"0": 4, "1": 3, "2": 3}},
+		{[]any{FieldMissing("n")}, map[string]int{}},
+	} {
+		counts, err := coll.GroupByCount(ctx, tc.condition, "group")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(counts, tc.want) {
+			t.Errorf("GroupByCount(%v) = %v, want %v", tc.condition, counts, tc.want)
+		}
+	}
+}
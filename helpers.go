@@ -2,12 +2,19 @@ package cffirestore
 
 import (
 	"cloud.google.com/go/firestore"
-	"encoding/json"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+	"math"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // helpers
@@ -20,6 +27,25 @@ func docSnapsDataToMap(docSnaps []*firestore.DocumentSnapshot) []map[string]any
 	return data
 }
 
+// snapshotTimeKeys are the metadata keys of the snapshot times, returned
+// only with ResponseOptions.IncludeSnapshotTimes.
+var snapshotTimeKeys = []string{"_createTime", "_updateTime", "_readTime"}
+
+// isMetadataKey reports the keys responses add to doc data, which are never
+// written back.
+func isMetadataKey(key string) bool {
+	return key == "_id" || key == "_ref" || lo.Contains(snapshotTimeKeys, key)
+}
+
+// dropMetadata removes the metadata keys of a doc read before writing it.
+func dropMetadata(v map[string]any) {
+	for key := range v {
+		if isMetadataKey(key) {
+			delete(v, key)
+		}
+	}
+}
+
 func makeDocResponse(doc *firestore.DocumentSnapshot) map[string]any {
 	return lo.Assign(
 		doc.Data(),
@@ -30,17 +56,221 @@ func makeDocResponse(doc *firestore.DocumentSnapshot) map[string]any {
 	)
 }
 
-func structToMap(v any) map[string]any {
-	var inInterface map[string]interface{}
-	inrec, err := json.Marshal(v)
-	if err != nil {
-		return nil
+// docResponse is makeDocResponse with the snapshot times when the response
+// options include them.
+func (coll *Collection) docResponse(doc *firestore.DocumentSnapshot) map[string]any {
+	data := makeDocResponse(doc)
+	if opts := coll.responseOptions; opts != nil && opts.IncludeSnapshotTimes {
+		data["_createTime"] = doc.CreateTime
+		data["_updateTime"] = doc.UpdateTime
+		data["_readTime"] = doc.ReadTime
 	}
-	err = json.Unmarshal(inrec, &inInterface)
-	if err != nil {
-		return nil
+	return data
+}
+
+func (coll *Collection) docResponses(docSnaps []*firestore.DocumentSnapshot) []map[string]any {
+	return lo.Map(docSnaps, func(doc *firestore.DocumentSnapshot, _ int) map[string]any {
+		return coll.docResponse(doc)
+	})
+}
+
+// ToDocMap converts a struct (or pointer to one) into a doc map, naming
+// fields by their firestore tag, else their json tag, else their Go name; "-"
+// skips a field and omitempty skips it when empty. Nested structs become
+// maps, while time.Time, integers, []byte and Firestore's own types are kept
+// as they are.
+func ToDocMap(v any) (map[string]any, error) {
+	if m, ok := v.(map[string]any); ok {
+		return m, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, errors.New("cannot convert nil to doc map")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprintf("cannot convert %T to doc map", v))
+	}
+	out := make(map[string]any)
+	if err := structFieldsToMap(rv, "", out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func structFieldsToMap(rv reflect.Value, path string, out map[string]any) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, tagged := structFieldName(field)
+		if name == "" {
+			continue
+		}
+		fv := rv.Field(i)
+		if structFieldOmitEmpty(field) && isEmptyValue(fv) {
+			continue
+		}
+		if field.Anonymous && !tagged {
+			for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !keepAsIs(fv.Type()) {
+				if err := structFieldsToMap(fv, path, out); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		val, err := toDocValue(fv, path+name)
+		if err != nil {
+			return err
+		}
+		out[name] = val
+	}
+	return nil
+}
+
+// structFieldName names field by its firestore tag, else its json tag, else
+// its Go name, reporting whether a tag named it. An empty name is "-".
+func structFieldName(field reflect.StructField) (string, bool) {
+	for _, tagKey := range []string{"firestore", "json"} {
+		tag, ok := field.Tag.Lookup(tagKey)
+		if !ok {
+			continue
+		}
+		tagName := strings.Split(tag, ",")[0]
+		switch tagName {
+		case "-":
+			return "", true
+		case "":
+			// only options, the next tag or the Go name names it
+			continue
+		default:
+			return tagName, true
+		}
+	}
+	return field.Name, false
+}
+
+// structFieldOmitEmpty reports whether the tag naming field, see
+// structFieldName, has the omitempty option.
+func structFieldOmitEmpty(field reflect.StructField) bool {
+	for _, tagKey := range []string{"firestore", "json"} {
+		tag, ok := field.Tag.Lookup(tagKey)
+		if !ok {
+			continue
+		}
+		return lo.Contains(strings.Split(tag, ",")[1:], "omitempty")
+	}
+	return false
+}
+
+// isEmptyValue is the omitempty emptiness of encoding/json and Firestore.
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return rv.IsNil()
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// keepAsIs reports types the Firestore client encodes itself.
+func keepAsIs(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	return rt == timeType ||
+		strings.HasPrefix(rt.PkgPath(), "cloud.google.com/go/firestore") ||
+		strings.HasPrefix(rt.PkgPath(), "google.golang.org/genproto/googleapis/type/latlng")
+}
+
+func toDocValue(rv reflect.Value, path string) (any, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil() {
+		return nil, nil
+	}
+	if c, ok := lookupConverter(rv.Type()); ok && c.toFirestore != nil {
+		val, err := c.toFirestore(rv.Interface())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("field %s: %v", path, err))
+		}
+		return val, nil
+	}
+	if keepAsIs(rv.Type()) {
+		return rv.Interface(), nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return toDocValue(rv.Elem(), path)
+	case reflect.Struct:
+		out := make(map[string]any)
+		if err := structFieldsToMap(rv, path+".", out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.New(fmt.Sprintf("field %s: unsupported map key type %s", path, rv.Type().Key()))
+		}
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			val, err := toDocValue(iter.Value(), path+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = val
+		}
+		return out, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.Kind() == reflect.Slice {
+				return rv.Bytes(), nil
+			}
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return b, nil
+		}
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		out := make([]any, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			val, err := toDocValue(rv.Index(i), fmt.Sprintf("%s.%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = val
+		}
+		return out, nil
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return nil, errors.New(fmt.Sprintf("field %s: unsupported type %s", path, rv.Type()))
+	default:
+		return rv.Interface(), nil
 	}
-	return inInterface
 }
 
 func FilterDocs(docs []map[string]any, filter func(doc map[string]any) bool) []map[string]any {
@@ -61,6 +291,112 @@ func TransformsDocs(docs []map[string]any, transform func(doc map[string]any) ma
 	return transformed
 }
 
+// TransformsDocsParallel is TransformsDocs running transform on up to
+// workers docs at a time, keeping the order of docs. A panicking transform
+// is returned as an error.
+func TransformsDocsParallel(docs []map[string]any, transform func(doc map[string]any) map[string]any, workers int) ([]map[string]any, error) {
+	return TransformsDocsParallelContext(context.Background(), docs, transform, workers)
+}
+
+// TransformsDocsParallelContext is TransformsDocsParallel stopping early with
+// the ctx error when ctx is done.
+func TransformsDocsParallelContext(ctx context.Context, docs []map[string]any, transform func(doc map[string]any) map[string]any, workers int) ([]map[string]any, error) {
+	transformed := make([]map[string]any, len(docs))
+	err := eachDocParallel(ctx, len(docs), workers, func(i int) {
+		transformed[i] = transform(docs[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transformed, nil
+}
+
+// FilterDocsParallel is FilterDocs running filter on up to workers docs at a
+// time, keeping the order of docs. A panicking filter is returned as an
+// error.
+func FilterDocsParallel(docs []map[string]any, filter func(doc map[string]any) bool, workers int) ([]map[string]any, error) {
+	return FilterDocsParallelContext(context.Background(), docs, filter, workers)
+}
+
+// FilterDocsParallelContext is FilterDocsParallel stopping early with the
+// ctx error when ctx is done.
+func FilterDocsParallelContext(ctx context.Context, docs []map[string]any, filter func(doc map[string]any) bool, workers int) ([]map[string]any, error) {
+	keep := make([]bool, len(docs))
+	err := eachDocParallel(ctx, len(docs), workers, func(i int) {
+		keep[i] = filter(docs[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(docs, func(_ map[string]any, i int) bool { return keep[i] }), nil
+}
+
+// eachDocParallel calls fn with each index below n on up to workers
+// goroutines, turning panics into errors.
+func eachDocParallel(ctx context.Context, n int, workers int, fn func(i int)) error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(workers, 1))
+	for i := 0; i < n; i++ {
+		if gctx.Err() != nil {
+			break
+		}
+		i := i
+		g.Go(func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = errors.New(fmt.Sprintf("doc %d: panic: %v", i, r))
+				}
+			}()
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			fn(i)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// bulkDocID returns the id of a doc of a bulk operation: its _id metadata,
+// which is the Firestore doc id, else its id field, integers being
+// formatted since other tools may store numeric ids.
+func bulkDocID(doc map[string]any) (string, bool) {
+	for _, key := range []string{"_id", IdFieldName} {
+		switch v := doc[key].(type) {
+		case string:
+			if v != "" {
+				return v, true
+			}
+		case int, int32, int64, uint, uint32, uint64:
+			return fmt.Sprint(v), true
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return strconv.FormatInt(int64(v), 10), true
+			}
+		}
+	}
+	return "", false
+}
+
+// SortDocs returns docs sorted by the given "field:asc|desc" orderBys, like
+// a query's orderBy option: fields are dot paths, numbers compare across
+// int and float types, nil and missing values come last and ties keep their
+// order.
+func SortDocs(docs []map[string]any, orderBy ...string) []map[string]any {
+	orderBys := make([]OrderBy, 0, len(orderBy))
+	for _, ob := range orderBy {
+		if parsed := parseOrderBy(ob); parsed != nil && parsed.Field != "" {
+			orderBys = append(orderBys, *parsed)
+		}
+	}
+	sorted := append([]map[string]any{}, docs...)
+	sortDocs(sorted, orderBys)
+	return sorted
+}
+
 // orderBy functions
 
 var DefaultOrderByString = fmt.Sprintf("%s:%s", CreatedAtFieldName, "desc")
@@ -96,7 +432,7 @@ func deepCopyMap(src interface{}) interface{} {
 	case reflect.Map:
 		dstMap := reflect.MakeMap(srcVal.Type())
 		for _, key := range srcVal.MapKeys() {
-			dstMap.SetMapIndex(key, reflect.ValueOf(deepCopyMap(srcVal.MapIndex(key).Interface())))
+			dstMap.SetMapIndex(key, copiedValue(deepCopyMap(srcVal.MapIndex(key).Interface()), srcVal.Type().Elem()))
 		}
 		return dstMap.Interface()
 
@@ -104,7 +440,7 @@ func deepCopyMap(src interface{}) interface{} {
 		dstSlice := reflect.MakeSlice(srcVal.Type(), srcVal.Len(), srcVal.Cap())
 		reflect.Copy(dstSlice, srcVal)
 		for i := 0; i < srcVal.Len(); i++ {
-			dstSlice.Index(i).Set(reflect.ValueOf(deepCopyMap(srcVal.Index(i).Interface())))
+			dstSlice.Index(i).Set(copiedValue(deepCopyMap(srcVal.Index(i).Interface()), srcVal.Type().Elem()))
 		}
 		return dstSlice.Interface()
 
@@ -113,6 +449,201 @@ func deepCopyMap(src interface{}) interface{} {
 	}
 }
 
+// copiedValue keeps nil elements, which reflect.ValueOf turns into an
+// invalid Value.
+func copiedValue(v any, elemType reflect.Type) reflect.Value {
+	if v == nil {
+		return reflect.Zero(elemType)
+	}
+	return reflect.ValueOf(v)
+}
+
 func debug(msg ...any) {
 	color.Yellow("CFFIRESTORE DEBUG: %v", msg)
 }
+
+// compareValues orders numbers, strings, times and bools; ok is false when
+// the values are not comparable with each other.
+func compareValues(a, b any) (int, bool) {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return ta.Compare(tb), true
+	}
+	if fa, ok := toFloat64(a); ok {
+		fb, ok := toFloat64(b)
+		if !ok {
+			return 0, false
+		}
+		return cmp.Compare(fa, fb), true
+	}
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(sa, sb), true
+	}
+	if ba, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case ba == bb:
+			return 0, true
+		case !ba:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// GetPathAny walks a dot path through nested maps and slices (numeric
+// segments index slices) and returns the value found there.
+func GetPathAny(doc map[string]any, path string) (any, bool) {
+	var cur any = doc
+	for _, seg := range strings.Split(path, ".") {
+		rv := reflect.ValueOf(cur)
+		switch rv.Kind() {
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			val := rv.MapIndex(reflect.ValueOf(seg).Convert(rv.Type().Key()))
+			if !val.IsValid() {
+				return nil, false
+			}
+			cur = val.Interface()
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= rv.Len() {
+				return nil, false
+			}
+			cur = rv.Index(i).Interface()
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// GetPath is GetPathAny with a conversion to T. Numbers convert between
+// integer and float types when no precision is lost, and RFC3339 strings
+// convert to time.Time.
+func GetPath[T any](doc map[string]any, path string) (T, bool) {
+	var zero T
+	val, ok := GetPathAny(doc, path)
+	if !ok || val == nil {
+		return zero, false
+	}
+	if t, ok := val.(T); ok {
+		return t, true
+	}
+	converted, ok := convertValue(val, reflect.TypeOf((*T)(nil)).Elem())
+	if !ok {
+		return zero, false
+	}
+	t, ok := converted.(T)
+	return t, ok
+}
+
+func convertValue(val any, to reflect.Type) (any, bool) {
+	if to == reflect.TypeOf(time.Time{}) {
+		switch v := val.(type) {
+		case *time.Time:
+			if v != nil {
+				return *v, true
+			}
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err == nil {
+				return t, true
+			}
+		}
+		return nil, false
+	}
+	rv := reflect.ValueOf(val)
+	switch to.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			out := reflect.New(to).Elem()
+			if out.OverflowInt(rv.Int()) {
+				return nil, false
+			}
+			out.SetInt(rv.Int())
+			return out.Interface(), true
+		case reflect.Float32, reflect.Float64:
+			f := rv.Float()
+			if f != math.Trunc(f) {
+				return nil, false
+			}
+			out := reflect.New(to).Elem()
+			if out.OverflowInt(int64(f)) {
+				return nil, false
+			}
+			out.SetInt(int64(f))
+			return out.Interface(), true
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := toFloat64(val); ok {
+			return reflect.ValueOf(f).Convert(to).Interface(), true
+		}
+	}
+	if rv.Type().ConvertibleTo(to) && rv.Kind() == to.Kind() {
+		return rv.Convert(to).Interface(), true
+	}
+	return nil, false
+}
+
+// sortDocs stable sorts docs by the given orderBys, nil and missing values
+// last.
+func sortDocs(docs []map[string]any, orderBys []OrderBy) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, ob := range orderBys {
+			field := ob.Field
+			if field == firestore.DocumentID {
+				field = "_id"
+			}
+			a, _ := GetPathAny(docs[i], field)
+			b, _ := GetPathAny(docs[j], field)
+			if a == nil || b == nil {
+				if (a == nil) == (b == nil) {
+					continue
+				}
+				return b == nil
+			}
+			order, ok := compareValues(a, b)
+			if !ok {
+				order = strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+			}
+			if order == 0 {
+				continue
+			}
+			if ob.Direction == firestore.Desc {
+				return order > 0
+			}
+			return order < 0
+		}
+		return false
+	})
+}
@@ -0,0 +1,287 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type roundTripAddress struct {
+	City string `firestore:"city"`
+	Zip  int    `json:"zip"`
+}
+
+type RoundTripBase struct {
+	Kind string `firestore:"kind"`
+}
+
+type roundTripDoc struct {
+	RoundTripBase
+	Name      string            `firestore:"name"`
+	CreatedAt time.Time         `firestore:"createdAt"`
+	Deadline  *time.Time        `firestore:"deadline"`
+	Count     int               `firestore:"count"`
+	Small     int32             `firestore:"small"`
+	Big       int64             `firestore:"big"`
+	Unsigned  uint16            `firestore:"unsigned"`
+	Ratio     float64           `firestore:"ratio"`
+	Blob      []byte            `firestore:"blob"`
+	Address   roundTripAddress  `firestore:"address"`
+	Previous  *roundTripAddress `firestore:"previous"`
+	History   []roundTripAddress
+	Labels    map[string]int `json:"labels"`
+	Skipped   string         `firestore:"-"`
+	Note      string         `firestore:"note,omitempty"`
+	private   string
+}
+
+func TestToDocMapKeepsTypes(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 30, 0, 123000, time.UTC)
+	doc, err := ToDocMap(&roundTripDoc{
+		RoundTripBase: RoundTripBase{Kind: "invoice"},
+		Name:          "John Doe",
+		CreatedAt:     created,
+		Count:         3,
+		Small:         -7,
+		Big:           1 << 53,
+		Unsigned:      9,
+		Ratio:         0.5,
+		Blob:          []byte{0, 1, 2},
+		Address:       roundTripAddress{City: "Hanoi", Zip: 100000},
+		History:       []roundTripAddress{{City: "Hue", Zip: 530000}},
+		Labels:        map[string]int{"a": 1},
+		Skipped:       "skipped",
+		private:       "private",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"kind":      "invoice",
+		"name":      "John Doe",
+		"createdAt": created,
+		"deadline":  nil,
+		"count":     3,
+		"small":     int32(-7),
+		"big":       int64(1 << 53),
+		"unsigned":  uint16(9),
+		"ratio":     0.5,
+		"blob":      []byte{0, 1, 2},
+		"address":   map[string]any{"city": "Hanoi", "zip": 100000},
+		"previous":  nil,
+		"History":   []any{map[string]any{"city": "Hue", "zip": 530000}},
+		"labels":    map[string]any{"a": 1},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("ToDocMap = %#v, want %#v", doc, want)
+	}
+}
+
+func TestToDocMapRoundTrip(t *testing.T) {
+	deadline := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)
+	in := roundTripDoc{
+		RoundTripBase: RoundTripBase{Kind: "invoice"},
+		Name:          "John Doe",
+		CreatedAt:     time.Date(2024, 3, 1, 10, 30, 0, 123000, time.UTC),
+		Deadline:      &deadline,
+		Count:         3,
+		Small:         -7,
+		Big:           1 << 53,
+		Unsigned:      9,
+		Ratio:         0.5,
+		Blob:          []byte("hello"),
+		Address:       roundTripAddress{City: "Hanoi", Zip: 100000},
+		Previous:      &roundTripAddress{City: "Hue", Zip: 530000},
+		History:       []roundTripAddress{{City: "Hue", Zip: 530000}, {City: "Da Nang", Zip: 550000}},
+		Labels:        map[string]int{"a": 1, "b": 2},
+		Note:          "note",
+	}
+	doc, err := ToDocMap(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out roundTripDoc
+	if err := decodeDoc(doc, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %#v, want %#v", out, in)
+	}
+}
+
+func TestDecodeDocFromStoredValues(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	// Firestore reads every integer back as int64.
+	stored := map[string]any{
+		"kind":      "invoice",
+		"createdAt": created,
+		"count":     int64(3),
+		"small":     int64(-7),
+		"big":       int64(1 << 53),
+		"ratio":     int64(2),
+		"blob":      []byte{0, 1},
+		"address":   map[string]any{"city": "Hanoi", "zip": int64(100000)},
+		"History":   []any{map[string]any{"city": "Hue", "zip": int64(530000)}},
+		"labels":    map[string]any{"a": int64(1)},
+		"_id":       "doc1",
+	}
+	var out roundTripDoc
+	if err := decodeDoc(stored, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := roundTripDoc{
+		RoundTripBase: RoundTripBase{Kind: "invoice"},
+		CreatedAt:     created,
+		Count:         3,
+		Small:         -7,
+		Big:           1 << 53,
+		Ratio:         2,
+		Blob:          []byte{0, 1},
+		Address:       roundTripAddress{City: "Hanoi", Zip: 100000},
+		History:       []roundTripAddress{{City: "Hue", Zip: 530000}},
+		Labels:        map[string]int{"a": 1},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("decodeDoc = %#v, want %#v", out, want)
+	}
+}
+
+func TestStructFieldName(t *testing.T) {
+	type tagged struct {
+		Plain      string
+		Firestore  string `firestore:"fs" json:"js1"`
+		JSON       string `json:"js2"`
+		OnlyOpts   string `firestore:",omitempty" json:"js3"`
+		AllOpts    string `firestore:",omitempty" json:",omitempty"`
+		FsOpts     string `firestore:",omitempty"`
+		Skipped    string `firestore:"-" json:"js4"`
+		JSONSkip   string `json:"-"`
+		OptsToSkip string `firestore:",omitempty" json:"-"`
+	}
+	rt := reflect.TypeOf(tagged{})
+	for _, tc := range []struct {
+		field  string
+		name   string
+		tagged bool
+	}{
+		{"Plain", "Plain", false},
+		{"Firestore", "fs", true},
+		{"JSON", "js2", true},
+		{"OnlyOpts", "js3", true},
+		{"AllOpts", "AllOpts", false},
+		{"FsOpts", "FsOpts", false},
+		{"Skipped", "", true},
+		{"JSONSkip", "", true},
+		{"OptsToSkip", "", true},
+	} {
+		field, _ := rt.FieldByName(tc.field)
+		if name, ok := structFieldName(field); name != tc.name || ok != tc.tagged {
+			t.Errorf("structFieldName(%s) = %q, %v, want %q, %v", tc.field, name, ok, tc.name, tc.tagged)
+		}
+	}
+}
+
+func TestToDocMapErrors(t *testing.T) {
+	var nilDoc *roundTripDoc
+	for name, v := range map[string]any{
+		"nil pointer": nilDoc,
+		"not struct":  42,
+		"func field":  struct{ Fn func() }{Fn: func() {}},
+		"int map key": struct{ M map[int]string }{M: map[int]string{1: "a"}},
+	} {
+		if _, err := ToDocMap(v); err == nil {
+			t.Errorf("%s: ToDocMap returned no error", name)
+		}
+	}
+}
+
+func TestBulkDocID(t *testing.T) {
+	for _, tc := range []struct {
+		doc  map[string]any
+		want string
+		ok   bool
+	}{
+		{map[string]any{"_id": "a", IdFieldName: "b"}, "a", true},
+		{map[string]any{"_id": "", IdFieldName: "b"}, "b", true},
+		{map[string]any{IdFieldName: "b"}, "b", true},
+		{map[string]any{IdFieldName: int64(42)}, "42", true},
+		{map[string]any{IdFieldName: 7}, "7", true},
+		{map[string]any{IdFieldName: float64(12)}, "12", true},
+		{map[string]any{IdFieldName: 1.5}, "", false},
+		{map[string]any{IdFieldName: ""}, "", false},
+		{map[string]any{IdFieldName: nil}, "", false},
+		{map[string]any{IdFieldName: true}, "", false},
+		{map[string]any{"name": "x"}, "", false},
+	} {
+		got, ok := bulkDocID(tc.doc)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("bulkDocID(%v) = %q, %v, want %q, %v", tc.doc, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDocResponseSnapshotTimes(t *testing.T) {
+	coll := testCollection(t)
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	snap := &firestore.DocumentSnapshot{
+		Ref:        coll.Ref().Doc("a"),
+		CreateTime: created,
+		UpdateTime: created.Add(time.Minute),
+		ReadTime:   created.Add(time.Hour),
+	}
+	want := map[string]any{"_id": "a", "_ref": snap.Ref.Path}
+	if doc := coll.docResponse(snap); !reflect.DeepEqual(doc, want) {
+		t.Errorf("docResponse = %v, want %v", doc, want)
+	}
+	coll.WithResponseOptions(ResponseOptions{IncludeSnapshotTimes: true})
+	want["_createTime"] = snap.CreateTime
+	want["_updateTime"] = snap.UpdateTime
+	want["_readTime"] = snap.ReadTime
+	if doc := coll.docResponse(snap); !reflect.DeepEqual(doc, want) {
+		t.Errorf("docResponse with IncludeSnapshotTimes = %v, want %v", doc, want)
+	}
+}
+
+func TestGetPath(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	doc := map[string]any{
+		"n":       int64(3),
+		"ratio":   2.5,
+		"created": created.Format(time.RFC3339Nano),
+		"address": map[string]any{"city": "Hanoi"},
+		"tags":    []any{"a", "b"},
+		"stamp":   created,
+	}
+	if n, ok := GetPath[int](doc, "n"); !ok || n != 3 {
+		t.Errorf("GetPath[int](n) = %v, %v", n, ok)
+	}
+	if f, ok := GetPath[float64](doc, "n"); !ok || f != 3 {
+		t.Errorf("GetPath[float64](n) = %v, %v", f, ok)
+	}
+	if _, ok := GetPath[int](doc, "ratio"); ok {
+		t.Error("GetPath[int](ratio) converted 2.5")
+	}
+	if c, ok := GetPath[time.Time](doc, "created"); !ok || !c.Equal(created) {
+		t.Errorf("GetPath[time.Time](created) = %v, %v", c, ok)
+	}
+	if city, ok := GetPath[string](doc, "address.city"); !ok || city != "Hanoi" {
+		t.Errorf("GetPath[string](address.city) = %v, %v", city, ok)
+	}
+	if tag, ok := GetPath[string](doc, "tags.1"); !ok || tag != "b" {
+		t.Errorf("GetPath[string](tags.1) = %v, %v", tag, ok)
+	}
+	if _, ok := GetPath[string](doc, "missing"); ok {
+		t.Error("GetPath[string](missing) found it")
+	}
+	if s, ok := GetPath[fmt.Stringer](doc, "n"); ok || s != nil {
+		t.Errorf("GetPath[fmt.Stringer](n) = %v, %v, want nil, false", s, ok)
+	}
+	if s, ok := GetPath[fmt.Stringer](doc, "stamp"); !ok || s.String() != created.String() {
+		t.Errorf("GetPath[fmt.Stringer](stamp) = %v, %v", s, ok)
+	}
+	if v, ok := GetPath[any](doc, "n"); !ok || v != int64(3) {
+		t.Errorf("GetPath[any](n) = %v, %v", v, ok)
+	}
+}
@@ -0,0 +1,116 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/url"
+	"time"
+)
+
+// IdempotencyKeyTTL is how long AddDocIdempotent remembers a key, expired
+// keys create a new doc and are deleted by PruneIdempotencyKeys.
+var IdempotencyKeyTTL = 24 * time.Hour
+
+const idempotencyExpiresAtFieldName = "expiresAt"
+
+func (coll *Collection) idempotencyColl() *firestore.CollectionRef {
+	return coll.Client.Collection(coll.Path + "__idempotency")
+}
+
+// AddDocIdempotent adds v like AddDoc unless idempotencyKey was already
+// used within IdempotencyKeyTTL, in which case it returns the doc added
+// then. The bool reports whether the doc was created. The key and the doc
+// are written in one transaction, in the "<path>__idempotency" collection.
+func (coll *Collection) AddDocIdempotent(ctx context.Context, idempotencyKey string, uid *string, v map[string]any) (*firestore.DocumentRef, bool, error) {
+	var created bool
+	ref, err := run(ctx, coll, call{Op: "AddDocIdempotent", Data: v}, func(ctx context.Context, c *OperationCall) (ref *firestore.DocumentRef, err error) {
+		ref, created, err = coll.addDocIdempotent(ctx, idempotencyKey, uid, c.Data)
+		return ref, err
+	})
+	return ref, created, err
+}
+
+func (coll *Collection) addDocIdempotent(ctx context.Context, idempotencyKey string, uid *string, v map[string]any) (*firestore.DocumentRef, bool, error) {
+	if idempotencyKey == "" {
+		return nil, false, errors.New("empty idempotency key")
+	}
+	if len(coll.uniqueFields) > 0 {
+		return nil, false, errors.New("AddDocIdempotent does not maintain unique fields, use AddDocWithId")
+	}
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	newRef, stored, err := coll.prepareNewDoc(nil, uid, v, AddOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	keyRef := coll.idempotencyColl().Doc(url.PathEscape(idempotencyKey))
+	var ref *firestore.DocumentRef
+	var created bool
+	err = coll.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		now := coll.now()
+		snap, err := tx.Get(keyRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			expiresAt, _ := snap.DataAt(idempotencyExpiresAtFieldName)
+			if t, ok := expiresAt.(time.Time); ok && t.After(now) {
+				docID, _ := snap.DataAt(uniqueOwnerFieldName)
+				if id, ok := docID.(string); ok {
+					ref, created = coll.ref.Doc(id), false
+					return nil
+				}
+			}
+		}
+		if err := tx.Create(newRef, stored); err != nil {
+			return err
+		}
+		ref, created = newRef, true
+		return tx.Set(keyRef, map[string]any{
+			uniqueOwnerFieldName:          newRef.ID,
+			CreatedAtFieldName:            now,
+			idempotencyExpiresAtFieldName: now.Add(IdempotencyKeyTTL),
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return ref, created, nil
+}
+
+// PruneIdempotencyKeys deletes the expired keys of AddDocIdempotent and
+// returns how many it deleted.
+func (coll *Collection) PruneIdempotencyKeys(ctx context.Context) (int, error) {
+	return run(ctx, coll, call{Op: "PruneIdempotencyKeys"}, func(ctx context.Context, c *OperationCall) (int, error) {
+		ctx, cancel := coll.withTimeout(ctx)
+		defer cancel()
+		iter := coll.idempotencyColl().Where(idempotencyExpiresAtFieldName, "<=", coll.now()).Select().Documents(ctx)
+		defer iter.Stop()
+		batch := coll.Client.BulkWriter(ctx)
+		jobs := &bulkJobs{}
+		var iterErr error
+		for {
+			snap, err := iter.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				iterErr = err
+				break
+			}
+			job, err := batch.Delete(snap.Ref)
+			if err != nil {
+				iterErr = err
+				break
+			}
+			jobs.add(snap.Ref.ID, job)
+		}
+		batch.End()
+		report := jobs.report("delete")
+		return report.Succeeded, errors.Join(iterErr, report.Err())
+	})
+}
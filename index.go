@@ -0,0 +1,163 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IndexField is a field of a composite index, as in firestore.indexes.json.
+type IndexField struct {
+	FieldPath   string `json:"fieldPath"`
+	Order       string `json:"order,omitempty"`
+	ArrayConfig string `json:"arrayConfig,omitempty"`
+}
+
+// CompositeIndex is an index of firestore.indexes.json.
+type CompositeIndex struct {
+	CollectionGroup string       `json:"collectionGroup"`
+	QueryScope      string       `json:"queryScope"`
+	Fields          []IndexField `json:"fields"`
+}
+
+func (idx CompositeIndex) String() string {
+	fields := make([]string, len(idx.Fields))
+	for i, f := range idx.Fields {
+		fields[i] = f.FieldPath + " " + lo.Ternary(f.ArrayConfig != "", f.ArrayConfig, f.Order)
+	}
+	return fmt.Sprintf("%s (%s)", idx.CollectionGroup, strings.Join(fields, ", "))
+}
+
+// IndexRecorder collects the composite indexes the queries of collections
+// using it need, see WithIndexRecorder.
+type IndexRecorder struct {
+	mu      sync.Mutex
+	indexes map[string]CompositeIndex
+}
+
+func NewIndexRecorder() *IndexRecorder {
+	return &IndexRecorder{indexes: make(map[string]CompositeIndex)}
+}
+
+// WithIndexRecorder records the index every query of the collection needs.
+func (coll *Collection) WithIndexRecorder(r *IndexRecorder) *Collection {
+	coll.indexRecorder = r
+	return coll
+}
+
+func (r *IndexRecorder) record(idx CompositeIndex) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.indexes[idx.String()] = idx
+}
+
+// Indexes returns the recorded indexes, de-duplicated.
+func (r *IndexRecorder) Indexes() []CompositeIndex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := lo.Keys(r.indexes)
+	sort.Strings(keys)
+	return lo.Map(keys, func(key string, _ int) CompositeIndex { return r.indexes[key] })
+}
+
+// IndexesJSON returns the recorded indexes in the firestore.indexes.json
+// format.
+func (r *IndexRecorder) IndexesJSON() ([]byte, error) {
+	return json.MarshalIndent(map[string]any{
+		"indexes":        r.Indexes(),
+		"fieldOverrides": []any{},
+	}, "", "  ")
+}
+
+// compositeIndex returns the index a query planned as plan needs, false
+// when single field indexes serve it.
+func (coll *Collection) compositeIndex(plan *queryPlan) (CompositeIndex, bool) {
+	if len(plan.filters) > 0 {
+		// Or needs an index per disjunction, which is not worked out
+		return CompositeIndex{}, false
+	}
+	idx := CompositeIndex{CollectionGroup: coll.ref.ID, QueryScope: "COLLECTION"}
+	seen := make(map[string]bool)
+	equalities := make([]string, 0)
+	ranges := make([]string, 0)
+	var arrayField string
+	for _, w := range plan.wheres {
+		switch strings.ToLower(w.Op) {
+		case "==", "in":
+			equalities = append(equalities, w.Path)
+		case "array-contains", "array-contains-any":
+			arrayField = w.Path
+		case "<", "<=", ">", ">=", "!=", "not-in":
+			ranges = append(ranges, w.Path)
+		}
+	}
+	sort.Strings(equalities)
+	for _, field := range lo.Uniq(equalities) {
+		seen[field] = true
+		idx.Fields = append(idx.Fields, IndexField{FieldPath: field, Order: "ASCENDING"})
+	}
+	if arrayField != "" {
+		seen[arrayField] = true
+		idx.Fields = append(idx.Fields, IndexField{FieldPath: arrayField, ArrayConfig: "CONTAINS"})
+	}
+	orderBys, _ := plan.fullOrderBys()
+	// inequalities are ordered by first, as Firestore does implicitly
+	for i := len(ranges) - 1; i >= 0; i-- {
+		if !lo.ContainsBy(orderBys, func(ob OrderBy) bool { return ob.Field == ranges[i] }) {
+			orderBys = append([]OrderBy{{ranges[i], firestore.Asc}}, orderBys...)
+		}
+	}
+	ordered := 0
+	for _, ob := range orderBys {
+		if ob.Field == firestore.DocumentID || seen[ob.Field] {
+			continue
+		}
+		seen[ob.Field] = true
+		ordered++
+		idx.Fields = append(idx.Fields, IndexField{FieldPath: ob.Field, Order: lo.Ternary(ob.Direction == firestore.Desc, "DESCENDING", "ASCENDING")})
+	}
+	if len(idx.Fields) < 2 || (ordered == 0 && arrayField == "") {
+		return idx, false
+	}
+	return idx, true
+}
+
+// MissingIndexError is a FailedPrecondition query error along with the
+// index the query needs.
+type MissingIndexError struct {
+	Index CompositeIndex
+	Err   error
+}
+
+func (e *MissingIndexError) Error() string {
+	return fmt.Sprintf("%v; needs index %s", e.Err, e.Index)
+}
+
+func (e *MissingIndexError) Unwrap() error {
+	return e.Err
+}
+
+// explainIndexErr wraps a FailedPrecondition err of a query on condition
+// into a MissingIndexError.
+func (coll *Collection) explainIndexErr(condition []any, err error) error {
+	var missing *MissingIndexError
+	if err == nil || status.Code(err) != codes.FailedPrecondition || errors.As(err, &missing) {
+		return err
+	}
+	plan, planErr := coll.planQuery(condition)
+	if planErr != nil {
+		return err
+	}
+	idx, ok := coll.compositeIndex(plan)
+	if !ok {
+		return err
+	}
+	return &MissingIndexError{Index: idx, Err: err}
+}
@@ -0,0 +1,175 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"unicode"
+)
+
+// KeywordsFieldName is the array field WithSearchKeywords maintains.
+var KeywordsFieldName = "_keywords"
+
+var DefaultKeywordPrefixLength = 15
+var DefaultMaxKeywords = 500
+
+// KeywordOptions configures the keywords of WithSearchKeywords.
+type KeywordOptions struct {
+	// MinPrefixLength is the shortest prefix stored per word, 0 means 1.
+	MinPrefixLength int
+	// MaxPrefixLength is the longest prefix stored per word, whole words
+	// are always stored. 0 means DefaultKeywordPrefixLength.
+	MaxPrefixLength int
+	// MaxKeywords caps the keywords of a doc, 0 means DefaultMaxKeywords.
+	MaxKeywords int
+}
+
+func (opts KeywordOptions) minPrefix() int {
+	return max(opts.MinPrefixLength, 1)
+}
+
+func (opts KeywordOptions) maxPrefix() int {
+	if opts.MaxPrefixLength > 0 {
+		return opts.MaxPrefixLength
+	}
+	return DefaultKeywordPrefixLength
+}
+
+func (opts KeywordOptions) maxKeywords() int {
+	if opts.MaxKeywords > 0 {
+		return opts.MaxKeywords
+	}
+	return DefaultMaxKeywords
+}
+
+// WithSearchKeywords makes AddDoc, UpdateDoc and BatchDocs maintain the
+// KeywordsFieldName array of the lowercased words of the source fields and
+// their prefixes, which SearchDocs queries. Source fields are dot paths to
+// strings or string arrays.
+func (coll *Collection) WithSearchKeywords(sourceFields []string, opts KeywordOptions) *Collection {
+	coll.keywordFields = lo.Uniq(sourceFields)
+	coll.keywordOptions = opts
+	return coll
+}
+
+// keywordWords splits s into lowercased words of letters and digits.
+func keywordWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchKeywords returns the keywords of the source fields of doc, in word
+// order so the cap drops those of the last words.
+func (coll *Collection) searchKeywords(doc map[string]any) []string {
+	opts := coll.keywordOptions
+	keywords := make([]string, 0)
+	add := func(s string) {
+		for _, word := range keywordWords(s) {
+			runes := []rune(word)
+			for n := opts.minPrefix(); n <= min(len(runes), opts.maxPrefix()); n++ {
+				keywords = append(keywords, string(runes[:n]))
+			}
+			keywords = append(keywords, word)
+		}
+	}
+	for _, field := range coll.keywordFields {
+		val, _ := GetPathAny(doc, field)
+		switch v := val.(type) {
+		case string:
+			add(v)
+		case []string:
+			lo.ForEach(v, func(s string, _ int) { add(s) })
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					add(s)
+				}
+			}
+		}
+	}
+	keywords = lo.Uniq(keywords)
+	return keywords[:min(len(keywords), opts.maxKeywords())]
+}
+
+// setSearchKeywords sets the keywords of a whole doc.
+func (coll *Collection) setSearchKeywords(doc map[string]any) {
+	if len(coll.keywordFields) == 0 {
+		return
+	}
+	doc[KeywordsFieldName] = coll.searchKeywords(doc)
+}
+
+// touchesKeywordFields reports whether the update data sets a source field
+// of the keywords.
+func (coll *Collection) touchesKeywordFields(data map[string]any) bool {
+	return lo.SomeBy(coll.keywordFields, func(field string) bool {
+		_, ok := data[strings.Split(field, ".")[0]]
+		return ok
+	})
+}
+
+// updateSearchKeywords sets the keywords of the doc id as merged with the
+// update data, reading the doc only when data sets a source field.
+func (coll *Collection) updateSearchKeywords(ctx context.Context, id string, data map[string]any) error {
+	if !coll.touchesKeywordFields(data) {
+		return nil
+	}
+	current := map[string]any{}
+	snap, err := getSnap(ctx, coll.ref.Doc(id))
+	if err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+	if err == nil {
+		current = snap.Data()
+		if err := coll.decryptFields(current); err != nil {
+			return err
+		}
+	}
+	merged := mergeDocMaps(current, deepCopyMap(data).(map[string]any))
+	data[KeywordsFieldName] = coll.searchKeywords(merged)
+	return nil
+}
+
+// SearchDocs lists at most limit docs matching condition whose keywords
+// contain term, see WithSearchKeywords. term is lowercased and cut to the
+// max prefix length, and a term of several words is searched by its
+// longest one. A limit below 1 lists every match.
+func (coll *Collection) SearchDocs(ctx context.Context, term string, condition []any, limit int) ([]map[string]any, error) {
+	return run(ctx, coll, call{Op: "SearchDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		if len(coll.keywordFields) == 0 {
+			return nil, errors.New("no search keywords configured")
+		}
+		words := keywordWords(term)
+		if len(words) == 0 {
+			return nil, errors.New(fmt.Sprintf("search term %q has no words", term))
+		}
+		word := []rune(lo.MaxBy(words, func(a, b string) bool { return len(a) > len(b) }))
+		if len(word) > coll.keywordOptions.maxPrefix() {
+			word = word[:coll.keywordOptions.maxPrefix()]
+		}
+		condition := append([]any{[]any{KeywordsFieldName, "array-contains", string(word)}}, copyCondition(c.Condition)...)
+		if limit > 0 {
+			condition = withOptions(condition, map[string]any{"limit": limit})
+		}
+		return coll.listDocs(ctx, condition)
+	})
+}
+
+// BackfillSearchKeywords writes the keywords of every doc matching
+// condition, for docs created before WithSearchKeywords was set.
+func (coll *Collection) BackfillSearchKeywords(condition []any) ([]*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "BackfillSearchKeywords", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]*firestore.WriteResult, error) {
+		if len(coll.keywordFields) == 0 {
+			return nil, errors.New("no search keywords configured")
+		}
+		return coll.batchDocs(ctx, c.Condition, func(doc map[string]any) map[string]any {
+			return doc
+		})
+	})
+}
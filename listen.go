@@ -0,0 +1,117 @@
+package cffirestore
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+var (
+	listenRetryMin = 500 * time.Millisecond
+	listenRetryMax = 30 * time.Second
+)
+
+// backoff doubles the wait after each failure, up to listenRetryMax.
+type backoff struct {
+	wait time.Duration
+}
+
+func (b *backoff) next() time.Duration {
+	if b.wait == 0 {
+		b.wait = listenRetryMin
+	} else if b.wait *= 2; b.wait > listenRetryMax {
+		b.wait = listenRetryMax
+	}
+	return b.wait
+}
+
+func (b *backoff) reset() {
+	b.wait = 0
+}
+
+// sleep waits d, returning false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.ResourceExhausted, codes.Aborted, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// ListenDoc calls fn with doc id every time it changes, starting with its
+// current state; exists is false while the doc is missing. It returns nil
+// when ctx is done, or fn's error. Transient stream errors are logged and
+// retried with backoff.
+func (coll *Collection) ListenDoc(ctx context.Context, id string, fn func(doc map[string]any, exists bool) error) error {
+	_, err := run(ctx, coll, call{Op: "ListenDoc", DocID: id}, func(ctx context.Context, c *OperationCall) (any, error) {
+		return nil, coll.listenDoc(ctx, c.DocID, fn)
+	})
+	return err
+}
+
+func (coll *Collection) listenDoc(ctx context.Context, id string, fn func(doc map[string]any, exists bool) error) error {
+	retry := &backoff{}
+	for {
+		iter := coll.ref.Doc(id).Snapshots(ctx)
+		err := func() error {
+			defer iter.Stop()
+			for {
+				snap, err := iter.Next()
+				if err != nil {
+					return err
+				}
+				retry.reset()
+				if !snap.Exists() {
+					if err := fn(nil, false); err != nil {
+						return &callbackError{err}
+					}
+					continue
+				}
+				doc := coll.docResponse(snap)
+				if err := coll.decryptFields(doc); err != nil {
+					return &callbackError{err}
+				}
+				if err := fn(coll.shapeDoc(doc), true); err != nil {
+					return &callbackError{err}
+				}
+			}
+		}()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if cbErr, ok := err.(*callbackError); ok {
+			return cbErr.err
+		}
+		if !isTransient(err) {
+			return err
+		}
+		wait := retry.next()
+		coll.logf("listen %s/%s: %v, retrying in %s", coll.Path, id, err, wait)
+		if !sleep(ctx, wait) {
+			return nil
+		}
+	}
+}
+
+// callbackError keeps errors of the listener callback apart from stream
+// errors, which may be retried.
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string {
+	return e.err.Error()
+}
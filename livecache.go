@@ -0,0 +1,180 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"github.com/samber/lo"
+	"sort"
+	"sync"
+)
+
+// LiveDocs holds the docs matching a condition in memory, kept up to date
+// by a snapshot listener, see LiveCache.
+type LiveDocs struct {
+	mu    sync.RWMutex
+	docs  map[string]map[string]any
+	err   error
+	ready chan struct{}
+	done  chan struct{}
+}
+
+// LiveCache lists the docs matching condition then listens to their
+// changes until ctx is done. Transient listener errors are logged and
+// retried with backoff. Meant for small reference collections.
+func (coll *Collection) LiveCache(ctx context.Context, condition []any) (*LiveDocs, error) {
+	return run(ctx, coll, call{Op: "LiveCache", Condition: condition}, func(readCtx context.Context, c *OperationCall) (*LiveDocs, error) {
+		return coll.liveCache(ctx, readCtx, c.Condition)
+	})
+}
+
+// liveCache lists the docs with readCtx then listens with ctx, which
+// outlives the LiveCache call.
+func (coll *Collection) liveCache(ctx context.Context, readCtx context.Context, condition []any) (*LiveDocs, error) {
+	query, err := coll.makeQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := coll.listDocs(readCtx, condition)
+	if err != nil {
+		return nil, err
+	}
+	live := &LiveDocs{
+		docs:  make(map[string]map[string]any, len(docs)),
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	for _, doc := range docs {
+		live.docs[doc["_id"].(string)] = doc
+	}
+	close(live.ready)
+
+	go func() {
+		defer close(live.done)
+		retry := &backoff{}
+		for {
+			err := live.listen(ctx, coll, query, retry)
+			if ctx.Err() != nil {
+				return
+			}
+			if !isTransient(err) {
+				coll.logf("live cache %s: %v, stopped", coll.Path, err)
+				live.mu.Lock()
+				live.err = coll.wrapErr("LiveCache", err)
+				live.mu.Unlock()
+				return
+			}
+			wait := retry.next()
+			coll.logf("live cache %s: %v, retrying in %s", coll.Path, err, wait)
+			if !sleep(ctx, wait) {
+				return
+			}
+		}
+	}()
+	return live, nil
+}
+
+// listen applies the snapshots of query until its stream fails. The first
+// snapshot of a stream replaces the whole set, catching up on changes
+// missed while reconnecting.
+func (live *LiveDocs) listen(ctx context.Context, coll *Collection, query firestore.Query, retry *backoff) error {
+	iter := query.Snapshots(ctx)
+	defer iter.Stop()
+	first := true
+	for {
+		snap, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		retry.reset()
+		if first {
+			first = false
+			snaps, err := snap.Documents.GetAll()
+			if err != nil {
+				return err
+			}
+			docs := make(map[string]map[string]any, len(snaps))
+			for _, s := range snaps {
+				doc, err := live.response(coll, s)
+				if err != nil {
+					return err
+				}
+				docs[s.Ref.ID] = doc
+			}
+			live.mu.Lock()
+			live.docs = docs
+			live.mu.Unlock()
+			continue
+		}
+		for _, change := range snap.Changes {
+			if change.Kind == firestore.DocumentRemoved {
+				live.mu.Lock()
+				delete(live.docs, change.Doc.Ref.ID)
+				live.mu.Unlock()
+				continue
+			}
+			doc, err := live.response(coll, change.Doc)
+			if err != nil {
+				return err
+			}
+			live.mu.Lock()
+			live.docs[change.Doc.Ref.ID] = doc
+			live.mu.Unlock()
+		}
+	}
+}
+
+func (live *LiveDocs) response(coll *Collection, snap *firestore.DocumentSnapshot) (map[string]any, error) {
+	doc := coll.docResponse(snap)
+	if err := coll.decryptFields(doc); err != nil {
+		return nil, docErr(snap.Ref.ID, err)
+	}
+	return coll.shapeDoc(doc), nil
+}
+
+// Ready is closed once the docs are loaded.
+func (live *LiveDocs) Ready() <-chan struct{} {
+	return live.ready
+}
+
+// Done is closed once the listener stopped, see Err.
+func (live *LiveDocs) Done() <-chan struct{} {
+	return live.done
+}
+
+// Err returns the error that stopped the listener, nil when its context
+// ended it.
+func (live *LiveDocs) Err() error {
+	live.mu.RLock()
+	defer live.mu.RUnlock()
+	return live.err
+}
+
+func (live *LiveDocs) Get(id string) (map[string]any, bool) {
+	live.mu.RLock()
+	defer live.mu.RUnlock()
+	doc, ok := live.docs[id]
+	if !ok {
+		return nil, false
+	}
+	return deepCopyMap(doc).(map[string]any), true
+}
+
+// All returns the docs sorted by id.
+func (live *LiveDocs) All() []map[string]any {
+	return live.Find(func(map[string]any) bool { return true })
+}
+
+// Find returns the docs matching predicate sorted by id.
+func (live *LiveDocs) Find(predicate func(doc map[string]any) bool) []map[string]any {
+	live.mu.RLock()
+	defer live.mu.RUnlock()
+	ids := lo.Keys(live.docs)
+	sort.Strings(ids)
+	docs := make([]map[string]any, 0)
+	for _, id := range ids {
+		if predicate(live.docs[id]) {
+			docs = append(docs, deepCopyMap(live.docs[id]).(map[string]any))
+		}
+	}
+	return docs
+}
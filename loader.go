@@ -0,0 +1,122 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"sync"
+	"time"
+)
+
+// LoaderWait is how long a Loader collects ids before getting them at once.
+var LoaderWait = 2 * time.Millisecond
+
+// Loader batches the GetDoc calls of a request: ids loaded within LoaderWait
+// of each other, or before Flush, are got with one GetAll. Every doc is got
+// once and kept for the Loader lifetime, so make one per request.
+type Loader struct {
+	coll *Collection
+	ctx  context.Context
+
+	mu      sync.Mutex
+	loads   map[string]*load
+	pending []string
+	timer   *time.Timer
+}
+
+type load struct {
+	done chan struct{}
+	doc  map[string]any
+	err  error
+}
+
+func (coll *Collection) NewLoader(ctx context.Context) *Loader {
+	return &Loader{
+		coll:  coll,
+		ctx:   ctx,
+		loads: make(map[string]*load),
+	}
+}
+
+// Load returns doc id, or ErrDocNotFound when it's missing. It's safe to call
+// from several goroutines.
+func (l *Loader) Load(id string) (map[string]any, error) {
+	l.mu.Lock()
+	ld, ok := l.loads[id]
+	if !ok {
+		ld = &load{done: make(chan struct{})}
+		l.loads[id] = ld
+		l.pending = append(l.pending, id)
+		if l.timer == nil {
+			l.timer = time.AfterFunc(LoaderWait, l.Flush)
+		}
+	}
+	l.mu.Unlock()
+
+	<-ld.done
+	if ld.err != nil {
+		return nil, ld.err
+	}
+	return deepCopyMap(ld.doc).(map[string]any), nil
+}
+
+// Flush gets the ids loaded since the last batch without waiting.
+func (l *Loader) Flush() {
+	l.mu.Lock()
+	ids := l.pending
+	l.pending = nil
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	loads := make([]*load, len(ids))
+	l.mu.Lock()
+	for i, id := range ids {
+		loads[i] = l.loads[id]
+	}
+	l.mu.Unlock()
+
+	_, err := run(l.ctx, l.coll, call{Op: "LoadDocs"}, func(ctx context.Context, c *OperationCall) (any, error) {
+		ctx, cancel := l.coll.withTimeout(ctx)
+		defer cancel()
+		refs := make([]*firestore.DocumentRef, len(ids))
+		for i, id := range ids {
+			refs[i] = l.coll.ref.Doc(id)
+		}
+		snaps, err := l.coll.Client.GetAll(ctx, refs)
+		if err != nil {
+			return nil, err
+		}
+		for i, snap := range snaps {
+			if !snap.Exists() {
+				loads[i].err = l.coll.wrapDocErr("LoadDoc", ids[i], docNotFound(ids[i]))
+				continue
+			}
+			doc := l.coll.docResponse(snap)
+			if err := l.coll.decryptFields(doc); err != nil {
+				loads[i].err = l.coll.wrapDocErr("LoadDoc", ids[i], err)
+				continue
+			}
+			loads[i].doc = l.coll.shapeDoc(doc)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		// let later loads retry instead of keeping the error
+		l.mu.Lock()
+		for _, id := range ids {
+			delete(l.loads, id)
+		}
+		l.mu.Unlock()
+	}
+	for _, ld := range loads {
+		if err != nil {
+			ld.err = err
+		}
+		close(ld.done)
+	}
+}
@@ -0,0 +1,32 @@
+package cffirestore
+
+import (
+	"log"
+	"os"
+)
+
+// Logger receives the events worth knowing about that aren't errors, like
+// listener retries. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// DefaultLogger is used by collections without their own logger, nil
+// silences them.
+var DefaultLogger Logger = log.New(os.Stderr, "cffirestore: ", log.LstdFlags)
+
+func (coll *Collection) WithLogger(logger Logger) *Collection {
+	coll.logger = logger
+	return coll
+}
+
+func (coll *Collection) logf(format string, v ...any) {
+	logger := coll.logger
+	if logger == nil {
+		logger = DefaultLogger
+	}
+	if logger == nil {
+		return
+	}
+	logger.Printf(format, v...)
+}
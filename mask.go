@@ -0,0 +1,168 @@
+package cffirestore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Masker returns the masked form of a field value, or false to drop the
+// field from the response. For a slice field it first gets the whole slice,
+// dropping it drops the field, else it masks each element and false drops
+// that element.
+type Masker func(val any) (any, bool)
+
+// MaskPolicy masks doc fields on read. Fields keys are either a dot path from
+// the doc root or a bare field name, matched at any depth including maps
+// inside slices.
+type MaskPolicy struct {
+	Fields map[string]Masker
+}
+
+var MaskRedacted = "***"
+
+// MaskRedact replaces any non nil value with MaskRedacted.
+func MaskRedact(val any) (any, bool) {
+	if val == nil {
+		return nil, true
+	}
+	return MaskRedacted, true
+}
+
+// MaskDrop removes the field.
+func MaskDrop(any) (any, bool) {
+	return nil, false
+}
+
+// MaskPartialEmail keeps the first character of the local part and the
+// domain, "john@example.com" becomes "j***@example.com".
+func MaskPartialEmail(val any) (any, bool) {
+	s, ok := val.(string)
+	if !ok {
+		return MaskRedact(val)
+	}
+	local, domain, found := strings.Cut(s, "@")
+	if !found || local == "" {
+		return MaskRedacted, true
+	}
+	return string([]rune(local)[:1]) + MaskRedacted + "@" + domain, true
+}
+
+// MaskLast4 keeps the last 4 characters, "0901234567" becomes "******4567".
+func MaskLast4(val any) (any, bool) {
+	s, ok := val.(string)
+	if !ok {
+		if val == nil {
+			return nil, true
+		}
+		s = fmt.Sprint(val)
+	}
+	runes := []rune(s)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes)), true
+	}
+	return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:]), true
+}
+
+// MaskDoc returns a masked copy of doc, doc itself is never modified.
+func (p MaskPolicy) MaskDoc(doc map[string]any) map[string]any {
+	if doc == nil {
+		return nil
+	}
+	masked := deepCopyMap(doc).(map[string]any)
+	p.maskMap(masked, "")
+	return masked
+}
+
+func (p MaskPolicy) MaskDocs(docs []map[string]any) []map[string]any {
+	masked := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		masked = append(masked, p.MaskDoc(doc))
+	}
+	return masked
+}
+
+func (p MaskPolicy) masker(path string, key string) (Masker, bool) {
+	if masker, ok := p.Fields[path]; ok {
+		return masker, true
+	}
+	masker, ok := p.Fields[key]
+	return masker, ok
+}
+
+func (p MaskPolicy) maskMap(doc map[string]any, prefix string) {
+	for key, val := range doc {
+		path := prefix + key
+		masker, ok := p.masker(path, key)
+		if !ok {
+			p.maskNested(val, path)
+			continue
+		}
+		if items, isSlice := val.([]any); isSlice {
+			if _, keep := masker(items); !keep {
+				delete(doc, key)
+				continue
+			}
+			kept := make([]any, 0, len(items))
+			for _, item := range items {
+				if masked, keep := masker(item); keep {
+					kept = append(kept, masked)
+				}
+			}
+			doc[key] = kept
+			continue
+		}
+		if masked, keep := masker(val); keep {
+			doc[key] = masked
+		} else {
+			delete(doc, key)
+		}
+	}
+}
+
+func (p MaskPolicy) maskNested(val any, path string) {
+	switch v := val.(type) {
+	case map[string]any:
+		p.maskMap(v, path+".")
+	case []any:
+		for _, item := range v {
+			p.maskNested(item, path)
+		}
+	case []map[string]any:
+		for _, item := range v {
+			p.maskMap(item, path+".")
+		}
+	}
+}
+
+func (coll *Collection) ListDocsMasked(condition []any, policy MaskPolicy) ([]map[string]any, error) {
+	docs, err := coll.ListDocs(condition)
+	if err != nil {
+		return nil, err
+	}
+	return policy.MaskDocs(docs), nil
+}
+
+func (coll *Collection) GetDocMasked(id string, policy MaskPolicy) (map[string]any, error) {
+	doc, err := coll.GetDoc(id)
+	if err != nil {
+		return nil, err
+	}
+	return policy.MaskDoc(doc), nil
+}
+
+func (coll *Collection) FindDocMasked(condition []any, policy MaskPolicy) (map[string]any, error) {
+	doc, err := coll.FindDoc(condition)
+	if err != nil {
+		return nil, err
+	}
+	return policy.MaskDoc(doc), nil
+}
+
+func (coll *Collection) PaginateMasked(condition []any, page int, perPage int, policy MaskPolicy) (map[string]any, error) {
+	result, err := coll.PaginateTyped(condition, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	result.Docs = policy.MaskDocs(result.Docs)
+	return result.toMap(), nil
+}
@@ -0,0 +1,38 @@
+package cffirestore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMaskDoc(t *testing.T) {
+	doc := map[string]any{
+		"email":  "john@example.com",
+		"phone":  "0901234567",
+		"tokens": []any{"a", "b"},
+		"emails": []any{"john@example.com", "jane@example.com"},
+		"contacts": []any{
+			map[string]any{"name": "Jane", "email": "jane@example.com", "tokens": []any{"c"}},
+		},
+	}
+	policy := MaskPolicy{Fields: map[string]Masker{
+		"email":  MaskPartialEmail,
+		"phone":  MaskLast4,
+		"tokens": MaskDrop,
+		"emails": MaskPartialEmail,
+	}}
+	want := map[string]any{
+		"email":  "j***@example.com",
+		"phone":  "******4567",
+		"emails": []any{"j***@example.com", "j***@example.com"},
+		"contacts": []any{
+			map[string]any{"name": "Jane", "email": "j***@example.com"},
+		},
+	}
+	if masked := policy.MaskDoc(doc); !reflect.DeepEqual(masked, want) {
+		t.Errorf("MaskDoc = %v, want %v", masked, want)
+	}
+	if _, ok := doc["tokens"]; !ok || doc["email"] != "john@example.com" {
+		t.Errorf("MaskDoc modified doc: %v", doc)
+	}
+}
@@ -0,0 +1,57 @@
+package cffirestore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrResultTruncated is returned along with the capped docs of a list query
+// that hit the collection max docs, see WithMaxDocsPerQuery.
+var ErrResultTruncated = errors.New("result truncated")
+
+type TruncationMode int
+
+const (
+	// TruncateSilently returns the capped docs without error.
+	TruncateSilently TruncationMode = iota
+	// ReportTruncation returns the capped docs with ErrResultTruncated.
+	ReportTruncation
+)
+
+// WithMaxDocsPerQuery caps list queries without a limit option to n docs,
+// so a missing limit can't read a whole large collection. Counts, pages,
+// ForEachDoc and the other iterating reads are not capped.
+func (coll *Collection) WithMaxDocsPerQuery(n int, mode TruncationMode) *Collection {
+	coll.maxDocs = n
+	coll.truncationMode = mode
+	return coll
+}
+
+// maxDocsCap returns the cap of a list query on condition, 0 when the query
+// has its own limit or the collection no max docs.
+func (coll *Collection) maxDocsCap(condition []any) int {
+	if coll.maxDocs <= 0 {
+		return 0
+	}
+	plan, err := coll.planQuery(condition)
+	if err != nil {
+		return 0
+	}
+	for _, name := range []string{"limit", "limitToLast"} {
+		if _, ok := plan.option(name); ok {
+			return 0
+		}
+	}
+	return coll.maxDocs
+}
+
+// truncateDocs cuts docs to max, reporting it per the truncation mode.
+func (coll *Collection) truncateDocs(docs []map[string]any, max int) ([]map[string]any, error) {
+	if max <= 0 || len(docs) <= max {
+		return docs, nil
+	}
+	if coll.truncationMode == ReportTruncation {
+		return docs[:max], fmt.Errorf("%w: at most %d docs", ErrResultTruncated, max)
+	}
+	return docs[:max], nil
+}
@@ -0,0 +1,546 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// MemoryCollection is an in-memory ICFFSCollection for unit tests. It plans
+// conditions with the same code as MakeQuery and supports the where
+// operators, orderBy, limit, offset and select; cursor options are ignored. Docs
+// are stamped like Collection stamps them, and returned refs only carry an
+// ID and Path.
+type MemoryCollection struct {
+	coll *Collection
+	mu   sync.RWMutex
+	docs map[string]map[string]any
+}
+
+var _ ICFFSCollection = (*MemoryCollection)(nil)
+
+func NewMemoryCollection(path string) *MemoryCollection {
+	return &MemoryCollection{
+		coll: &Collection{Path: path},
+		docs: make(map[string]map[string]any),
+	}
+}
+
+// Config returns the Collection whose settings, like WithFieldTypes or
+// WithNormalizedFields, the fake applies to conditions and writes.
+func (m *MemoryCollection) Config() *Collection {
+	return m.coll
+}
+
+// Ref returns nil, there is no Firestore collection behind the fake.
+func (m *MemoryCollection) Ref() *firestore.CollectionRef {
+	return nil
+}
+
+// MakeQuery returns an empty query, there is no client to build it on.
+func (m *MemoryCollection) MakeQuery(condition []any) firestore.Query {
+	return firestore.Query{}
+}
+
+func (m *MemoryCollection) docRef(id string) *firestore.DocumentRef {
+	return &firestore.DocumentRef{ID: id, Path: m.coll.Path + "/" + id}
+}
+
+func (m *MemoryCollection) response(id string, doc map[string]any) map[string]any {
+	out := deepCopyMap(doc).(map[string]any)
+	out["_id"] = id
+	out["_ref"] = m.docRef(id)
+	return out
+}
+
+func (m *MemoryCollection) AddDocData(v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	return m.AddDoc(nil, v, docIdPrefix...)
+}
+
+func (m *MemoryCollection) AddDoc(uid *string, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	idPrefix := ""
+	if len(docIdPrefix) > 0 {
+		idPrefix = docIdPrefix[0]
+	}
+	id := idPrefix + newMemoryID()
+	return m.AddDocWithId(&id, uid, v)
+}
+
+func (m *MemoryCollection) AddDocWithId(id *string, uid *string, v map[string]any) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	docId := lo.FromPtr(id)
+	if docId == "" {
+		docId = newMemoryID()
+	}
+	if uid != nil {
+		v[UidFieldName] = *uid
+	}
+	now := m.coll.now()
+	v[CreatedAtFieldName] = now
+	v[UpdatedAtFieldName] = now
+	v[DeletedAtFieldName] = nil
+	v[IdFieldName] = docId
+	m.coll.normalizeFields(v)
+	m.coll.setSearchKeywords(v)
+	if err := m.coll.validateDoc(v); err != nil {
+		return nil, nil, m.coll.wrapErr("AddDocWithId", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs[docId] = deepCopyMap(v).(map[string]any)
+	return m.docRef(docId), &firestore.WriteResult{UpdateTime: now}, nil
+}
+
+func (m *MemoryCollection) ListDocs(condition []any) ([]map[string]any, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	docs, err := m.query(condition)
+	if err != nil {
+		return nil, m.coll.wrapErr("ListDocs", err)
+	}
+	return m.coll.shapeDocs(docs), nil
+}
+
+func (m *MemoryCollection) FindDoc(condition []any) (map[string]any, error) {
+	plan, err := m.coll.planQuery(condition)
+	if err != nil {
+		return nil, m.coll.wrapErr("FindDoc", err)
+	}
+	if plan.options == nil {
+		condition = append(condition, map[string]any{})
+	}
+	condition = copyCondition(condition)
+	condition[len(condition)-1].(map[string]any)["limit"] = 1
+	docs, err := m.ListDocs(condition)
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	return docs[0], nil
+}
+
+func (m *MemoryCollection) GetDoc(id string) (map[string]any, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	doc, ok := m.docs[id]
+	if !ok {
+		return nil, m.coll.wrapDocErr("GetDoc", id, docNotFound(id))
+	}
+	return m.coll.shapeDoc(m.response(id, doc)), nil
+}
+
+// UpdateDoc merges data into doc id, creating it when missing like a
+// MergeAll set does.
+func (m *MemoryCollection) UpdateDoc(id string, data map[string]any) (*firestore.WriteResult, error) {
+	data, err := m.coll.protectUpdate(data, false)
+	if err != nil {
+		return nil, m.coll.wrapDocErr("UpdateDoc", id, err)
+	}
+	return m.updateDoc(id, data)
+}
+
+func (m *MemoryCollection) updateDoc(id string, data map[string]any) (*firestore.WriteResult, error) {
+	now := m.coll.now()
+	data[UpdatedAtFieldName] = now
+	m.coll.normalizeFields(data)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc := deepCopyMap(lo.ValueOr(m.docs, id, map[string]any{})).(map[string]any)
+	doc = mergeDocMaps(doc, deepCopyMap(data).(map[string]any))
+	if m.coll.touchesKeywordFields(data) {
+		m.coll.setSearchKeywords(doc)
+	}
+	if err := m.coll.validateDoc(doc); err != nil {
+		return nil, m.coll.wrapDocErr("UpdateDoc", id, err)
+	}
+	m.docs[id] = doc
+	return &firestore.WriteResult{UpdateTime: now}, nil
+}
+
+func (m *MemoryCollection) DeleteDoc(id string, isSoftDelete ...bool) (*firestore.WriteResult, error) {
+	m.mu.Lock()
+	_, ok := m.docs[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, m.coll.wrapDocErr("DeleteDoc", id, docNotFound(id))
+	}
+	if len(isSoftDelete) > 0 && isSoftDelete[0] {
+		return m.updateDoc(id, map[string]any{
+			DeletedAtFieldName: m.coll.now(),
+		})
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs, id)
+	return &firestore.WriteResult{UpdateTime: m.coll.now()}, nil
+}
+
+func (m *MemoryCollection) DeleteDocs(condition []any, isSoftDelete ...bool) ([]*firestore.WriteResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs, err := m.query(condition)
+	if err != nil {
+		return nil, m.coll.wrapErr("DeleteDocs", err)
+	}
+	if len(docs) == 0 {
+		return nil, m.coll.wrapErr("DeleteDocs", errors.New("not found"))
+	}
+	now := m.coll.now()
+	results := make([]*firestore.WriteResult, 0, len(docs))
+	for _, doc := range docs {
+		id := doc["_id"].(string)
+		if len(isSoftDelete) > 0 && isSoftDelete[0] {
+			m.docs[id][DeletedAtFieldName] = now
+			m.docs[id][UpdatedAtFieldName] = now
+		} else {
+			delete(m.docs, id)
+		}
+		results = append(results, &firestore.WriteResult{UpdateTime: now})
+	}
+	return results, nil
+}
+
+// CountDocs counts the docs matching condition, ignoring a trailing options
+// map like Collection.CountDocs.
+func (m *MemoryCollection) CountDocs(condition []any) (int, error) {
+	if len(condition) > 0 {
+		if _, ok := condition[len(condition)-1].(map[string]any); ok {
+			condition = condition[:len(condition)-1]
+		}
+	}
+	if plan, err := m.coll.planQuery(condition); err == nil && len(plan.missing) > 0 {
+		return 0, m.coll.wrapErr("CountDocs", fieldMissingErr(plan.missing[0]))
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	docs, err := m.query(condition)
+	return len(docs), m.coll.wrapErr("CountDocs", err)
+}
+
+func (m *MemoryCollection) paginate(condition []any, page int, perPage int) (*PaginateResult, error) {
+	page, perPage = m.coll.pageBounds(page, perPage)
+	condition = copyCondition(condition)
+	// one more doc than perPage tells whether there is a next page
+	paging := map[string]any{
+		"limit":  perPage + 1,
+		"offset": (page - 1) * perPage,
+	}
+	if last, err := lo.Last(condition); err == nil && reflect.TypeOf(last).Kind() == reflect.Map {
+		condition[len(condition)-1] = lo.Assign(last.(map[string]any), paging)
+	} else {
+		condition = append(condition, paging)
+	}
+	docs, err := m.ListDocs(condition)
+	if err != nil {
+		return nil, err
+	}
+	return &PaginateResult{
+		Docs:    docs[:min(len(docs), perPage)],
+		Page:    page,
+		PerPage: perPage,
+		HasNext: len(docs) > perPage,
+		HasPrev: page > 1,
+	}, nil
+}
+
+func (m *MemoryCollection) Paginate(condition []any, page int, perPage int) (map[string]any, error) {
+	result, err := m.paginate(condition, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return result.toMap(), nil
+}
+
+func (m *MemoryCollection) PaginateWithCount(condition []any, page int, perPage int) (map[string]any, error) {
+	result, err := m.paginate(condition, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	count, err := m.CountDocs(copyCondition(condition))
+	if err != nil {
+		return nil, err
+	}
+	result.setCount(count, false)
+	return result.toMapWithCount(), nil
+}
+
+func (m *MemoryCollection) BatchDocs(condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error) {
+	return m.batchDocs("BatchDocs", condition, batchFn, false)
+}
+
+// BatchDocsSet is like BatchDocs but replaces each changed doc whole, see
+// Collection.BatchDocsSet.
+func (m *MemoryCollection) BatchDocsSet(condition []any, batchFn func(map[string]any) map[string]any) ([]*firestore.WriteResult, error) {
+	return m.batchDocs("BatchDocsSet", condition, batchFn, true)
+}
+
+func (m *MemoryCollection) batchDocs(op string, condition []any, batchFn func(map[string]any) map[string]any, replace bool) ([]*firestore.WriteResult, error) {
+	if err := m.coll.checkBulkOptions(op, condition); err != nil {
+		return nil, m.coll.wrapErr(op, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs, err := m.query(condition)
+	if err != nil {
+		return nil, m.coll.wrapErr(op, err)
+	}
+	if len(docs) == 0 {
+		return nil, m.coll.wrapErr(op, errors.New("no docs to batch"))
+	}
+	batchFn = m.coll.normalizingBatchFn(batchFn)
+	now := m.coll.now()
+	errs := make([]error, 0)
+	results := make([]*firestore.WriteResult, 0)
+	for _, doc := range docs {
+		id := doc["_id"].(string)
+		stored := m.docs[id]
+		afterDoc := transformDoc(stored, batchFn)
+		updateData := makeUpdateData(stored, afterDoc)
+		if len(updateData) == 0 {
+			continue
+		}
+		if err := m.coll.validateDoc(afterDoc); err != nil {
+			if !m.coll.schemaOptions.SkipInvalidBatchDocs {
+				errs = append(errs, docErr(id, err))
+			}
+			continue
+		}
+		if replace {
+			dropMetadata(afterDoc)
+			for _, key := range []string{IdFieldName, CreatedAtFieldName} {
+				if val, ok := stored[key]; ok {
+					afterDoc[key] = val
+				} else {
+					delete(afterDoc, key)
+				}
+			}
+			stored = afterDoc
+			m.docs[id] = stored
+		} else {
+			for _, update := range updateData {
+				stored[update.Path] = update.Value
+			}
+		}
+		stored[UpdatedAtFieldName] = now
+		results = append(results, &firestore.WriteResult{UpdateTime: now})
+	}
+	return results, m.coll.wrapErr(op, errors.Join(errs...))
+}
+
+func (m *MemoryCollection) CheckExists(condition []any) (bool, error) {
+	docs, err := m.ListDocs(condition)
+	if err != nil {
+		return false, err
+	}
+	return len(docs) > 0, nil
+}
+
+// query returns the unshaped responses of the docs matching condition, m.mu
+// must be held.
+func (m *MemoryCollection) query(condition []any) ([]map[string]any, error) {
+	plan, err := m.coll.planQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	orderBys, err := plan.fullOrderBys()
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.notNull) > 0 {
+		if err := plan.checkNotNull(orderBys); err != nil {
+			return nil, err
+		}
+	}
+	docs := make([]map[string]any, 0)
+	for _, id := range lo.Keys(m.docs) {
+		doc := m.docs[id]
+		if !memoryMatches(id, doc, plan.wheres) || !lo.EveryBy(plan.filters, func(node filterNode) bool {
+			return memoryFilterMatches(id, doc, node)
+		}) {
+			continue
+		}
+		if lo.SomeBy(plan.notNull, func(field string) bool {
+			val, _ := GetPathAny(doc, field)
+			return val == nil
+		}) {
+			continue
+		}
+		// Firestore leaves out docs missing an orderBy field.
+		if lo.SomeBy(orderBys, func(ob OrderBy) bool {
+			if ob.Field == firestore.DocumentID {
+				return false
+			}
+			_, ok := GetPathAny(doc, ob.Field)
+			return !ok
+		}) {
+			continue
+		}
+		docs = append(docs, m.response(id, doc))
+	}
+	sortDocs(docs, append(orderBys, OrderBy{"_id", firestore.Asc}))
+	if val, ok := plan.option("select"); ok {
+		fields, err := selectFields(val)
+		if err != nil {
+			return nil, err
+		}
+		docs = lo.Map(docs, func(doc map[string]any, _ int) map[string]any {
+			return selectDoc(doc, fields)
+		})
+	}
+
+	if val, ok := plan.option("offset"); ok {
+		offset, err := intOption("offset", val)
+		if err != nil {
+			return nil, err
+		}
+		docs = docs[min(offset, len(docs)):]
+	}
+	if val, ok := plan.option("limit"); ok {
+		limit, err := intOption("limit", val)
+		if err != nil {
+			return nil, err
+		}
+		if limit < len(docs) {
+			docs = docs[:limit]
+		}
+	}
+	// like Collection, after the limit
+	docs = lo.Filter(docs, func(doc map[string]any, _ int) bool { return plan.keep(doc) })
+	return docs, nil
+}
+
+// selectDoc keeps the metadata and the fields of doc, which may be dot
+// paths, like a query with a select option.
+func selectDoc(doc map[string]any, fields []string) map[string]any {
+	out := lo.PickBy(doc, func(key string, _ any) bool { return isMetadataKey(key) })
+	for _, field := range fields {
+		val, ok := GetPathAny(doc, field)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(field, ".")
+		parent := out
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := parent[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				parent[part] = child
+			}
+			parent = child
+		}
+		parent[parts[len(parts)-1]] = val
+	}
+	return out
+}
+
+func memoryFilterMatches(id string, doc map[string]any, node filterNode) bool {
+	matches := lo.Map(node.wheres, func(w whereClause, _ int) bool {
+		return memoryMatches(id, doc, []whereClause{w})
+	})
+	for _, child := range node.children {
+		matches = append(matches, memoryFilterMatches(id, doc, child))
+	}
+	if node.or {
+		return lo.Contains(matches, true)
+	}
+	return !lo.Contains(matches, false)
+}
+
+func memoryMatches(id string, doc map[string]any, wheres []whereClause) bool {
+	for _, w := range wheres {
+		if w.Path == DocumentID {
+			if !memoryMatch(id, true, w.Op, memoryDocID(w.Value)) {
+				return false
+			}
+			continue
+		}
+		val, exists := GetPathAny(doc, w.Path)
+		if !memoryMatch(val, exists, w.Op, w.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func memoryMatch(val any, exists bool, op string, operand any) bool {
+	if !exists {
+		return false
+	}
+	switch strings.ToLower(op) {
+	case "==":
+		return memoryEqual(val, operand)
+	case "!=":
+		return val != nil && !memoryEqual(val, operand)
+	case "<", "<=", ">", ">=":
+		order, ok := compareValues(val, operand)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "<":
+			return order < 0
+		case "<=":
+			return order <= 0
+		case ">":
+			return order > 0
+		default:
+			return order >= 0
+		}
+	case "in":
+		return lo.SomeBy(toAnySlice(operand), func(item any) bool { return memoryEqual(val, item) })
+	case "not-in":
+		return val != nil && !lo.SomeBy(toAnySlice(operand), func(item any) bool { return memoryEqual(val, item) })
+	case "array-contains":
+		return lo.SomeBy(toAnySlice(val), func(item any) bool { return memoryEqual(item, operand) })
+	case "array-contains-any":
+		items := toAnySlice(val)
+		return lo.SomeBy(toAnySlice(operand), func(want any) bool {
+			return lo.SomeBy(items, func(item any) bool { return memoryEqual(item, want) })
+		})
+	default:
+		return false
+	}
+}
+
+func memoryEqual(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if order, ok := compareValues(a, b); ok {
+		return order == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toAnySlice(v any) []any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
+
+const memoryIDChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// newMemoryID returns a random 20 character id like Firestore's auto ids.
+func newMemoryID() string {
+	b := make([]byte, 20)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(memoryIDChars))))
+		if err != nil {
+			panic(fmt.Sprintf("cffirestore: generating id: %v", err))
+		}
+		b[i] = memoryIDChars[n.Int64()]
+	}
+	return string(b)
+}
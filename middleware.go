@@ -0,0 +1,59 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"sync"
+)
+
+// OperationCall describes a public Collection call passing through the
+// middlewares. Data is the doc map being written, if any. The operation runs
+// with the DocID, Condition and Data of the call reaching it, so middlewares
+// may rewrite them, e.g. to scope a condition to a tenant; Method and Path
+// are for reading only.
+type OperationCall struct {
+	Method    string
+	Path      string
+	DocID     string
+	Condition []any
+	Data      map[string]any
+}
+
+// Operation executes a call, returning what the Collection method returns
+// along with the error, e.g. *firestore.WriteResult for UpdateDoc.
+type Operation func(ctx context.Context, call *OperationCall) (any, error)
+
+type Middleware func(next Operation) Operation
+
+var (
+	middlewaresMu sync.RWMutex
+	middlewares   = make(map[*firestore.Client][]Middleware)
+)
+
+// Use registers middlewares for every Collection later created from client
+// by CollectionWithPath. They run onion style in registration order: the
+// first registered is the outermost, seeing the call first and the result
+// last. A middleware can short-circuit by returning without calling next;
+// its result must then have the type the method returns, or it's dropped.
+// Watch and LiveCache go through them for their setup and initial read
+// only, the changes they get later don't; ListenDoc does for as long as it
+// listens, and a Loader once per batch, as LoadDocs.
+func Use(client *firestore.Client, mw ...Middleware) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	middlewares[client] = append(middlewares[client], mw...)
+}
+
+func clientMiddlewares(client *firestore.Client) []Middleware {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+	return append([]Middleware{}, middlewares[client]...)
+}
+
+// chain wraps op with mw, the first being the outermost.
+func chain(mw []Middleware, op Operation) Operation {
+	for i := len(mw) - 1; i >= 0; i-- {
+		op = mw[i](op)
+	}
+	return op
+}
@@ -0,0 +1,181 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func recordingMiddleware(name string, log *[]string) Middleware {
+	return func(next Operation) Operation {
+		return func(ctx context.Context, call *OperationCall) (any, error) {
+			*log = append(*log, name+" before "+call.Method)
+			out, err := next(ctx, call)
+			*log = append(*log, name+" after")
+			return out, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	client := testClient(t)
+	var log []string
+	Use(client, recordingMiddleware("first", &log), recordingMiddleware("second", &log))
+	Use(client, recordingMiddleware("third", &log))
+	coll := CollectionWithPath(client, "tests")
+
+	out, err := run(context.Background(), coll, call{Op: "GetDoc", DocID: "a"}, func(ctx context.Context, c *OperationCall) (string, error) {
+		log = append(log, "op")
+		return "done", nil
+	})
+	if err != nil || out != "done" {
+		t.Fatalf("run = %q, %v", out, err)
+	}
+	want := []string{
+		"first before GetDoc",
+		"second before GetDoc",
+		"third before GetDoc",
+		"op",
+		"third after",
+		"second after",
+		"first after",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("log = %q, want %q", log, want)
+	}
+}
+
+func TestMiddlewareOnlyForLaterCollectionsOfClient(t *testing.T) {
+	client := testClient(t)
+	before := CollectionWithPath(client, "tests")
+	var log []string
+	Use(client, recordingMiddleware("mw", &log))
+	other := CollectionWithPath(testClient(t), "tests")
+	for _, coll := range []*Collection{before, other} {
+		if _, err := run(context.Background(), coll, call{Op: "GetDoc"}, func(ctx context.Context, c *OperationCall) (any, error) {
+			return nil, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(log) != 0 {
+		t.Errorf("middleware ran for %q", log)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	client := testClient(t)
+	cached := map[string]any{"_id": "a", "name": "cached"}
+	Use(client, func(next Operation) Operation {
+		return func(ctx context.Context, call *OperationCall) (any, error) {
+			if call.Method == "GetDoc" {
+				return cached, nil
+			}
+			return next(ctx, call)
+		}
+	})
+	doc, err := CollectionWithPath(client, "tests").GetDoc("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, cached) {
+		t.Errorf("GetDoc = %v, want %v", doc, cached)
+	}
+}
+
+func TestMiddlewareWrapsErrors(t *testing.T) {
+	client := testClient(t)
+	errDenied := errors.New("denied")
+	Use(client, func(next Operation) Operation {
+		return func(ctx context.Context, call *OperationCall) (any, error) {
+			return nil, fmt.Errorf("tenant check: %w", errDenied)
+		}
+	})
+	_, err := CollectionWithPath(client, "tests").GetDoc("a")
+	if !errors.Is(err, errDenied) {
+		t.Errorf("GetDoc error = %v, want %v", err, errDenied)
+	}
+}
+
+func TestMiddlewareRewritesCall(t *testing.T) {
+	client := testClient(t)
+	Use(client, func(next Operation) Operation {
+		return func(ctx context.Context, call *OperationCall) (any, error) {
+			rewritten := *call
+			rewritten.DocID = "tenant1_" + call.DocID
+			rewritten.Condition = append([]any{[]any{"tenant", "==", "tenant1"}}, call.Condition...)
+			rewritten.Data = map[string]any{"tenant": "tenant1"}
+			return next(ctx, &rewritten)
+		}
+	})
+	coll := CollectionWithPath(client, "tests")
+
+	var got *OperationCall
+	_, err := run(context.Background(), coll, call{
+		Op:        "UpdateDoc",
+		DocID:     "a",
+		Condition: []any{[]any{"status", "==", "open"}},
+		Data:      map[string]any{"name": "x"},
+	}, func(ctx context.Context, c *OperationCall) (any, error) {
+		got = c
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &OperationCall{
+		Method:    "UpdateDoc",
+		Path:      "tests",
+		DocID:     "tenant1_a",
+		Condition: []any{[]any{"tenant", "==", "tenant1"}, []any{"status", "==", "open"}},
+		Data:      map[string]any{"tenant": "tenant1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("op got %+v, want %+v", got, want)
+	}
+}
+
+func TestMiddlewareRewriteReachesCollectionMethods(t *testing.T) {
+	client := testClient(t)
+	Use(client, func(next Operation) Operation {
+		return func(ctx context.Context, call *OperationCall) (any, error) {
+			rewritten := *call
+			rewritten.Condition = []any{42}
+			rewritten.Data = map[string]any{}
+			return next(ctx, &rewritten)
+		}
+	})
+	coll := CollectionWithPath(client, "tests")
+	// both fail before reaching Firestore, on what the middleware passed
+	if _, err := coll.ListDocs([]any{[]any{"status", "==", "open"}}); err == nil || !strings.Contains(err.Error(), "unhandled condition element: int") {
+		t.Errorf("ListDocs error = %v, want the one of the rewritten condition", err)
+	}
+	if _, err := coll.UpdateDoc("a", map[string]any{"name": "x"}); !errors.Is(err, ErrEmptyUpdate) {
+		t.Errorf("UpdateDoc error = %v, want %v", err, ErrEmptyUpdate)
+	}
+}
+
+func TestMiddlewareSeesListeners(t *testing.T) {
+	client := testClient(t)
+	var log []string
+	Use(client, recordingMiddleware("mw", &log))
+	coll := CollectionWithPath(client, "tests")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := coll.Watch(ctx, []any{42}); err == nil || !strings.Contains(err.Error(), "Watch") {
+		t.Errorf("Watch error = %v, want the wrapped condition error", err)
+	}
+	if _, err := coll.LiveCache(ctx, []any{42}); err == nil || !strings.Contains(err.Error(), "LiveCache") {
+		t.Errorf("LiveCache error = %v, want the wrapped condition error", err)
+	}
+	if err := coll.ListenDoc(ctx, "a", func(map[string]any, bool) error { return nil }); err != nil {
+		t.Errorf("ListenDoc with ctx done = %v", err)
+	}
+	want := []string{"mw before Watch", "mw after", "mw before LiveCache", "mw after", "mw before ListenDoc", "mw after"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("log = %q, want %q", log, want)
+	}
+}
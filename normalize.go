@@ -0,0 +1,84 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"strings"
+)
+
+var NormalizedFieldSuffix = "_lower"
+
+// WithNormalizedFields makes writes maintain a lowercased "<field>_lower"
+// copy of each given top level string field, queried by WhereEqualFold.
+func (coll *Collection) WithNormalizedFields(fields ...string) *Collection {
+	coll.normalizedFields = lo.Uniq(append(coll.normalizedFields, fields...))
+	return coll
+}
+
+func (coll *Collection) normalizeFields(v map[string]any) {
+	for _, field := range coll.normalizedFields {
+		val, ok := v[field]
+		if !ok {
+			continue
+		}
+		switch s := val.(type) {
+		case string:
+			v[field+NormalizedFieldSuffix] = strings.ToLower(s)
+		case nil:
+			v[field+NormalizedFieldSuffix] = nil
+		}
+	}
+}
+
+func (coll *Collection) normalizingBatchFn(batchFn func(map[string]any) map[string]any) func(map[string]any) map[string]any {
+	if len(coll.normalizedFields) == 0 && len(coll.keywordFields) == 0 {
+		return batchFn
+	}
+	return func(doc map[string]any) map[string]any {
+		if batchFn != nil {
+			doc = batchFn(doc)
+		}
+		coll.normalizeFields(doc)
+		coll.setSearchKeywords(doc)
+		return doc
+	}
+}
+
+// BackfillNormalizedFields writes the normalized shadow fields of every doc
+// matching condition, for docs created before WithNormalizedFields was set.
+func (coll *Collection) BackfillNormalizedFields(condition []any) ([]*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "BackfillNormalizedFields", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]*firestore.WriteResult, error) {
+		if len(coll.normalizedFields) == 0 {
+			return nil, errors.New("no normalized fields configured")
+		}
+		return coll.batchDocs(ctx, c.Condition, func(doc map[string]any) map[string]any {
+			return doc
+		})
+	})
+}
+
+type equalFoldCondition struct {
+	field string
+	value string
+}
+
+// WhereEqualFold matches field case-insensitively against value through its
+// normalized shadow field, see Collection.WithNormalizedFields.
+func WhereEqualFold(field string, value string) Condition {
+	return equalFoldCondition{field, value}
+}
+
+func (c equalFoldCondition) canonical() []any {
+	return []any{c.field, c.value}
+}
+
+func (c equalFoldCondition) expand(coll *Collection, plan *queryPlan) error {
+	if !lo.Contains(coll.normalizedFields, c.field) {
+		return errors.New(fmt.Sprintf("field %s is not normalized", c.field))
+	}
+	plan.where(c.field+NormalizedFieldSuffix, "==", strings.ToLower(c.value))
+	return nil
+}
@@ -0,0 +1,93 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+)
+
+// call describes a public Collection operation.
+type call struct {
+	Op        string
+	DocID     string
+	Condition []any
+	Data      map[string]any
+}
+
+// run executes a public operation: it passes through the client middlewares,
+// is traced, and its error carries the operation and collection context. fn
+// gets the call as the middlewares left it, and must take its DocID,
+// Condition and Data from there so their rewrites apply.
+func run[T any](ctx context.Context, coll *Collection, c call, fn func(ctx context.Context, c *OperationCall) (T, error)) (T, error) {
+	if coll.appendOnly && !readOps[c.Op] && c.Op != "AppendEvent" {
+		var zero T
+		return zero, coll.wrapErr(c.Op, ErrAppendOnly)
+	}
+	final := &OperationCall{
+		Method:    c.Op,
+		Path:      coll.Path,
+		DocID:     c.DocID,
+		Condition: c.Condition,
+		Data:      c.Data,
+	}
+	op := func(ctx context.Context, oc *OperationCall) (any, error) {
+		final = oc
+		ctx, span := coll.startSpan(ctx, c.Op, oc.DocID, oc.Condition)
+		out, err := fn(ctx, oc)
+		span.End(docCount(out), err)
+		if coll.queryCache != nil && !readOps[c.Op] {
+			coll.queryCache.clear()
+		}
+		return out, err
+	}
+	res, err := chain(coll.middlewares, op)(ctx, final)
+	out, _ := res.(T)
+	if final.Condition != nil {
+		err = coll.explainIndexErr(final.Condition, err)
+	}
+	if final.DocID != "" {
+		return out, coll.wrapDocErr(c.Op, final.DocID, err)
+	}
+	return out, coll.wrapErr(c.Op, err)
+}
+
+// docIDPtr returns DocID as the id argument of the Add methods, nil for
+// none.
+func (c *OperationCall) docIDPtr() *string {
+	if c.DocID == "" {
+		return nil
+	}
+	return &c.DocID
+}
+
+func docCount(out any) int {
+	switch v := out.(type) {
+	case []map[string]any:
+		return len(v)
+	case map[string]any:
+		if v == nil {
+			return 0
+		}
+		return 1
+	case []*firestore.WriteResult:
+		return len(v)
+	case *firestore.WriteResult:
+		if v == nil {
+			return 0
+		}
+		return 1
+	case *PaginateResult:
+		if v == nil {
+			return 0
+		}
+		return len(v.Docs)
+	case *BulkResult:
+		if v == nil {
+			return 0
+		}
+		return v.Succeeded
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
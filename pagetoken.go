@@ -0,0 +1,234 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidPageToken is returned by PaginateWithToken for a token it did
+// not make, or made for a query with another order.
+var ErrInvalidPageToken = fmt.Errorf("%w: invalid page token", ErrInvalidArgument)
+
+type TokenPaginateResult struct {
+	Docs    []map[string]any `json:"docs"`
+	PerPage int              `json:"perPage"`
+	HasNext bool             `json:"hasNext"`
+	// NextPageToken reads the next page, empty on the last one.
+	NextPageToken string `json:"nextPageToken,omitempty"`
+}
+
+func (r *TokenPaginateResult) toMap() map[string]any {
+	m := map[string]any{
+		"docs":    r.Docs,
+		"perPage": r.PerPage,
+		"hasNext": r.HasNext,
+	}
+	if r.NextPageToken != "" {
+		m["nextPageToken"] = r.NextPageToken
+	}
+	return m
+}
+
+// PaginateWithToken reads the page after pageToken, the first page when it
+// is empty, and returns the token of the next one. Pages start after the
+// sort values of the last doc of the previous page instead of skipping an
+// offset, so deep pages cost no more than the first. condition cannot take
+// offset, limitToLast or cursor options.
+func (coll *Collection) PaginateWithToken(condition []any, pageToken string, perPage int) (map[string]any, error) {
+	return coll.PaginateWithTokenContext(context.Background(), condition, pageToken, perPage)
+}
+
+// PaginateWithTokenContext is PaginateWithToken with ctx.
+func (coll *Collection) PaginateWithTokenContext(ctx context.Context, condition []any, pageToken string, perPage int) (map[string]any, error) {
+	result, err := coll.PaginateWithTokenTypedContext(ctx, condition, pageToken, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return result.toMap(), nil
+}
+
+func (coll *Collection) PaginateWithTokenTyped(condition []any, pageToken string, perPage int) (*TokenPaginateResult, error) {
+	return coll.PaginateWithTokenTypedContext(context.Background(), condition, pageToken, perPage)
+}
+
+// PaginateWithTokenTypedContext is PaginateWithTokenTyped with ctx.
+func (coll *Collection) PaginateWithTokenTypedContext(ctx context.Context, condition []any, pageToken string, perPage int) (*TokenPaginateResult, error) {
+	return run(ctx, coll, call{Op: "PaginateWithToken", Condition: condition}, func(ctx context.Context, c *OperationCall) (*TokenPaginateResult, error) {
+		return cachedQuery(coll, "PaginateWithToken", []any{c.Condition, pageToken, perPage}, func() (*TokenPaginateResult, error) {
+			return coll.paginateWithToken(ctx, c.Condition, pageToken, perPage)
+		})
+	})
+}
+
+func (coll *Collection) paginateWithToken(ctx context.Context, condition []any, pageToken string, perPage int) (*TokenPaginateResult, error) {
+	_, perPage = coll.pageBounds(1, perPage)
+	condition = copyCondition(condition)
+	plan, err := coll.planQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"offset", "limitToLast", "startAt", "startAfter", "endAt", "endBefore"} {
+		if _, ok := plan.option(name); ok {
+			return nil, errors.New(fmt.Sprintf("PaginateWithToken does not take the %s option", name))
+		}
+	}
+	orderBys, err := plan.fullOrderBys()
+	if err != nil {
+		return nil, err
+	}
+	if len(orderBys) == 0 {
+		orderBys = []OrderBy{{DocumentID, firestore.Asc}}
+		condition = withOptions(condition, map[string]any{"orderBy": orderBys})
+	}
+	// one more doc than perPage tells whether there is a next page
+	query, plan, err := coll.makeFilteredQuery(withOptions(condition, map[string]any{"limit": perPage + 1}))
+	if err != nil {
+		return nil, err
+	}
+	if pageToken != "" {
+		after, err := coll.decodePageToken(pageToken, orderBys)
+		if err != nil {
+			return nil, err
+		}
+		if after, err = coll.docIDCursor(orderBys, after); err != nil {
+			return nil, err
+		}
+		// set last, so it replaces the cursor of NotNull conditions
+		query = query.StartAfter(after...)
+	}
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	snaps, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	docs, err := coll.decryptDocs(coll.docResponses(snaps))
+	if err != nil {
+		return nil, err
+	}
+	result := &TokenPaginateResult{
+		PerPage: perPage,
+		HasNext: len(docs) > perPage,
+	}
+	docs = docs[:min(len(docs), perPage)]
+	if result.HasNext {
+		if result.NextPageToken, err = encodePageToken(docs[len(docs)-1], orderBys); err != nil {
+			return nil, err
+		}
+	}
+	// the token follows the docs read, kept or not
+	docs = lo.Filter(docs, func(doc map[string]any, _ int) bool { return plan.keep(doc) })
+	result.Docs = coll.shapeDocs(docs)
+	return result, nil
+}
+
+// pageToken is the order of the query, to reject a token of another query,
+// and the sort values of the last doc of a page, typed so they decode as
+// they were read.
+type pageToken struct {
+	Order  []string     `json:"o"`
+	Values []tokenValue `json:"v"`
+}
+
+type tokenValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+func encodePageToken(doc map[string]any, orderBys []OrderBy) (string, error) {
+	token := pageToken{}
+	for _, orderBy := range orderBys {
+		token.Order = append(token.Order, orderByKey(orderBy))
+		var val any
+		if orderBy.Field == DocumentID {
+			val = doc["_id"]
+		} else {
+			val, _ = GetPathAny(doc, orderBy.Field)
+		}
+		tv, err := encodeTokenValue(val)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("orderBy %s: %v", orderBy.Field, err))
+		}
+		token.Values = append(token.Values, tv)
+	}
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (coll *Collection) decodePageToken(s string, orderBys []OrderBy) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var token pageToken
+	if err := json.Unmarshal(b, &token); err != nil || len(token.Order) != len(orderBys) || len(token.Values) != len(orderBys) {
+		return nil, ErrInvalidPageToken
+	}
+	vals := make([]any, len(orderBys))
+	for i, orderBy := range orderBys {
+		if token.Order[i] != orderByKey(orderBy) {
+			return nil, ErrInvalidPageToken
+		}
+		if vals[i], err = coll.decodeTokenValue(token.Values[i]); err != nil {
+			return nil, ErrInvalidPageToken
+		}
+	}
+	return vals, nil
+}
+
+func orderByKey(orderBy OrderBy) string {
+	if orderBy.Direction == firestore.Desc {
+		return orderBy.Field + ":desc"
+	}
+	return orderBy.Field + ":asc"
+}
+
+func encodeTokenValue(val any) (tokenValue, error) {
+	switch v := val.(type) {
+	case nil:
+		return tokenValue{Type: "n"}, nil
+	case bool:
+		return tokenValue{Type: "b", Value: strconv.FormatBool(v)}, nil
+	case int64:
+		return tokenValue{Type: "i", Value: strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return tokenValue{Type: "f", Value: strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	case string:
+		return tokenValue{Type: "s", Value: v}, nil
+	case time.Time:
+		return tokenValue{Type: "t", Value: v.UTC().Format(time.RFC3339Nano)}, nil
+	case *firestore.DocumentRef:
+		return tokenValue{Type: "r", Value: relativeDocPath(v.Path)}, nil
+	}
+	return tokenValue{}, errors.New(fmt.Sprintf("cannot sort pages by a %T value", val))
+}
+
+func (coll *Collection) decodeTokenValue(tv tokenValue) (any, error) {
+	switch tv.Type {
+	case "n":
+		return nil, nil
+	case "b":
+		return strconv.ParseBool(tv.Value)
+	case "i":
+		return strconv.ParseInt(tv.Value, 10, 64)
+	case "f":
+		return strconv.ParseFloat(tv.Value, 64)
+	case "s":
+		return tv.Value, nil
+	case "t":
+		return time.Parse(time.RFC3339Nano, tv.Value)
+	case "r":
+		return coll.Client.Doc(tv.Value), nil
+	}
+	return nil, errors.New(fmt.Sprintf("unknown token value type %s", tv.Type))
+}
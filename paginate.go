@@ -0,0 +1,300 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+	"reflect"
+)
+
+var DefaultPaginatePerPage = 25
+
+// MaxPaginatePerPage caps the perPage of paginated reads, 0 disables the cap.
+// WithMaxPerPage overrides it per collection.
+var MaxPaginatePerPage = 100
+
+// WithMaxPerPage caps the perPage of the collection paginated reads instead
+// of MaxPaginatePerPage.
+func (coll *Collection) WithMaxPerPage(n int) *Collection {
+	coll.maxPerPage = n
+	return coll
+}
+
+// WithMaxPaginateCount makes PaginateWithCount count at most n docs, see
+// CountDocsUpTo, so totalPage stays cheap on huge results.
+func (coll *Collection) WithMaxPaginateCount(n int) *Collection {
+	coll.maxCount = n
+	return coll
+}
+
+// WithConsistentPaginateCount sets whether PaginateWithCount reads the page
+// and the count in one read-only transaction, so both see the same
+// snapshot. It does by default, disabling it saves the transaction round
+// trips.
+func (coll *Collection) WithConsistentPaginateCount(enabled bool) *Collection {
+	coll.inconsistentCount = !enabled
+	return coll
+}
+
+type readTxKey struct{}
+
+// withReadTx makes the doc, page and count reads under ctx go through tx.
+func withReadTx(ctx context.Context, tx *firestore.Transaction) context.Context {
+	return context.WithValue(ctx, readTxKey{}, tx)
+}
+
+func readTx(ctx context.Context) *firestore.Transaction {
+	tx, _ := ctx.Value(readTxKey{}).(*firestore.Transaction)
+	return tx
+}
+
+// isNestedTransaction reports the error of a firestore transaction run
+// within another one, which firestore doesn't export.
+func isNestedTransaction(err error) bool {
+	return err != nil && err.Error() == "firestore: nested transaction"
+}
+
+// getSnap gets ref, through the transaction of ctx if any.
+func getSnap(ctx context.Context, ref *firestore.DocumentRef) (*firestore.DocumentSnapshot, error) {
+	if tx := readTx(ctx); tx != nil {
+		return tx.Get(ref)
+	}
+	return ref.Get(ctx)
+}
+
+type PaginateQueryParams struct {
+	Page    int    `query:"page" form:"page" json:"page"`
+	PerPage int    `query:"perPage" form:"perPage" json:"perPage"`
+	Sort    string `query:"sort" form:"sort" json:"sort"`
+}
+
+type PaginateResult struct {
+	Docs      []map[string]any `json:"docs"`
+	Page      int              `json:"page"`
+	PerPage   int              `json:"perPage"`
+	Count     int              `json:"count"`
+	TotalPage int              `json:"totalPage"`
+	HasNext   bool             `json:"hasNext"`
+	HasPrev   bool             `json:"hasPrev"`
+	// CountCapped reports Count stopped at the collection max count, see
+	// WithMaxPaginateCount.
+	CountCapped bool `json:"countCapped,omitempty"`
+}
+
+// setCount sets the count fields of r, whose HasNext was set from the docs
+// read. A capped count is only a lower bound, so it leaves HasNext as read.
+func (r *PaginateResult) setCount(count int, capped bool) {
+	r.Count = count
+	r.CountCapped = capped
+	r.TotalPage = (count + r.PerPage - 1) / r.PerPage
+	if !capped {
+		r.HasNext = r.Page < r.TotalPage
+	}
+}
+
+func (r *PaginateResult) toMap() map[string]any {
+	return map[string]any{
+		"docs":    r.Docs,
+		"page":    r.Page,
+		"perPage": r.PerPage,
+		"hasNext": r.HasNext,
+		"hasPrev": r.HasPrev,
+	}
+}
+
+func (r *PaginateResult) toMapWithCount() map[string]any {
+	m := lo.Assign(r.toMap(), map[string]any{
+		"count":     r.Count,
+		"totalPage": r.TotalPage,
+	})
+	if r.CountCapped {
+		m["countCapped"] = true
+	}
+	return m
+}
+
+func (coll *Collection) Paginate(condition []any, page int, perPage int) (map[string]any, error) {
+	return coll.PaginateContext(context.Background(), condition, page, perPage)
+}
+
+// PaginateContext is Paginate with ctx.
+func (coll *Collection) PaginateContext(ctx context.Context, condition []any, page int, perPage int) (map[string]any, error) {
+	result, err := coll.PaginateTypedContext(ctx, condition, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return result.toMap(), nil
+}
+
+func (coll *Collection) PaginateWithCount(condition []any, page int, perPage int) (map[string]any, error) {
+	return coll.PaginateWithCountContext(context.Background(), condition, page, perPage)
+}
+
+// PaginateWithCountContext is PaginateWithCount with ctx.
+func (coll *Collection) PaginateWithCountContext(ctx context.Context, condition []any, page int, perPage int) (map[string]any, error) {
+	result, err := coll.PaginateWithCountTypedContext(ctx, condition, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return result.toMapWithCount(), nil
+}
+
+// PaginateWithTotal is like PaginateWithCount but trusts a previously known
+// total instead of running the count aggregation again.
+func (coll *Collection) PaginateWithTotal(condition []any, page int, perPage int, total int) (map[string]any, error) {
+	return coll.PaginateWithTotalContext(context.Background(), condition, page, perPage, total)
+}
+
+// PaginateWithTotalContext is PaginateWithTotal with ctx.
+func (coll *Collection) PaginateWithTotalContext(ctx context.Context, condition []any, page int, perPage int, total int) (map[string]any, error) {
+	result, err := run(ctx, coll, call{Op: "PaginateWithTotal", Condition: condition}, func(ctx context.Context, c *OperationCall) (*PaginateResult, error) {
+		return coll.paginateWithCount(ctx, c.Condition, page, perPage, &total)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.toMapWithCount(), nil
+}
+
+func (coll *Collection) PaginateTyped(condition []any, page int, perPage int) (*PaginateResult, error) {
+	return coll.PaginateTypedContext(context.Background(), condition, page, perPage)
+}
+
+// PaginateTypedContext is PaginateTyped with ctx.
+func (coll *Collection) PaginateTypedContext(ctx context.Context, condition []any, page int, perPage int) (*PaginateResult, error) {
+	return run(ctx, coll, call{Op: "Paginate", Condition: condition}, func(ctx context.Context, c *OperationCall) (*PaginateResult, error) {
+		return cachedQuery(coll, "Paginate", []any{c.Condition, page, perPage}, func() (*PaginateResult, error) {
+			return coll.paginate(ctx, c.Condition, page, perPage)
+		})
+	})
+}
+
+func (coll *Collection) PaginateWithCountTyped(condition []any, page int, perPage int) (*PaginateResult, error) {
+	return coll.PaginateWithCountTypedContext(context.Background(), condition, page, perPage)
+}
+
+// PaginateWithCountTypedContext is PaginateWithCountTyped with ctx.
+func (coll *Collection) PaginateWithCountTypedContext(ctx context.Context, condition []any, page int, perPage int) (*PaginateResult, error) {
+	return run(ctx, coll, call{Op: "PaginateWithCount", Condition: condition}, func(ctx context.Context, c *OperationCall) (*PaginateResult, error) {
+		return cachedQuery(coll, "PaginateWithCount", []any{c.Condition, page, perPage}, func() (*PaginateResult, error) {
+			return coll.paginateWithCount(ctx, c.Condition, page, perPage, nil)
+		})
+	})
+}
+
+func (coll *Collection) paginate(ctx context.Context, condition []any, page int, perPage int) (*PaginateResult, error) {
+	page, perPage = coll.pageBounds(page, perPage)
+	// one more doc than perPage tells whether there is a next page
+	paging := map[string]any{
+		"limit":  perPage + 1,
+		"offset": (page - 1) * perPage,
+	}
+	if lastCond, err := lo.Last(condition); err == nil && reflect.TypeOf(lastCond).Kind() == reflect.Map {
+		condition[len(condition)-1] = lo.Assign(lastCond.(map[string]any), paging)
+	} else {
+		condition = append(condition, paging)
+	}
+
+	docs, err := coll.listDocs(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginateResult{
+		Docs:    docs[:min(len(docs), perPage)],
+		Page:    page,
+		PerPage: perPage,
+		HasNext: len(docs) > perPage,
+		HasPrev: page > 1,
+	}, nil
+}
+
+// pageBounds defaults a page below 1 to 1 and a perPage below 1 to
+// DefaultPaginatePerPage, and caps perPage, see WithMaxPerPage.
+func (coll *Collection) pageBounds(page int, perPage int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = DefaultPaginatePerPage
+	}
+	maxPerPage := MaxPaginatePerPage
+	if coll.maxPerPage > 0 {
+		maxPerPage = coll.maxPerPage
+	}
+	if maxPerPage > 0 && perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
+// paginateWithCount runs the page query and the count concurrently, on
+// separate copies of condition, in a read-only transaction unless disabled
+// by WithConsistentPaginateCount. Within another transaction, which
+// firestore can't nest, it reads without one. A non nil knownTotal skips
+// the count.
+func (coll *Collection) paginateWithCount(ctx context.Context, condition []any, page int, perPage int, knownTotal *int) (*PaginateResult, error) {
+	if knownTotal == nil && !coll.inconsistentCount && readTx(ctx) == nil {
+		var result *PaginateResult
+		err := coll.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			var err error
+			result, err = coll.paginateWithCount(withReadTx(ctx, tx), condition, page, perPage, nil)
+			return err
+		}, firestore.ReadOnly)
+		if !isNestedTransaction(err) {
+			return result, err
+		}
+	}
+	page, perPage = coll.pageBounds(page, perPage)
+	var result *PaginateResult
+	var count int
+	var capped bool
+	g, gctx := errgroup.WithContext(ctx)
+	pageCondition := copyCondition(condition)
+	g.Go(func() error {
+		var err error
+		result, err = coll.paginate(gctx, pageCondition, page, perPage)
+		return err
+	})
+	if knownTotal != nil {
+		count = *knownTotal
+	} else {
+		countCondition := copyCondition(condition)
+		g.Go(func() error {
+			var err error
+			if coll.maxCount > 0 {
+				count, capped, err = coll.countDocsUpTo(gctx, countCondition, coll.maxCount)
+			} else {
+				count, err = coll.countDocs(gctx, countCondition)
+			}
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	result.setCount(count, capped)
+	return result, nil
+}
+
+// PaginateAll calls fn with every page of perPage docs matching condition,
+// stopping at the first fn error, which it returns, or when ctx is done.
+// Pages follow each other with cursors instead of offsets, and ties are
+// broken by document ID, so each doc comes once even when writes land
+// between pages, unless they change an orderBy field.
+func (coll *Collection) PaginateAll(ctx context.Context, condition []any, perPage int, fn func(page int, docs []map[string]any) error) error {
+	_, err := run(ctx, coll, call{Op: "PaginateAll", Condition: condition}, func(ctx context.Context, c *OperationCall) (any, error) {
+		_, perPage := coll.pageBounds(1, perPage)
+		page := 0
+		return nil, coll.eachPage(ctx, c.Condition, nil, perPage, func(snaps []*firestore.DocumentSnapshot) error {
+			docs, err := coll.decryptDocs(coll.docResponses(snaps))
+			if err != nil {
+				return err
+			}
+			page++
+			return fn(page, coll.shapeDocs(docs))
+		})
+	})
+	return err
+}
@@ -0,0 +1,121 @@
+package cffirestore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type pageFlags struct {
+	docs    int
+	hasNext bool
+	hasPrev bool
+}
+
+// assertPageFlags checks Paginate and PaginateWithCount agree on the pages
+// of total docs, perPage a page.
+func assertPageFlags(t *testing.T, coll ICFFSReader, total int, perPage int, want []pageFlags) {
+	t.Helper()
+	for i, flags := range want {
+		page := i + 1
+		for name, paginate := range map[string]func([]any, int, int) (map[string]any, error){
+			"Paginate":          coll.Paginate,
+			"PaginateWithCount": coll.PaginateWithCount,
+		} {
+			result, err := paginate(nil, page, perPage)
+			if err != nil {
+				t.Fatalf("%s page %d: %v", name, page, err)
+			}
+			got := pageFlags{len(result["docs"].([]map[string]any)), result["hasNext"].(bool), result["hasPrev"].(bool)}
+			if got != flags {
+				t.Errorf("%d docs, %s page %d of %d = %+v, want %+v", total, name, page, perPage, got, flags)
+			}
+		}
+	}
+}
+
+func addMemoryDocs(t *testing.T, m *MemoryCollection, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("doc%04d", i)
+		if _, _, err := m.AddDocWithId(&id, nil, map[string]any{"n": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryCollectionPageFlags(t *testing.T) {
+	exact := NewMemoryCollection("tests")
+	addMemoryDocs(t, exact, 6)
+	assertPageFlags(t, exact, 6, 3, []pageFlags{
+		{3, true, false},
+		{3, false, true},
+		{0, false, true},
+	})
+
+	partial := NewMemoryCollection("tests")
+	addMemoryDocs(t, partial, 7)
+	assertPageFlags(t, partial, 7, 3, []pageFlags{
+		{3, true, false},
+		{3, true, true},
+		{1, false, true},
+	})
+
+	assertPageFlags(t, NewMemoryCollection("tests"), 0, 3, []pageFlags{{0, false, false}})
+}
+
+func TestPageFlags(t *testing.T) {
+	coll := emulatorCollection(t)
+	seedDocs(t, coll, 6)
+	assertPageFlags(t, coll, 6, 3, []pageFlags{
+		{3, true, false},
+		{3, false, true},
+		{0, false, true},
+	})
+	seedDocs(t, coll, 7)
+	assertPageFlags(t, coll, 7, 3, []pageFlags{
+		{3, true, false},
+		{3, true, true},
+		{1, false, true},
+	})
+}
+
+func TestPaginateWithCountInTransaction(t *testing.T) {
+	coll := emulatorCollection(t)
+	seedDocs(t, coll, 5)
+	err := coll.RunTransaction(context.Background(), func(ctx context.Context, tx *Tx) error {
+		result, err := coll.PaginateWithCountTypedContext(ctx, nil, 1, 2)
+		if err != nil {
+			return err
+		}
+		if result.Count != 5 || len(result.Docs) != 2 || !result.HasNext {
+			t.Errorf("result = %+v", result)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPaginateResultSetCount(t *testing.T) {
+	for _, tc := range []struct {
+		page, count  int
+		capped, read bool
+		totalPage    int
+		hasNext      bool
+	}{
+		{1, 7, false, true, 3, true},
+		{3, 7, false, false, 3, false},
+		{2, 6, false, true, 2, false},
+		{2, 6, true, true, 2, true},
+		{2, 6, true, false, 2, false},
+		{1, 0, false, false, 0, false},
+	} {
+		r := &PaginateResult{Page: tc.page, PerPage: 3, HasNext: tc.read}
+		r.setCount(tc.count, tc.capped)
+		if r.Count != tc.count || r.CountCapped != tc.capped || r.TotalPage != tc.totalPage || r.HasNext != tc.hasNext {
+			t.Errorf("page %d of %d docs, capped %v: %+v, want totalPage %d, hasNext %v", tc.page, tc.count, tc.capped, r, tc.totalPage, tc.hasNext)
+		}
+	}
+}
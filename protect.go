@@ -0,0 +1,53 @@
+package cffirestore
+
+import (
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+)
+
+// ErrEmptyUpdate is returned by updates having no field to write besides
+// updatedAt.
+var ErrEmptyUpdate = errors.New("empty update")
+
+// ErrProtectedField is returned by updates setting id, createdAt or
+// deletedAt when the collection rejects them, see WithProtectedFieldsMode.
+var ErrProtectedField = errors.New("protected field")
+
+type ProtectedFieldsMode int
+
+const (
+	// StripProtectedFields drops the protected fields from update data.
+	StripProtectedFields ProtectedFieldsMode = iota
+	// RejectProtectedFields fails updates setting a protected field.
+	RejectProtectedFields
+)
+
+// WithProtectedFieldsMode sets how updates treat the id, createdAt and
+// deletedAt fields, which are stripped by default. Soft deletes and restores
+// still set deletedAt.
+func (coll *Collection) WithProtectedFieldsMode(mode ProtectedFieldsMode) *Collection {
+	coll.protectedFieldsMode = mode
+	return coll
+}
+
+// protectUpdate returns a copy of data without the protected fields and
+// the metadata keys of responses, or an error. createdAt is left in when preserveTimestamps is set, for backfills.
+func (coll *Collection) protectUpdate(data map[string]any, preserveTimestamps bool) (map[string]any, error) {
+	protected := []string{IdFieldName, CreatedAtFieldName, DeletedAtFieldName}
+	if preserveTimestamps {
+		protected = []string{IdFieldName, DeletedAtFieldName}
+	}
+	for _, field := range protected {
+		if _, ok := data[field]; ok && coll.protectedFieldsMode == RejectProtectedFields {
+			return nil, fmt.Errorf("%w: %s", ErrProtectedField, field)
+		}
+	}
+	data = lo.OmitBy(data, func(key string, _ any) bool {
+		return lo.Contains(protected, key) || isMetadataKey(key)
+	})
+	if len(lo.Without(lo.Keys(data), UpdatedAtFieldName)) == 0 {
+		return nil, ErrEmptyUpdate
+	}
+	return data, nil
+}
@@ -0,0 +1,32 @@
+package cffirestore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProtectUpdate(t *testing.T) {
+	coll := testCollection(t)
+	for _, data := range []map[string]any{
+		{},
+		{UpdatedAtFieldName: "x"},
+		{"_id": "a", "_ref": "tests/a"},
+		{"_id": "a", IdFieldName: "a", CreatedAtFieldName: "x"},
+	} {
+		if _, err := coll.protectUpdate(data, false); !errors.Is(err, ErrEmptyUpdate) {
+			t.Errorf("protectUpdate(%v) error = %v, want %v", data, err, ErrEmptyUpdate)
+		}
+	}
+	data := map[string]any{"_id": "a", "_ref": "tests/a", IdFieldName: "a", "name": "x"}
+	got, err := coll.protectUpdate(data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"name": "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("protectUpdate = %v, want %v", got, want)
+	}
+	if len(data) != 4 {
+		t.Errorf("protectUpdate modified data: %v", data)
+	}
+}
@@ -0,0 +1,636 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Condition is a condition element that MakeQuery expands into one or more
+// where clauses, see Between.
+type Condition interface {
+	expand(coll *Collection, plan *queryPlan) error
+	// canonical returns the values ConditionHash hashes the condition by.
+	canonical() []any
+}
+
+type whereClause struct {
+	Path  string
+	Op    string
+	Value any
+}
+
+type queryPlan struct {
+	wheres      []whereClause
+	filters     []filterNode
+	rangeFields []string
+	notNull     []string
+	// exists are the fields of FieldExists.
+	exists []string
+	// missing are the fields of FieldMissing, filtered after the read.
+	missing []string
+	options map[string]any
+	// ttlField is the field of the TTL filter, see WithTTLField.
+	ttlField string
+	// includeExpired skips the TTL filter, see IncludeExpired.
+	includeExpired bool
+	// includeDeleted skips the soft delete filter, see IncludeDeleted.
+	includeDeleted bool
+}
+
+// filterNode is a composite filter, see Or and And, of its wheres and
+// children.
+type filterNode struct {
+	or       bool
+	wheres   []whereClause
+	children []filterNode
+}
+
+func (plan *queryPlan) where(path string, op string, val any) {
+	plan.wheres = append(plan.wheres, whereClause{path, op, val})
+}
+
+// checkNotNull reports the NotNull conditions the null cursor can't serve.
+func (plan *queryPlan) checkNotNull(orderBys []OrderBy) error {
+	field := plan.notNull[0]
+	if len(lo.Uniq(plan.notNull)) > 1 {
+		return errors.New(fmt.Sprintf("not null %s: only one field per query can be not null", strings.Join(lo.Uniq(plan.notNull), ", ")))
+	}
+	if len(orderBys) == 0 || orderBys[0].Field != field {
+		return errors.New(fmt.Sprintf("not null %s: %s must be the first orderBy, which another inequality or orderBy prevents", field, field))
+	}
+	if len(orderBys) > 1 {
+		return errors.New(fmt.Sprintf("not null %s: cannot be combined with an orderBy on %s", field, orderBys[1].Field))
+	}
+	for _, name := range []string{"startAt", "startAfter", "endAt", "endBefore"} {
+		if _, ok := plan.option(name); ok {
+			return errors.New(fmt.Sprintf("not null %s: cannot be combined with %s", field, name))
+		}
+	}
+	return nil
+}
+
+func (plan *queryPlan) requireOrderBy(field string) {
+	if !lo.Contains(plan.rangeFields, field) {
+		plan.rangeFields = append(plan.rangeFields, field)
+	}
+}
+
+// MakeQuery builds the query of condition. A bad condition is logged, see
+// WithLogger, and gives a query failing when run; use MakeQueryE to get its
+// error instead.
+func (coll *Collection) MakeQuery(condition []any) firestore.Query {
+	query, err := coll.makeQuery(condition)
+	if err != nil {
+		coll.logf("MakeQuery %s: %v", coll.Path, err)
+		// an empty orderBy path only fails once the query is built to run,
+		// so later cursors, which reset query errors, can't clear it
+		return coll.ref.Query.OrderByPath(firestore.FieldPath{}, firestore.Asc)
+	}
+	return query
+}
+
+// MakeQueryE is MakeQuery returning the error of a bad condition.
+func (coll *Collection) MakeQueryE(condition []any) (firestore.Query, error) {
+	return coll.makeQuery(condition)
+}
+
+func (coll *Collection) makeQuery(condition []any) (firestore.Query, error) {
+	if DebugEnabled {
+		debug(coll.ref.Path)
+	}
+	return coll.buildQuery(coll.ref.Query, condition)
+}
+
+// makeFilteredQuery is makeQuery for the reads that keep only the docs its
+// plan keeps, see FieldMissing.
+func (coll *Collection) makeFilteredQuery(condition []any) (firestore.Query, *queryPlan, error) {
+	if DebugEnabled {
+		debug(coll.ref.Path)
+	}
+	plan, err := coll.planQuery(condition)
+	if err != nil {
+		return coll.ref.Query, nil, err
+	}
+	query, err := coll.planToQuery(coll.ref.Query, plan)
+	return query, plan, err
+}
+
+// buildQuery adds condition to query, which may be a collection group one.
+func (coll *Collection) buildQuery(query firestore.Query, condition []any) (firestore.Query, error) {
+	plan, err := coll.planQuery(condition)
+	if err != nil {
+		return query, err
+	}
+	if len(plan.missing) > 0 {
+		return query, fieldMissingErr(plan.missing[0])
+	}
+	return coll.planToQuery(query, plan)
+}
+
+func fieldMissingErr(field string) error {
+	return errors.New(fmt.Sprintf("field missing %s: counts, aggregations and listeners can't filter on it", field))
+}
+
+// keep reports whether doc passes the filters Firestore can't apply.
+func (plan *queryPlan) keep(doc map[string]any) bool {
+	return !lo.SomeBy(plan.missing, func(field string) bool {
+		_, ok := GetPathAny(doc, field)
+		return ok
+	})
+}
+
+// planToQuery adds the clauses and options of plan to query.
+func (coll *Collection) planToQuery(query firestore.Query, plan *queryPlan) (firestore.Query, error) {
+	if coll.indexRecorder != nil {
+		if idx, ok := coll.compositeIndex(plan); ok {
+			coll.indexRecorder.record(idx)
+		}
+	}
+
+	for _, w := range plan.wheres {
+		val, err := coll.whereValue(w)
+		if err != nil {
+			return query, err
+		}
+		if DebugEnabled {
+			debug(w.Path, w.Op, val)
+		}
+		query = query.Where(w.Path, w.Op, val)
+	}
+	for _, node := range plan.filters {
+		filter, err := coll.entityFilter(node)
+		if err != nil {
+			return query, err
+		}
+		query = query.WhereEntity(filter)
+	}
+
+	orderBys, err := plan.fullOrderBys()
+	if err != nil {
+		return query, err
+	}
+	for _, orderBy := range orderBys {
+		query = query.OrderBy(orderBy.Field, orderBy.Direction)
+	}
+
+	for _, key := range sortedKeys(plan.options) {
+		val := plan.options[key]
+		switch strings.ToLower(key) {
+		case "limit", "offset", "limittolast":
+			n, err := intOption(key, val)
+			if err != nil {
+				return query, err
+			}
+			switch strings.ToLower(key) {
+			case "limit":
+				query = query.Limit(n)
+			case "offset":
+				query = query.Offset(n)
+			default:
+				query = query.LimitToLast(n)
+			}
+		case "select":
+			fields, err := selectFields(val)
+			if err != nil {
+				return query, err
+			}
+			query = query.Select(fields...)
+		case "startat", "startafter", "endat", "endbefore":
+			vals, err := coll.docIDCursor(orderBys, cursorValues(val))
+			if err != nil {
+				return query, err
+			}
+			switch strings.ToLower(key) {
+			case "startat":
+				query = query.StartAt(vals...)
+			case "startafter":
+				query = query.StartAfter(vals...)
+			case "endat":
+				query = query.EndAt(vals...)
+			default:
+				query = query.EndBefore(vals...)
+			}
+		}
+	}
+	if len(plan.notNull) > 0 {
+		// Firestore has no "is not null" filter for the Go client, nulls
+		// sort first so a cursor right after them skips them
+		if err := plan.checkNotNull(orderBys); err != nil {
+			return query, err
+		}
+		if orderBys[0].Direction == firestore.Desc {
+			query = query.EndBefore(nil)
+		} else {
+			query = query.StartAfter(nil)
+		}
+	}
+	if DebugEnabled {
+		debug("--------------------")
+	}
+	return query, nil
+}
+
+// whereValue returns the value to query w with.
+func (coll *Collection) whereValue(w whereClause) (any, error) {
+	if coll.isEncryptedField(w.Path) {
+		return nil, errors.New(fmt.Sprintf("field %s is encrypted and cannot be queried", w.Path))
+	}
+	if w.Path == DocumentID {
+		return coll.docIDValue(w.Value)
+	}
+	return w.Value, nil
+}
+
+func (coll *Collection) entityFilter(node filterNode) (firestore.EntityFilter, error) {
+	filters := make([]firestore.EntityFilter, 0, len(node.wheres)+len(node.children))
+	for _, w := range node.wheres {
+		val, err := coll.whereValue(w)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, firestore.PropertyFilter{Path: w.Path, Operator: w.Op, Value: val})
+	}
+	for _, child := range node.children {
+		filter, err := coll.entityFilter(child)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if node.or {
+		return firestore.OrFilter{Filters: filters}, nil
+	}
+	return firestore.AndFilter{Filters: filters}, nil
+}
+
+func (coll *Collection) planQuery(condition []any) (*queryPlan, error) {
+	plan := &queryPlan{}
+	for idx, where := range condition {
+		if err := coll.planElement(plan, where, idx == len(condition)-1); err != nil {
+			return nil, err
+		}
+	}
+	coll.planTTL(plan)
+	coll.planDeleted(plan)
+	if err := plan.checkInequalities(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+var inequalityOps = []string{"<", "<=", ">", ">=", "!=", "not-in"}
+
+// checkInequalities reports the FieldExists, NotNull and TTL filters an
+// inequality on another field prevents: they order on their field first,
+// which Firestore only allows for the field of the inequality.
+func (plan *queryPlan) checkInequalities() error {
+	if len(plan.exists) == 0 && len(plan.notNull) == 0 && plan.ttlField == "" {
+		return nil
+	}
+	wheres := append([]whereClause{}, plan.wheres...)
+	var walk func(nodes []filterNode)
+	walk = func(nodes []filterNode) {
+		for _, node := range nodes {
+			wheres = append(wheres, node.wheres...)
+			walk(node.children)
+		}
+	}
+	walk(plan.filters)
+	for _, w := range wheres {
+		if !lo.Contains(inequalityOps, w.Op) {
+			continue
+		}
+		for _, field := range plan.exists {
+			if w.Path != field {
+				return errors.New(fmt.Sprintf("field exists %s: cannot be combined with the inequality on %s, only one field can have inequalities", field, w.Path))
+			}
+		}
+		for _, field := range plan.notNull {
+			if w.Path != field {
+				return errors.New(fmt.Sprintf("not null %s: cannot be combined with the inequality on %s, only one field can have inequalities", field, w.Path))
+			}
+		}
+		if plan.ttlField != "" && w.Path != plan.ttlField {
+			return errors.New(fmt.Sprintf("ttl %s: cannot be combined with the inequality on %s, add IncludeExpired to the condition", plan.ttlField, w.Path))
+		}
+	}
+	return nil
+}
+
+// planElement adds a condition element to plan, a map being the options
+// when last.
+func (coll *Collection) planElement(plan *queryPlan, where any, last bool) error {
+	if c, ok := where.(Condition); ok {
+		return c.expand(coll, plan)
+	}
+	switch v := reflect.ValueOf(where); v.Kind() {
+	case reflect.Slice:
+		// v = []any{"path", "op", "val"}
+		vSlide := toAnySlice(where)
+		if len(vSlide) != 3 {
+			return errors.New(fmt.Sprintf("condition element %v: want []any{path, op, value}", where))
+		}
+		path, ok := vSlide[0].(string)
+		if !ok {
+			return errors.New(fmt.Sprintf("condition element %v: path must be a string, got %T", where, vSlide[0]))
+		}
+		op, ok := vSlide[1].(string)
+		if !ok {
+			return errors.New(fmt.Sprintf("condition element %v: op must be a string, got %T", where, vSlide[1]))
+		}
+		val, err := normalizeInValue(path, op, unwrapExact(vSlide[2]))
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			return coll.planNil(plan, path, op)
+		}
+		val, err = coll.coerceConditionValue(path, op, val)
+		if err != nil {
+			return err
+		}
+		plan.where(path, op, val)
+	case reflect.Map:
+		vMap, ok := where.(map[string]any)
+		if !ok {
+			return errors.New(fmt.Sprintf("unhandled condition element: %T", where))
+		}
+		if DebugEnabled {
+			debug(vMap)
+		}
+		if !last {
+			return coll.planEquals(plan, "", vMap)
+		}
+		plan.options = vMap
+	default:
+		return errors.New(fmt.Sprintf("unhandled condition element: %T", where))
+	}
+	return nil
+}
+
+// MaxInValues is the most values Firestore takes for in, not-in and
+// array-contains-any.
+const MaxInValues = 30
+
+// normalizeInValue turns the typed slice value of an in, not-in or
+// array-contains-any clause, like []string, into the []any Firestore takes.
+func normalizeInValue(path string, op string, val any) (any, error) {
+	switch strings.ToLower(op) {
+	case "in", "not-in", "array-contains-any":
+	default:
+		return val, nil
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.New(fmt.Sprintf("field %s: %s expects a slice value, got %T", path, op, val))
+	}
+	if rv.Len() == 0 {
+		return nil, errors.New(fmt.Sprintf("field %s: %s expects a non empty slice", path, op))
+	}
+	if rv.Len() > MaxInValues {
+		return nil, errors.New(fmt.Sprintf("field %s: %s takes at most %d values, got %d", path, op, MaxInValues, rv.Len()))
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, nil
+}
+
+// planNil plans a comparison with nil: == matches null fields, and != is
+// planned as NotNull since the Go client can't send it.
+func (coll *Collection) planNil(plan *queryPlan, path string, op string) error {
+	switch op {
+	case "==":
+		plan.where(path, "==", nil)
+		return nil
+	case "!=":
+		return NotNull(path).expand(coll, plan)
+	default:
+		return errors.New(fmt.Sprintf("field %s: nil can only be compared with == or !=, not %s", path, op))
+	}
+}
+
+// Exact marks a map value of an equality map to be matched as a whole
+// instead of being flattened into dot path clauses.
+type Exact struct {
+	Value any
+}
+
+func unwrapExact(val any) any {
+	if exact, ok := val.(Exact); ok {
+		return exact.Value
+	}
+	return val
+}
+
+// OpValue as a value of an equality map compares the field with Op
+// instead of ==, e.g. map[string]any{"status": In("open", "draft")}.
+type OpValue struct {
+	Op    string
+	Value any
+}
+
+func In(values ...any) OpValue {
+	return OpValue{"in", values}
+}
+
+func NotIn(values ...any) OpValue {
+	return OpValue{"not-in", values}
+}
+
+func ArrayContains(val any) OpValue {
+	return OpValue{"array-contains", val}
+}
+
+func ArrayContainsAny(values ...any) OpValue {
+	return OpValue{"array-contains-any", values}
+}
+
+// planEquals adds an equality clause per leaf of m, nested maps become dot
+// paths: {"address": {"city": "Hanoi"}} matches address.city == "Hanoi".
+// An OpValue leaf is compared with its Op.
+func (coll *Collection) planEquals(plan *queryPlan, prefix string, m map[string]any) error {
+	for _, key := range sortedKeys(m) {
+		path := prefix + key
+		if ov, ok := m[key].(OpValue); ok {
+			if err := coll.planElement(plan, []any{path, ov.Op, ov.Value}, false); err != nil {
+				return err
+			}
+			continue
+		}
+		if nested, ok := m[key].(map[string]any); ok && len(nested) > 0 {
+			if err := coll.planEquals(plan, path+".", nested); err != nil {
+				return err
+			}
+			continue
+		}
+		val, err := coll.coerceConditionValue(path, "==", unwrapExact(m[key]))
+		if err != nil {
+			return err
+		}
+		plan.where(path, "==", val)
+	}
+	return nil
+}
+
+func (plan *queryPlan) orderBys() ([]OrderBy, error) {
+	orderBys := make([]OrderBy, 0)
+	add := func(ob string) {
+		orderBy := parseOrderBy(ob)
+		if orderBy != nil && len(orderBy.Field) > 0 {
+			orderBys = append(orderBys, *orderBy)
+		}
+	}
+	for _, key := range sortedKeys(plan.options) {
+		val := plan.options[key]
+		if strings.ToLower(key) != "orderby" {
+			continue
+		}
+		// orderby = string | []string | []any of strings | OrderBy | []OrderBy
+		switch v := val.(type) {
+		case string:
+			add(v)
+		case []string:
+			for _, ob := range v {
+				add(ob)
+			}
+		case []any:
+			for i, ob := range v {
+				s, ok := ob.(string)
+				if !ok {
+					return nil, errors.New(fmt.Sprintf("orderBy element %d must be a string, got %T", i, ob))
+				}
+				add(s)
+			}
+		case OrderBy:
+			orderBys = append(orderBys, v)
+		case []OrderBy:
+			orderBys = append(orderBys, v...)
+		default:
+			return nil, errors.New(fmt.Sprintf("unhandled orderBy value: %T", val))
+		}
+	}
+	return orderBys, nil
+}
+
+// fullOrderBys prepends the orderBys required by range conditions to the
+// ones given in the options map, and ends them with the document ID in the
+// direction of the last one so ties keep the same order between pages. The
+// null cursor of NotNull takes a single value, so its queries are left to
+// the document ID order Firestore breaks ties with anyway.
+func (plan *queryPlan) fullOrderBys() ([]OrderBy, error) {
+	orderBys, err := plan.orderBys()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(plan.rangeFields) - 1; i >= 0; i-- {
+		field := plan.rangeFields[i]
+		if !lo.ContainsBy(orderBys, func(ob OrderBy) bool { return ob.Field == field }) {
+			orderBys = append([]OrderBy{{field, firestore.Asc}}, orderBys...)
+		}
+	}
+	if len(orderBys) > 0 && len(plan.notNull) == 0 && !lo.ContainsBy(orderBys, func(ob OrderBy) bool { return ob.Field == firestore.DocumentID }) {
+		orderBys = append(orderBys, OrderBy{firestore.DocumentID, orderBys[len(orderBys)-1].Direction})
+	}
+	return orderBys, nil
+}
+
+// selectFields reads the select option, a field path or a slice of them,
+// an empty one selecting only the document IDs.
+func selectFields(val any) ([]string, error) {
+	switch v := val.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []any:
+		fields := make([]string, len(v))
+		for i, field := range v {
+			s, ok := field.(string)
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("select element %d must be a string, got %T", i, field))
+			}
+			fields[i] = s
+		}
+		return fields, nil
+	}
+	return nil, errors.New(fmt.Sprintf("unhandled select value: %T", val))
+}
+
+func (plan *queryPlan) option(name string) (any, bool) {
+	for _, key := range sortedKeys(plan.options) {
+		if strings.EqualFold(key, name) {
+			return plan.options[key], true
+		}
+	}
+	return nil, false
+}
+
+// cursorValues spreads a []any cursor option into one value per orderBy,
+// the last being the document ID when ties are broken by it, see
+// fullOrderBys.
+func cursorValues(val any) []any {
+	if vals, ok := val.([]any); ok {
+		return vals
+	}
+	return []any{val}
+}
+
+// intOption reads a non negative integer option value, which may be any
+// integer type or an integral float64 as decoded from JSON.
+func intOption(name string, val any) (int, error) {
+	var n int64
+	switch v := val.(type) {
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, errors.New(fmt.Sprintf("option %s must be an integer, got %v", name, v))
+		}
+		n = int64(v)
+	default:
+		return 0, errors.New(fmt.Sprintf("option %s must be an integer, got %T", name, val))
+	}
+	if n < 0 {
+		return 0, errors.New(fmt.Sprintf("option %s must not be negative, got %d", name, n))
+	}
+	return int(n), nil
+}
+
+// withOptions merges opts into the options map ending condition, adding one
+// when there is none.
+func withOptions(condition []any, opts map[string]any) []any {
+	if last, err := lo.Last(condition); err == nil {
+		if m, isMap := last.(map[string]any); isMap {
+			condition[len(condition)-1] = lo.Assign(m, opts)
+			return condition
+		}
+	}
+	return append(condition, opts)
+}
+
+func copyCondition(condition []any) []any {
+	if condition == nil {
+		return nil
+	}
+	return deepCopyMap(condition).([]any)
+}
+
+// sortedKeys returns the keys of m sorted, so queries built from maps are
+// the same on every run.
+func sortedKeys(m map[string]any) []string {
+	keys := lo.Keys(m)
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,333 @@
+package cffirestore
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"encoding/json"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/samber/lo"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMakeQueryDeterministic(t *testing.T) {
+	coll := testCollection(t)
+	condition := func() []any {
+		return []any{
+			map[string]any{
+				"status": "open",
+				"uid":    "abc",
+				"kind":   "invoice",
+				"address": map[string]any{
+					"city":    "Hanoi",
+					"country": "VN",
+					"zip":     "100000",
+				},
+				"tags": ArrayContains("urgent"),
+			},
+			[]any{"amount", ">", 10},
+			map[string]any{"orderBy": "createdAt:desc", "limit": 10, "offset": 5},
+		}
+	}
+
+	output := color.Output
+	noColor := color.NoColor
+	DebugEnabled = true
+	color.NoColor = true
+	defer func() {
+		DebugEnabled = false
+		color.Output = output
+		color.NoColor = noColor
+	}()
+	var first []byte
+	var firstQuery []byte
+	for i := 0; i < 20; i++ {
+		var buf bytes.Buffer
+		color.Output = &buf
+		query, err := coll.makeQuery(condition())
+		if err != nil {
+			t.Fatal(err)
+		}
+		serialized, err := query.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first, firstQuery = buf.Bytes(), serialized
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), first) {
+			t.Fatalf("run %d debug output:\n%s\nwant:\n%s", i, buf.Bytes(), first)
+		}
+		if !bytes.Equal(serialized, firstQuery) {
+			t.Fatalf("run %d built another query", i)
+		}
+	}
+	if len(first) == 0 {
+		t.Fatal("no debug output")
+	}
+}
+
+func TestPlanEqualsSortedPaths(t *testing.T) {
+	coll := testCollection(t)
+	plan, err := coll.planQuery([]any{
+		map[string]any{"b": 1, "a": map[string]any{"z": 1, "y": 2}, "c": In("x", "y")},
+		[]any{"d", "==", 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []whereClause{
+		{"a.y", "==", 2},
+		{"a.z", "==", 1},
+		{"b", "==", 1},
+		{"c", "in", []any{"x", "y"}},
+		{"d", "==", 1},
+	}
+	if !reflect.DeepEqual(plan.wheres, want) {
+		t.Errorf("wheres = %v, want %v", plan.wheres, want)
+	}
+}
+
+func jsonCondition(t *testing.T, s string) []any {
+	t.Helper()
+	var condition []any
+	if err := json.Unmarshal([]byte(s), &condition); err != nil {
+		t.Fatal(err)
+	}
+	return condition
+}
+
+func TestMakeQueryJSONLimitOffset(t *testing.T) {
+	coll := testCollection(t)
+	query, err := coll.MakeQueryE(jsonCondition(t, `[["status", "==", "open"], {"limit": 25, "offset": 50}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sq := structuredQuery(t, query)
+	if sq.GetLimit().GetValue() != 25 || sq.GetOffset() != 50 {
+		t.Errorf("limit, offset = %d, %d, want 25, 50", sq.GetLimit().GetValue(), sq.GetOffset())
+	}
+
+	query, err = coll.MakeQueryE(jsonCondition(t, `[{"orderBy": "createdAt", "limitToLast": 5}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sq = structuredQuery(t, query); sq.GetLimit().GetValue() != 5 {
+		t.Errorf("limitToLast = %d, want 5", sq.GetLimit().GetValue())
+	}
+}
+
+func TestIntOption(t *testing.T) {
+	for _, val := range []any{int(7), int32(7), int64(7), float64(7)} {
+		if n, err := intOption("limit", val); err != nil || n != 7 {
+			t.Errorf("intOption(%T) = %d, %v", val, n, err)
+		}
+	}
+	for _, val := range []any{2.5, -1, float64(-3), "7", nil} {
+		if _, err := intOption("limit", val); err == nil {
+			t.Errorf("intOption(%#v) returned no error", val)
+		}
+	}
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestMakeQueryBadConditions(t *testing.T) {
+	logger := &recordingLogger{}
+	coll := testCollection(t).WithLogger(logger)
+	for _, s := range []string{
+		`[{"limit": 2.5}]`,
+		`[{"offset": -1}]`,
+		`[{"limit": "10"}]`,
+		`[["status", "=="]]`,
+		`[[1, "==", "open"]]`,
+		`[["status", 1, "open"]]`,
+		`[42]`,
+	} {
+		condition := jsonCondition(t, s)
+		if _, err := coll.MakeQueryE(condition); err == nil {
+			t.Errorf("MakeQueryE(%s) returned no error", s)
+		}
+		if _, err := coll.MakeQuery(condition).Serialize(); err == nil {
+			t.Errorf("MakeQuery(%s) returned a query that runs", s)
+		}
+		paged := coll.MakeQuery(condition).OrderBy("createdAt", firestore.Asc).StartAfter(time.Now()).EndBefore(time.Now())
+		if _, err := paged.Serialize(); err == nil {
+			t.Errorf("MakeQuery(%s) with cursors returned a query that runs", s)
+		}
+	}
+	if len(logger.lines) != 14 {
+		t.Errorf("MakeQuery logged %q", logger.lines)
+	}
+	if _, err := coll.MakeQueryE([]any{map[string]string{"status": "open"}, map[string]any{}}); err == nil {
+		t.Error("MakeQueryE took a map[string]string element")
+	}
+	if _, err := coll.MakeQueryE([]any{[]string{"status", "==", "open"}}); err != nil {
+		t.Errorf("MakeQueryE([]string) = %v", err)
+	}
+}
+
+func TestMemoryCollectionJSONLimitOffset(t *testing.T) {
+	m := NewMemoryCollection("tests")
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("doc%d", i)
+		if _, _, err := m.AddDocWithId(&id, nil, map[string]any{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs, err := m.ListDocs(jsonCondition(t, `[{"orderBy": "n", "limit": 3, "offset": 4}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lo.Map(docs, func(doc map[string]any, _ int) any { return doc["_id"] })
+	if want := []any{"doc4", "doc5", "doc6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("docs = %v, want %v", got, want)
+	}
+}
+
+func TestMakeQueryJSONOrderBy(t *testing.T) {
+	coll := testCollection(t)
+	query, err := coll.MakeQueryE(jsonCondition(t, `[{"orderBy": ["createdAt:desc", "name:asc"]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"createdAt:desc", "name:asc", "__name__:asc"}
+	if got := orderByKeys(structuredQuery(t, query)); !reflect.DeepEqual(got, want) {
+		t.Errorf("orderBy = %v, want %v", got, want)
+	}
+}
+
+func TestMakeQueryOrderByValues(t *testing.T) {
+	coll := testCollection(t)
+	want := []string{"createdAt:desc", "name:asc", "__name__:asc"}
+	for _, val := range []any{
+		[]string{"createdAt:desc", "name:asc"},
+		[]any{"createdAt:desc", "name:asc"},
+		[]OrderBy{{"createdAt", firestore.Desc}, {"name", firestore.Asc}},
+	} {
+		query, err := coll.MakeQueryE([]any{map[string]any{"orderBy": val}})
+		if err != nil {
+			t.Fatalf("orderBy %T: %v", val, err)
+		}
+		if got := orderByKeys(structuredQuery(t, query)); !reflect.DeepEqual(got, want) {
+			t.Errorf("orderBy %T = %v, want %v", val, got, want)
+		}
+	}
+	query, err := coll.MakeQueryE([]any{map[string]any{"orderBy": OrderBy{"name", firestore.Desc}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := orderByKeys(structuredQuery(t, query)), []string{"name:desc", "__name__:desc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("orderBy OrderBy = %v, want %v", got, want)
+	}
+	_, err = coll.MakeQueryE(jsonCondition(t, `[{"orderBy": ["createdAt:desc", 1]}]`))
+	if err == nil || !strings.Contains(err.Error(), "orderBy element 1 must be a string, got float64") {
+		t.Errorf("non string orderBy element error = %v", err)
+	}
+}
+
+func TestMemoryCollectionJSONOrderBy(t *testing.T) {
+	m := NewMemoryCollection("tests")
+	for _, doc := range []map[string]any{
+		{"group": 1, "name": "b"},
+		{"group": 2, "name": "a"},
+		{"group": 1, "name": "a"},
+		{"group": 2, "name": "b"},
+	} {
+		id := fmt.Sprintf("%v%v", doc["group"], doc["name"])
+		if _, _, err := m.AddDocWithId(&id, nil, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs, err := m.ListDocs(jsonCondition(t, `[{"orderBy": ["group:desc", "name:asc"]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lo.Map(docs, func(doc map[string]any, _ int) any { return doc["_id"] })
+	if want := []any{"2a", "2b", "1a", "1b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("docs = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeInValue(t *testing.T) {
+	for _, tc := range []struct {
+		val  any
+		want []any
+	}{
+		{[]string{"a", "b"}, []any{"a", "b"}},
+		{[]int64{1, 2}, []any{int64(1), int64(2)}},
+		{[]any{"a", int64(1)}, []any{"a", int64(1)}},
+		{[2]int{1, 2}, []any{1, 2}},
+	} {
+		for _, op := range []string{"in", "not-in", "array-contains-any"} {
+			got, err := normalizeInValue("status", op, tc.val)
+			if err != nil {
+				t.Fatalf("%s %T: %v", op, tc.val, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s %T = %#v, want %#v", op, tc.val, got, tc.want)
+			}
+		}
+	}
+	if got, err := normalizeInValue("tags", "array-contains", []string{"a"}); err != nil || !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("array-contains value = %#v, %v, want it unchanged", got, err)
+	}
+	for name, val := range map[string]any{
+		"empty":     []string{},
+		"too long":  make([]int64, MaxInValues+1),
+		"not slice": "a",
+	} {
+		if _, err := normalizeInValue("status", "in", val); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := normalizeInValue("status", "in", make([]int64, MaxInValues)); err != nil {
+		t.Errorf("%d values: %v", MaxInValues, err)
+	}
+}
+
+func TestMakeQueryTypedInValues(t *testing.T) {
+	coll := testCollection(t)
+	for _, val := range []any{[]string{"a", "b"}, []int64{1, 2}, []any{"a", int64(2)}} {
+		query, err := coll.MakeQueryE([]any{[]any{"status", "in", val}})
+		if err != nil {
+			t.Fatalf("in %T: %v", val, err)
+		}
+		filter := structuredQuery(t, query).GetWhere().GetFieldFilter()
+		if filter.GetOp() != pb.StructuredQuery_FieldFilter_IN || len(filter.GetValue().GetArrayValue().GetValues()) != 2 {
+			t.Errorf("in %T filter = %v", val, filter)
+		}
+	}
+}
+
+func TestMemoryCollectionTypedInValues(t *testing.T) {
+	m := NewMemoryCollection("tests")
+	for i, status := range []string{"open", "draft", "closed"} {
+		id := fmt.Sprint(i)
+		if _, _, err := m.AddDocWithId(&id, nil, map[string]any{"status": status, "n": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, condition := range [][]any{
+		{[]any{"status", "in", []string{"open", "draft"}}},
+		{[]any{"n", "in", []int64{0, 1}}},
+		{[]any{"status", "not-in", []string{"closed"}}},
+		{map[string]any{"status": In("open", "draft")}, map[string]any{}},
+	} {
+		docs, err := m.ListDocs(condition)
+		if err != nil || len(docs) != 2 {
+			t.Errorf("ListDocs(%v) = %d docs, %v, want 2", condition, len(docs), err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+)
+
+// QueryDocs runs a firestore.Query built by hand, for features the condition
+// format doesn't cover, and returns its docs decrypted and shaped like
+// ListDocs does. q should be built from the collection Ref, as for the
+// other Query methods.
+func (coll *Collection) QueryDocs(ctx context.Context, q firestore.Query) ([]map[string]any, error) {
+	return run(ctx, coll, call{Op: "QueryDocs"}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		return coll.queryDocs(ctx, q)
+	})
+}
+
+func (coll *Collection) queryDocs(ctx context.Context, q firestore.Query) ([]map[string]any, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	snaps, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	docs, err := coll.decryptDocs(coll.docResponses(snaps))
+	if err != nil {
+		return nil, err
+	}
+	return coll.shapeDocs(docs), nil
+}
+
+// QueryFirst returns the first doc of q, or nil when there is none.
+func (coll *Collection) QueryFirst(ctx context.Context, q firestore.Query) (map[string]any, error) {
+	return run(ctx, coll, call{Op: "QueryFirst"}, func(ctx context.Context, c *OperationCall) (map[string]any, error) {
+		docs, err := coll.queryDocs(ctx, q.Limit(1))
+		if err != nil || len(docs) == 0 {
+			return nil, err
+		}
+		return docs[0], nil
+	})
+}
+
+// QueryCount counts the docs of q with a count aggregation.
+func (coll *Collection) QueryCount(ctx context.Context, q firestore.Query) (int, error) {
+	return run(ctx, coll, call{Op: "QueryCount"}, func(ctx context.Context, c *OperationCall) (int, error) {
+		ctx, cancel := coll.withTimeout(ctx)
+		defer cancel()
+		return countQuery(ctx, q)
+	})
+}
+
+// QueryPaginate returns a page of q like PaginateTyped, with offsets, so q
+// shouldn't have its own limit or offset.
+func (coll *Collection) QueryPaginate(ctx context.Context, q firestore.Query, page int, perPage int) (*PaginateResult, error) {
+	return run(ctx, coll, call{Op: "QueryPaginate"}, func(ctx context.Context, c *OperationCall) (*PaginateResult, error) {
+		page, perPage = coll.pageBounds(page, perPage)
+		// one more doc than perPage tells whether there is a next page
+		docs, err := coll.queryDocs(ctx, q.Limit(perPage+1).Offset((page-1)*perPage))
+		if err != nil {
+			return nil, err
+		}
+		return &PaginateResult{
+			Docs:    docs[:min(len(docs), perPage)],
+			Page:    page,
+			PerPage: perPage,
+			HasNext: len(docs) > perPage,
+			HasPrev: page > 1,
+		}, nil
+	})
+}
@@ -0,0 +1,104 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"google.golang.org/api/iterator"
+)
+
+var errRecursiveSoftDelete = errors.New("a recursive delete cannot be soft, see WithSoftDeleteCascade")
+
+// DeleteDocRecursive deletes doc id and every doc of its subcollections,
+// at any depth, see DeleteOptions.Recursive.
+func (coll *Collection) DeleteDocRecursive(id string) (*firestore.WriteResult, error) {
+	return coll.DeleteDocWithOptions(id, DeleteOptions{Recursive: true})
+}
+
+// DeleteDocsWithOptions deletes the docs matching condition like
+// DeleteDocsWithReport, with the recursion and progress of opts.
+// IgnoreMissing does not apply.
+func (coll *Collection) DeleteDocsWithOptions(condition []any, opts DeleteOptions) (*BulkResult, error) {
+	return coll.DeleteDocsWithOptionsContext(context.Background(), condition, opts)
+}
+
+// DeleteDocsWithOptionsContext is DeleteDocsWithOptions with ctx.
+func (coll *Collection) DeleteDocsWithOptionsContext(ctx context.Context, condition []any, opts DeleteOptions) (*BulkResult, error) {
+	return run(ctx, coll, call{Op: "DeleteDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.deleteDocsReport(ctx, c.Condition, opts)
+	})
+}
+
+// deleteProgress counts the deletes a BulkWriter sent, and reports them
+// after each flush.
+type deleteProgress struct {
+	fn      func(deleted int)
+	deleted int
+	pending int
+}
+
+func (p *deleteProgress) queued(batch *firestore.BulkWriter) {
+	if p.pending++; p.pending == cascadePageSize {
+		batch.Flush()
+		p.flushed()
+	}
+}
+
+// flushed reports the pending deletes, once the BulkWriter was flushed or
+// ended.
+func (p *deleteProgress) flushed() {
+	if p.pending == 0 {
+		return
+	}
+	p.deleted += p.pending
+	p.pending = 0
+	if p.fn != nil {
+		p.fn(p.deleted)
+	}
+}
+
+// deleteTree deletes every doc of the subcollections of docRef.
+func (coll *Collection) deleteTree(ctx context.Context, docRef *firestore.DocumentRef, progress func(deleted int)) error {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	batch := coll.Client.BulkWriter(ctx)
+	jobs := &bulkJobs{}
+	p := &deleteProgress{fn: progress}
+	err := coll.deleteSubtree(ctx, batch, jobs, docRef, p)
+	batch.End()
+	p.flushed()
+	return errors.Join(jobs.report("deleteTree").Err(), err)
+}
+
+// deleteSubtree queues the deletes of the docs of the subcollections of
+// docRef, depth first. Missing docs having subcollections are visited too,
+// so no orphans are left.
+func (coll *Collection) deleteSubtree(ctx context.Context, batch *firestore.BulkWriter, jobs *bulkJobs, docRef *firestore.DocumentRef, progress *deleteProgress) error {
+	subColls, err := docRef.Collections(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, subColl := range subColls {
+		iter := subColl.DocumentRefs(ctx)
+		for {
+			ref, err := iter.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			errs = append(errs, coll.deleteSubtree(ctx, batch, jobs, ref, progress))
+			job, err := batch.Delete(ref)
+			if err != nil {
+				errs = append(errs, docErr(ref.Path, err))
+				continue
+			}
+			jobs.add(ref.Path, job)
+			progress.queued(batch)
+		}
+	}
+	return errors.Join(errs...)
+}
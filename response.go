@@ -0,0 +1,68 @@
+package cffirestore
+
+import (
+	"strings"
+)
+
+type ResponseOptions struct {
+	// ExcludeFields are dot paths removed from returned docs.
+	ExcludeFields []string
+	// OmitRef drops the "_ref" metadata key.
+	OmitRef bool
+	// IDKey renames the "_id" metadata key.
+	IDKey string
+	// IncludeSnapshotTimes adds the "_createTime", "_updateTime" and
+	// "_readTime" metadata keys, the server times of the doc snapshot.
+	IncludeSnapshotTimes bool
+}
+
+// WithResponseOptions shapes the docs returned by GetDoc, FindDoc, ListDocs
+// and Paginate. Internal reads, like the ones BatchDocs diffs against, are
+// not shaped.
+func (coll *Collection) WithResponseOptions(opts ResponseOptions) *Collection {
+	coll.responseOptions = &opts
+	return coll
+}
+
+func (coll *Collection) shapeDocs(docs []map[string]any) []map[string]any {
+	for i, doc := range docs {
+		docs[i] = coll.shapeDoc(doc)
+	}
+	return docs
+}
+
+func (coll *Collection) shapeDoc(doc map[string]any) map[string]any {
+	opts := coll.responseOptions
+	if doc == nil {
+		return doc
+	}
+	if opts == nil {
+		return doc
+	}
+	for _, path := range opts.ExcludeFields {
+		deletePath(doc, path)
+	}
+	if opts.OmitRef {
+		delete(doc, "_ref")
+	}
+	if opts.IDKey != "" && opts.IDKey != "_id" {
+		if id, ok := doc["_id"]; ok {
+			doc[opts.IDKey] = id
+			delete(doc, "_id")
+		}
+	}
+	return doc
+}
+
+func deletePath(doc map[string]any, path string) {
+	segs := strings.Split(path, ".")
+	cur := doc
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := cur[seg].(map[string]any)
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	delete(cur, segs[len(segs)-1])
+}
@@ -0,0 +1,180 @@
+package cffirestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path"
+	"strings"
+)
+
+var ErrSchemaViolation = errors.New("schema violation")
+
+type SchemaViolation struct {
+	// Path is the dot path of the violating value, "" for the doc itself.
+	Path string `json:"path"`
+	// Rule is the failing schema keyword, like "required" or "minimum".
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// SchemaError lists every violation of a doc. errors.Is(err,
+// ErrSchemaViolation) matches it.
+type SchemaError struct {
+	Violations []SchemaViolation `json:"violations"`
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s: %s", v.Path, v.Message))
+	}
+	return fmt.Sprintf("%s: %s", ErrSchemaViolation, strings.Join(parts, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+type SchemaOptions struct {
+	// ValidateUpdatedFieldsOnly makes UpdateDoc validate only the supplied
+	// fields instead of reading and validating the merged doc.
+	ValidateUpdatedFieldsOnly bool
+	// SkipInvalidBatchDocs makes BatchDocs silently skip transformed docs
+	// that fail validation instead of reporting them as errors.
+	SkipInvalidBatchDocs bool
+}
+
+// WithJSONSchema validates docs against schema before AddDoc, AddDocWithId,
+// UpdateDoc and BatchDocs write them.
+func (coll *Collection) WithJSONSchema(schema []byte, opts ...SchemaOptions) (*Collection, error) {
+	compiler := jsonschema.NewCompiler()
+	url := fmt.Sprintf("cffirestore://%s.json", coll.Path)
+	if err := compiler.AddResource(url, bytes.NewReader(schema)); err != nil {
+		return nil, err
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+	coll.schema = compiled
+	if len(opts) > 0 {
+		coll.schemaOptions = opts[0]
+	}
+	return coll, nil
+}
+
+func (coll *Collection) validateDoc(doc map[string]any) error {
+	return coll.validateAgainstSchema(doc, false)
+}
+
+func (coll *Collection) validateUpdate(ctx context.Context, id string, data map[string]any) error {
+	if coll.schema == nil {
+		return nil
+	}
+	if coll.schemaOptions.ValidateUpdatedFieldsOnly {
+		return coll.validateAgainstSchema(data, true)
+	}
+	snap, err := getSnap(ctx, coll.ref.Doc(id))
+	if err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+	merged := make(map[string]any)
+	if snap != nil && snap.Exists() {
+		merged = snap.Data()
+		if err := coll.decryptFields(merged); err != nil {
+			return err
+		}
+	}
+	return coll.validateAgainstSchema(mergeDocMaps(merged, data), false)
+}
+
+// mergeDocMaps merges src into dst the way a MergeAll set does, a
+// DeleteField value removing the key.
+func mergeDocMaps(dst map[string]any, src map[string]any) map[string]any {
+	for key, val := range src {
+		if val == DeleteField {
+			delete(dst, key)
+			continue
+		}
+		srcMap, srcOk := val.(map[string]any)
+		dstMap, dstOk := dst[key].(map[string]any)
+		if srcOk && dstOk {
+			dst[key] = mergeDocMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = val
+	}
+	return dst
+}
+
+func (coll *Collection) validateAgainstSchema(doc map[string]any, ignoreRequired bool) error {
+	if coll.schema == nil {
+		return nil
+	}
+	instance, err := toJSONValue(omitMetadata(doc))
+	if err != nil {
+		return err
+	}
+	err = coll.schema.Validate(instance)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	violations := make([]SchemaViolation, 0)
+	collectViolations(validationErr, ignoreRequired, &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	return &SchemaError{Violations: violations}
+}
+
+func collectViolations(ve *jsonschema.ValidationError, ignoreRequired bool, out *[]SchemaViolation) {
+	if len(ve.Causes) > 0 {
+		for _, cause := range ve.Causes {
+			collectViolations(cause, ignoreRequired, out)
+		}
+		return
+	}
+	rule := path.Base(ve.KeywordLocation)
+	if ignoreRequired && rule == "required" {
+		return
+	}
+	*out = append(*out, SchemaViolation{
+		Path:    strings.ReplaceAll(strings.TrimPrefix(ve.InstanceLocation, "/"), "/", "."),
+		Rule:    rule,
+		Message: ve.Message,
+	})
+}
+
+func omitMetadata(doc map[string]any) map[string]any {
+	out := make(map[string]any, len(doc))
+	for key, val := range doc {
+		if isMetadataKey(key) {
+			continue
+		}
+		out[key] = val
+	}
+	return out
+}
+
+// toJSONValue converts doc values to what a JSON decoder produces, e.g.
+// time.Time to an RFC 3339 string.
+func toJSONValue(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var out any
+	if err := decoder.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,60 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Scope roots collections under a document path, e.g. tenants/{tenantId}.
+type Scope struct {
+	Client *firestore.Client
+	Path   string
+}
+
+// NewScope returns the scope of the doc id in collection. Empty ids and ids
+// containing "/" are refused.
+func NewScope(client *firestore.Client, collection string, id string) (*Scope, error) {
+	if err := validateScopeSegments(collection, id); err != nil {
+		return nil, err
+	}
+	return &Scope{
+		Client: client,
+		Path:   fmt.Sprintf("%s/%s", collection, id),
+	}, nil
+}
+
+// Scope nests a scope under s, e.g. organizations/{orgId}/projects/{projectId}.
+func (s *Scope) Scope(collection string, id string) (*Scope, error) {
+	if err := validateScopeSegments(collection, id); err != nil {
+		return nil, err
+	}
+	return &Scope{
+		Client: s.Client,
+		Path:   fmt.Sprintf("%s/%s/%s", s.Path, collection, id),
+	}, nil
+}
+
+// Collection returns the collection name under the scope's doc. Empty names
+// and names containing "/" are refused.
+func (s *Scope) Collection(name string) (*Collection, error) {
+	if err := validateScopeSegment("collection", name); err != nil {
+		return nil, err
+	}
+	return CollectionWithPath(s.Client, fmt.Sprintf("%s/%s", s.Path, name)), nil
+}
+
+func validateScopeSegments(collection string, id string) error {
+	if err := validateScopeSegment("collection", collection); err != nil {
+		return err
+	}
+	return validateScopeSegment("id", id)
+}
+
+func validateScopeSegment(kind string, segment string) error {
+	if strings.TrimSpace(segment) == "" || strings.Contains(segment, "/") {
+		return errors.New(fmt.Sprintf("invalid scope %s: %q", kind, segment))
+	}
+	return nil
+}
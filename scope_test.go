@@ -0,0 +1,33 @@
+package cffirestore
+
+import "testing"
+
+func TestScope(t *testing.T) {
+	client := testClient(t)
+	tenant, err := NewScope(client, "tenants", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := tenant.Scope("projects", "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll, err := project.Collection("tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coll.Path != "tenants/t1/projects/p1/tasks" || coll.Ref().Path != "projects/"+testProject+"/databases/(default)/documents/tenants/t1/projects/p1/tasks" {
+		t.Errorf("collection path = %q, ref %q", coll.Path, coll.Ref().Path)
+	}
+	for _, name := range []string{"", " ", "tasks/t1", "/tasks", "tasks/"} {
+		if _, err := tenant.Collection(name); err == nil {
+			t.Errorf("Collection(%q) returned no error", name)
+		}
+		if _, err := tenant.Scope(name, "p1"); err == nil {
+			t.Errorf("Scope(%q, p1) returned no error", name)
+		}
+		if _, err := NewScope(client, "tenants", name); err == nil {
+			t.Errorf("NewScope(tenants, %q) returned no error", name)
+		}
+	}
+}
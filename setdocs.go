@@ -0,0 +1,136 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"strings"
+)
+
+// SetDocs writes docs by their _id or id field, replacing existing docs or,
+// with merge, merging into them like UpdateDoc. updatedAt is stamped on
+// every doc; createdAt and deletedAt are set on new docs and kept from the
+// stored doc on replace when docs lack them, which costs a read per doc.
+// Docs are written 500 at a time and reported one by one.
+func (coll *Collection) SetDocs(ctx context.Context, docs []map[string]any, merge bool) (*BulkResult, error) {
+	return run(ctx, coll, call{Op: "SetDocs"}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.setDocs(ctx, docs, merge)
+	})
+}
+
+func (coll *Collection) setDocs(ctx context.Context, docs []map[string]any, merge bool) (*BulkResult, error) {
+	missing := make([]string, 0)
+	for i, doc := range docs {
+		if _, ok := bulkDocID(doc); !ok {
+			missing = append(missing, fmt.Sprint(i))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.New(fmt.Sprintf("docs without %s field at indexes %s", IdFieldName, strings.Join(missing, ", ")))
+	}
+	if len(coll.uniqueFields) > 0 {
+		return nil, errors.New("SetDocs does not maintain unique fields, use AddDocWithId or UpdateDoc")
+	}
+	report := &BulkResult{}
+	for _, chunk := range lo.Chunk(docs, bulkPageSize) {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		chunkCtx, span := coll.startSpan(ctx, "SetDocs.chunk", "", nil)
+		chunkReport, err := setEach500Docs(chunkCtx, coll, chunk, merge)
+		if err != nil {
+			span.End(0, err)
+			return report, err
+		}
+		span.End(chunkReport.Succeeded, chunkReport.Err())
+		report.merge(chunkReport)
+	}
+	return report, nil
+}
+
+func setEach500Docs(ctx context.Context, coll *Collection, docs []map[string]any, merge bool) (*BulkResult, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	op := "set"
+	if merge {
+		op = "merge"
+	}
+	refs := make([]*firestore.DocumentRef, len(docs))
+	for i, doc := range docs {
+		id, _ := bulkDocID(doc)
+		refs[i] = coll.ref.Doc(id)
+	}
+	snaps, err := coll.Client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := coll.now()
+	report := &BulkResult{}
+	jobs := &bulkJobs{}
+	batch := coll.Client.BulkWriter(ctx)
+	for i, src := range docs {
+		id := refs[i].ID
+		doc := deepCopyMap(src).(map[string]any)
+		dropMetadata(doc)
+		if err := encodeValues(doc); err != nil {
+			report.add(id, op, nil, err)
+			continue
+		}
+		exists := snaps[i].Exists()
+		for _, field := range []string{CreatedAtFieldName, DeletedAtFieldName} {
+			if _, ok := doc[field]; ok {
+				continue
+			}
+			switch {
+			case !exists && field == CreatedAtFieldName:
+				doc[field] = now
+			case !exists:
+				doc[field] = nil
+			case !merge:
+				if val, err := snaps[i].DataAt(field); err == nil {
+					doc[field] = val
+				}
+			}
+		}
+		doc[UpdatedAtFieldName] = now
+		coll.normalizeFields(doc)
+		whole := !merge || !exists
+		if whole {
+			coll.setSearchKeywords(doc)
+		} else if len(coll.keywordFields) > 0 && coll.touchesKeywordFields(doc) {
+			current := snaps[i].Data()
+			if err := coll.decryptFields(current); err != nil {
+				report.add(id, op, nil, err)
+				continue
+			}
+			doc[KeywordsFieldName] = coll.searchKeywords(mergeDocMaps(current, deepCopyMap(doc).(map[string]any)))
+		}
+		if err := coll.validateAgainstSchema(doc, !whole); err != nil {
+			report.add(id, op, nil, err)
+			continue
+		}
+		stored, err := coll.encryptFields(doc)
+		if err != nil {
+			report.add(id, op, nil, err)
+			continue
+		}
+		var job *firestore.BulkWriterJob
+		if merge {
+			job, err = batch.Set(refs[i], stored, firestore.MergeAll)
+		} else {
+			job, err = batch.Set(refs[i], stored)
+		}
+		if err != nil {
+			report.add(id, op, nil, err)
+			continue
+		}
+		jobs.add(id, job)
+	}
+	batch.End()
+
+	report.merge(jobs.report(op))
+	return report, nil
+}
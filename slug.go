@@ -0,0 +1,118 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// SlugMaxAttempts bounds the "-2", "-3" suffixes AddDocWithSlug tries.
+var SlugMaxAttempts = 100
+
+var errSlugTaken = errors.New("slug taken")
+
+var slugReplacer = strings.NewReplacer(
+	"đ", "d", "ð", "d", "ø", "o", "ł", "l", "ß", "ss", "æ", "ae", "œ", "oe", "þ", "th",
+)
+
+// Slugify lowercases s, strips diacritics and joins the remaining letters and
+// digits with "-", "Crème Brûlée!" becomes "creme-brulee".
+func Slugify(s string) string {
+	s = slugReplacer.Replace(strings.ToLower(s))
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		default:
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// AddDocWithSlug adds v with slugField set to the slug of its sourceField,
+// suffixed "-2", "-3", ... when the slug is taken in the collection. When
+// slugField is a unique field, see WithUniqueField, its reservations decide
+// collisions.
+func (coll *Collection) AddDocWithSlug(uid *string, v map[string]any, sourceField, slugField string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(context.Background(), coll, call{Op: "AddDocWithSlug", Data: v}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		ref, result, err = coll.addDocWithSlug(ctx, uid, c.Data, sourceField, slugField)
+		return result, err
+	})
+	return ref, result, err
+}
+
+func (coll *Collection) addDocWithSlug(ctx context.Context, uid *string, v map[string]any, sourceField, slugField string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	source, _ := GetPath[string](v, sourceField)
+	base := Slugify(source)
+	if base == "" {
+		return nil, nil, errors.New(fmt.Sprintf("field %s has no slug characters", sourceField))
+	}
+	slugUnique := lo.Contains(coll.uniqueFields, slugField)
+	for attempt := 1; attempt <= SlugMaxAttempts; attempt++ {
+		slug := base
+		if attempt > 1 {
+			slug = fmt.Sprintf("%s-%d", base, attempt)
+		}
+		v[slugField] = slug
+		ref, stored, err := coll.prepareNewDoc(nil, uid, v, AddOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := coll.writeUnique(ctx, ref.ID, v, true, func(tx *firestore.Transaction, ref *firestore.DocumentRef) error {
+			if !slugUnique {
+				taken, err := tx.Documents(coll.ref.Where(slugField, "==", slug).Select().Limit(1)).GetAll()
+				if err != nil {
+					return err
+				}
+				if len(taken) > 0 {
+					return errSlugTaken
+				}
+			}
+			return tx.Create(ref, stored)
+		})
+		var uniqueErr *UniqueViolationError
+		if errors.Is(err, errSlugTaken) || (errors.As(err, &uniqueErr) && uniqueErr.Field == slugField) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return ref, result, nil
+	}
+	return nil, nil, errors.New(fmt.Sprintf("no free slug for %s after %d attempts", base, SlugMaxAttempts))
+}
+
+// GetDocBySlug finds the doc whose slugField, SlugFieldName by default,
+// equals slug, e.g. the slugField given to AddDocWithSlug.
+func (coll *Collection) GetDocBySlug(slug string, slugField ...string) (map[string]any, error) {
+	field := SlugFieldName
+	if len(slugField) > 0 && slugField[0] != "" {
+		field = slugField[0]
+	}
+	return run(context.Background(), coll, call{Op: "GetDocBySlug", DocID: slug}, func(ctx context.Context, c *OperationCall) (map[string]any, error) {
+		doc, err := coll.findDoc(ctx, []any{[]any{field, "==", c.DocID}})
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			return nil, docNotFound(c.DocID)
+		}
+		return doc, nil
+	})
+}
@@ -0,0 +1,26 @@
+package cffirestore
+
+import (
+	"testing"
+)
+
+func TestGetDocBySlugField(t *testing.T) {
+	coll := emulatorCollection(t)
+	for i := 0; i < 2; i++ {
+		if _, _, err := coll.AddDocWithSlug(nil, map[string]any{"title": "Crème Brûlée"}, "title", "handle"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, slug := range []string{"creme-brulee", "creme-brulee-2"} {
+		doc, err := coll.GetDocBySlug(slug, "handle")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc["handle"] != slug {
+			t.Errorf("GetDocBySlug(%s) = %v", slug, doc)
+		}
+	}
+	if _, err := coll.GetDocBySlug("creme-brulee"); err == nil {
+		t.Error("GetDocBySlug on the default field found a doc")
+	}
+}
@@ -0,0 +1,324 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+var cascadePageSize = 500
+
+// WithSoftDeleteCascade makes soft deletes and restores also stamp the docs
+// of the given subcollections. Without names every subcollection is visited,
+// recursively.
+func (coll *Collection) WithSoftDeleteCascade(subcollections ...string) *Collection {
+	coll.softDeleteCascade = true
+	coll.cascadeSubcollections = subcollections
+	return coll
+}
+
+// WithExcludeDeleted makes queries leave out soft deleted docs, matching
+// deletedAt == nil, unless their condition has its own top level deletedAt
+// clause or IncludeDeleted. GetDoc still gets a deleted doc by id.
+func (coll *Collection) WithExcludeDeleted() *Collection {
+	coll.excludeDeleted = true
+	return coll
+}
+
+type includeDeletedCondition struct{}
+
+// IncludeDeleted makes a condition also match the soft deleted docs of a
+// collection excluding them, e.g. []any{IncludeDeleted, ...}.
+var IncludeDeleted Condition = includeDeletedCondition{}
+
+func (includeDeletedCondition) canonical() []any {
+	return nil
+}
+
+func (includeDeletedCondition) expand(coll *Collection, plan *queryPlan) error {
+	plan.includeDeleted = true
+	return nil
+}
+
+// planDeleted adds the not deleted filter to plan.
+func (coll *Collection) planDeleted(plan *queryPlan) {
+	if !coll.excludeDeleted || plan.includeDeleted {
+		return
+	}
+	if lo.ContainsBy(plan.wheres, func(w whereClause) bool { return w.Path == DeletedAtFieldName }) ||
+		lo.Contains(plan.notNull, DeletedAtFieldName) || lo.Contains(plan.rangeFields, DeletedAtFieldName) {
+		return
+	}
+	plan.where(DeletedAtFieldName, "==", nil)
+}
+
+type bulkJobs struct {
+	jobs []*firestore.BulkWriterJob
+	ids  []string
+}
+
+func (b *bulkJobs) add(id string, job *firestore.BulkWriterJob) {
+	b.jobs = append(b.jobs, job)
+	b.ids = append(b.ids, id)
+}
+
+func (b *bulkJobs) report(op string) *BulkResult {
+	report := &BulkResult{}
+	for i, job := range b.jobs {
+		result, err := job.Results()
+		report.add(b.ids[i], op, result, err)
+	}
+	return report
+}
+
+var (
+	RestoredAtFieldName = "restoredAt"
+	RestoredByFieldName = "restoredBy"
+)
+
+// RestoreOptions changes how RestoreDocWithOptions and
+// RestoreDocsWithOptions write the restored docs.
+type RestoreOptions struct {
+	// RecordRestoredAt stamps restoredAt with the restore time.
+	RecordRestoredAt bool
+	// RestoredBy is stored in restoredBy when not empty.
+	RestoredBy string
+}
+
+func (opts RestoreOptions) updates(now time.Time) []firestore.Update {
+	updates := make([]firestore.Update, 0, 2)
+	if opts.RecordRestoredAt {
+		updates = append(updates, firestore.Update{Path: RestoredAtFieldName, Value: now})
+	}
+	if opts.RestoredBy != "" {
+		updates = append(updates, firestore.Update{Path: RestoredByFieldName, Value: opts.RestoredBy})
+	}
+	return updates
+}
+
+func (coll *Collection) RestoreDoc(id string) (*firestore.WriteResult, error) {
+	return coll.RestoreDocWithOptions(id, RestoreOptions{})
+}
+
+func (coll *Collection) RestoreDocWithOptions(id string, opts RestoreOptions) (*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "RestoreDoc", DocID: id}, func(ctx context.Context, c *OperationCall) (*firestore.WriteResult, error) {
+		return coll.restoreDoc(ctx, c.DocID, opts)
+	})
+}
+
+func (coll *Collection) restoreDoc(ctx context.Context, id string, opts RestoreOptions) (*firestore.WriteResult, error) {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	docRef := coll.ref.Doc(id)
+	snap, err := docRef.Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, docNotFound(id)
+		}
+		return nil, err
+	}
+	deletedAt, err := snap.DataAt(DeletedAtFieldName)
+	if err != nil || deletedAt == nil {
+		return nil, errors.New("doc is not deleted")
+	}
+	now := coll.now()
+	result, err := docRef.Update(ctx, append([]firestore.Update{
+		{
+			Path:  DeletedAtFieldName,
+			Value: nil,
+		},
+		{
+			Path:  UpdatedAtFieldName,
+			Value: now,
+		},
+	}, opts.updates(now)...))
+	if err != nil || !coll.softDeleteCascade {
+		return result, err
+	}
+	return result, coll.cascadeSoftDeleteDoc(ctx, docRef, deletedAt, nil)
+}
+
+// cascadeSoftDeleteDoc sets deletedAt to the given value on the subcollection
+// docs of docRef whose deletedAt currently equals match.
+func (coll *Collection) cascadeSoftDeleteDoc(ctx context.Context, docRef *firestore.DocumentRef, match any, deletedAt any) error {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	batch := coll.Client.BulkWriter(ctx)
+	jobs := &bulkJobs{}
+	err := coll.cascadeDeletedAt(ctx, batch, jobs, docRef, match, deletedAt, coll.now())
+	batch.End()
+	return errors.Join(jobs.report("cascade").Err(), err)
+}
+
+func (coll *Collection) cascadeDeletedAt(ctx context.Context, batch *firestore.BulkWriter, jobs *bulkJobs, docRef *firestore.DocumentRef, match any, deletedAt any, now time.Time) error {
+	subColls := make([]*firestore.CollectionRef, 0)
+	recursive := len(coll.cascadeSubcollections) == 0
+	if recursive {
+		refs, err := docRef.Collections(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		subColls = refs
+	} else {
+		for _, name := range coll.cascadeSubcollections {
+			subColls = append(subColls, docRef.Collection(name))
+		}
+	}
+
+	errs := make([]error, 0)
+	for _, subColl := range subColls {
+		iter := subColl.Where(DeletedAtFieldName, "==", match).Select().Documents(ctx)
+		pending := 0
+		for {
+			snap, err := iter.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			job, err := batch.Update(snap.Ref, []firestore.Update{
+				{
+					Path:  DeletedAtFieldName,
+					Value: deletedAt,
+				},
+				{
+					Path:  UpdatedAtFieldName,
+					Value: now,
+				},
+			})
+			if err != nil {
+				errs = append(errs, docErr(snap.Ref.Path, err))
+				continue
+			}
+			jobs.add(snap.Ref.Path, job)
+			if pending++; pending == cascadePageSize {
+				batch.Flush()
+				pending = 0
+			}
+			if recursive {
+				errs = append(errs, coll.cascadeDeletedAt(ctx, batch, jobs, snap.Ref, match, deletedAt, now))
+			}
+		}
+		iter.Stop()
+	}
+	return errors.Join(errs...)
+}
+
+// DeletedByFieldName is cleared along with deletedAt by RestoreDocs, when
+// present.
+var DeletedByFieldName = "deletedBy"
+
+// ErrRestoreWithoutCondition is returned by RestoreDocs called without any
+// where condition, pass AllDeleted to restore every deleted doc.
+var ErrRestoreWithoutCondition = errors.New("restore docs: no condition, pass AllDeleted to restore every deleted doc")
+
+type allDeletedCondition struct{}
+
+func (allDeletedCondition) canonical() []any {
+	return nil
+}
+
+func (allDeletedCondition) expand(coll *Collection, plan *queryPlan) error {
+	return nil
+}
+
+// AllDeleted lets RestoreDocs run without any other condition.
+var AllDeleted Condition = allDeletedCondition{}
+
+// RestoreDocs restores the soft deleted docs matching condition, the number
+// restored being the length of the results.
+func (coll *Collection) RestoreDocs(ctx context.Context, condition []any) ([]*firestore.WriteResult, error) {
+	report, err := coll.RestoreDocsWithReport(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+	return report.Results(), report.Err()
+}
+
+// RestoreDocsWithReport is RestoreDocs reporting every doc, Succeeded being
+// the number restored.
+func (coll *Collection) RestoreDocsWithReport(ctx context.Context, condition []any) (*BulkResult, error) {
+	return coll.RestoreDocsWithOptions(ctx, condition, RestoreOptions{})
+}
+
+// RestoreDocsWithOptions is RestoreDocsWithReport recording the restores
+// as opts asks.
+func (coll *Collection) RestoreDocsWithOptions(ctx context.Context, condition []any, opts RestoreOptions) (*BulkResult, error) {
+	return run(ctx, coll, call{Op: "RestoreDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.restoreDocsReport(ctx, c.Condition, opts)
+	})
+}
+
+func (coll *Collection) restoreDocsReport(ctx context.Context, condition []any, opts RestoreOptions) (*BulkResult, error) {
+	if !hasWhere(condition) {
+		return nil, ErrRestoreWithoutCondition
+	}
+	now := coll.now()
+	condition = append([]any{NotNull(DeletedAtFieldName)}, condition...)
+	report := &BulkResult{}
+	err := coll.eachPage(ctx, condition, []string{DeletedAtFieldName, DeletedByFieldName}, bulkPageSize, func(snaps []*firestore.DocumentSnapshot) error {
+		chunkCtx, span := coll.startSpan(ctx, "RestoreDocs.chunk", "", nil)
+		chunkReport := restoreEach500Docs(chunkCtx, coll, snaps, now, opts)
+		span.End(chunkReport.Succeeded, chunkReport.Err())
+		report.merge(chunkReport)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func restoreEach500Docs(ctx context.Context, coll *Collection, snaps []*firestore.DocumentSnapshot, now time.Time, opts RestoreOptions) *BulkResult {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	batch := coll.Client.BulkWriter(ctx)
+
+	report := &BulkResult{}
+	jobs := &bulkJobs{}
+	cascadeJobs := &bulkJobs{}
+	for _, snap := range snaps {
+		updates := []firestore.Update{
+			{
+				Path:  DeletedAtFieldName,
+				Value: nil,
+			},
+			{
+				Path:  UpdatedAtFieldName,
+				Value: now,
+			},
+		}
+		if _, err := snap.DataAt(DeletedByFieldName); err == nil {
+			updates = append(updates, firestore.Update{Path: DeletedByFieldName, Value: firestore.Delete})
+		}
+		updates = append(updates, opts.updates(now)...)
+		job, err := batch.Update(snap.Ref, updates)
+		if err != nil {
+			report.add(snap.Ref.ID, "restore", nil, err)
+			continue
+		}
+		jobs.add(snap.Ref.ID, job)
+		if coll.softDeleteCascade {
+			deletedAt, _ := snap.DataAt(DeletedAtFieldName)
+			if cascadeErr := coll.cascadeDeletedAt(ctx, batch, cascadeJobs, snap.Ref, deletedAt, nil, now); cascadeErr != nil {
+				report.add(snap.Ref.ID, "cascade", nil, cascadeErr)
+			}
+		}
+	}
+	batch.End()
+
+	report.merge(jobs.report("restore"))
+	for _, entry := range cascadeJobs.report("cascade").Entries {
+		if entry.Err != nil {
+			report.add(entry.ID, entry.Op, nil, entry.Err)
+		}
+	}
+	return report
+}
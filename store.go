@@ -0,0 +1,193 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CollectionOption configures a Collection registered in a Store, e.g.
+//
+//	func(coll *Collection) error { coll.WithTimeout(5 * time.Second); return nil }
+type CollectionOption func(coll *Collection) error
+
+// Store creates the collections of an app once and hands them out by name.
+type Store struct {
+	client   *firestore.Client
+	defaults []CollectionOption
+
+	mu          sync.RWMutex
+	collections map[string]*Collection
+	errs        []error
+}
+
+// NewStore makes a Store whose collections get the defaults options applied
+// before their own, along with those of its With methods.
+func NewStore(client *firestore.Client, defaults ...CollectionOption) *Store {
+	return &Store{
+		client:      client,
+		defaults:    defaults,
+		collections: make(map[string]*Collection),
+	}
+}
+
+// withDefault adds opt to the defaults of the collections made after it.
+func (s *Store) withDefault(opt func(coll *Collection)) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaults = append(s.defaults, func(coll *Collection) error {
+		opt(coll)
+		return nil
+	})
+	return s
+}
+
+// WithExcludeDeleted makes the collections made after it leave out soft
+// deleted docs, see Collection.WithExcludeDeleted.
+func (s *Store) WithExcludeDeleted() *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithExcludeDeleted() })
+}
+
+// WithTTLField sets the TTL field of the collections made after it, see
+// Collection.WithTTLField.
+func (s *Store) WithTTLField(field string) *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithTTLField(field) })
+}
+
+// WithFieldTypes adds types to the condition value coercion of the
+// collections made after it, see Collection.WithFieldTypes.
+func (s *Store) WithFieldTypes(types map[string]FieldType) *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithFieldTypes(types) })
+}
+
+// WithResponseOptions sets the response options of the collections made
+// after it, see Collection.WithResponseOptions.
+func (s *Store) WithResponseOptions(opts ResponseOptions) *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithResponseOptions(opts) })
+}
+
+// WithTimeout sets the timeout of the collections made after it.
+func (s *Store) WithTimeout(d time.Duration) *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithTimeout(d) })
+}
+
+// WithLogger sets the logger of the collections made after it.
+func (s *Store) WithLogger(logger Logger) *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithLogger(logger) })
+}
+
+// WithTracer sets the tracer of the collections made after it.
+func (s *Store) WithTracer(tracer Tracer) *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithTracer(tracer) })
+}
+
+// WithClock sets the clock of the collections made after it.
+func (s *Store) WithClock(clock Clock) *Store {
+	return s.withDefault(func(coll *Collection) { coll.WithClock(clock) })
+}
+
+// Register adds the collection at path name. A duplicate name or a failing
+// option is recorded, see Err, and the name is left as it was.
+func (s *Store) Register(name string, opts ...CollectionOption) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collections[name]; ok {
+		s.errs = append(s.errs, errors.New(fmt.Sprintf("collection %s already registered", name)))
+		return s
+	}
+	coll, err := s.newCollection(name, opts)
+	if err != nil {
+		s.errs = append(s.errs, err)
+		return s
+	}
+	s.collections[name] = coll
+	return s
+}
+
+// Collection makes a collection at path with the store defaults then opts,
+// without registering it, e.g. for paths built per request. Each call makes
+// a new one.
+func (s *Store) Collection(path string, opts ...CollectionOption) (*Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.newCollection(path, opts)
+}
+
+func (s *Store) newCollection(path string, opts []CollectionOption) (*Collection, error) {
+	if s.client.Collection(path) == nil {
+		return nil, errors.New(fmt.Sprintf("invalid collection path: %q", path))
+	}
+	coll := CollectionWithPath(s.client, path)
+	for _, opt := range append(append([]CollectionOption{}, s.defaults...), opts...) {
+		if err := opt(coll); err != nil {
+			return nil, errors.New(fmt.Sprintf("collection %s: %v", path, err))
+		}
+	}
+	return coll, nil
+}
+
+// Err returns the errors recorded by Register.
+func (s *Store) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return errors.Join(s.errs...)
+}
+
+func (s *Store) Get(name string) (*Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	coll, ok := s.collections[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("collection %s not registered", name))
+	}
+	return coll, nil
+}
+
+// TypedCollection adds and reads the docs of a Collection as T, see
+// AddStruct and ListDocsAs. The embedded Collection keeps the map methods.
+type TypedCollection[T any] struct {
+	*Collection
+}
+
+// Typed wraps coll to add and read its docs as T.
+func Typed[T any](coll *Collection) *TypedCollection[T] {
+	return &TypedCollection[T]{coll}
+}
+
+func GetTyped[T any](s *Store, name string) (*TypedCollection[T], error) {
+	coll, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return Typed[T](coll), nil
+}
+
+// AddDocAs adds v like AddStruct.
+func (t *TypedCollection[T]) AddDocAs(uid *string, v T, docIdPrefix ...string) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	return t.AddStruct(uid, v, docIdPrefix...)
+}
+
+// AddDocAsWithId adds v like AddStructWithId.
+func (t *TypedCollection[T]) AddDocAsWithId(id *string, uid *string, v T) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	return t.AddStructWithId(id, uid, v)
+}
+
+func (t *TypedCollection[T]) ListDocsAs(condition []any) ([]T, error) {
+	return ListDocsAs[T](t.Collection, condition)
+}
+
+func (t *TypedCollection[T]) GetDocAs(id string) (*T, error) {
+	return GetDocAs[T](t.Collection, id)
+}
+
+// FindDocAs returns the first doc matching condition as T, nil when none
+// does.
+func (t *TypedCollection[T]) FindDocAs(condition []any) (*T, error) {
+	docs, err := ListDocsAs[T](t.Collection, withOptions(condition, map[string]any{"limit": 1}))
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	return &docs[0], nil
+}
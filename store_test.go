@@ -0,0 +1,65 @@
+package cffirestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreDefaults(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	store := NewStore(testClient(t)).
+		WithExcludeDeleted().
+		WithTTLField("expiresAt").
+		WithFieldTypes(map[string]FieldType{"age": FieldTypeInt}).
+		WithTimeout(5*time.Second).
+		WithClock(clock).
+		Register("users").
+		Register("orders", func(coll *Collection) error {
+			coll.WithTimeout(time.Second)
+			return nil
+		})
+	if err := store.Err(); err != nil {
+		t.Fatal(err)
+	}
+	users, err := store.Get("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := store.Get("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := store.Collection("tenants/t1/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, coll := range []*Collection{users, orders, logs} {
+		if !coll.excludeDeleted || coll.ttlField != "expiresAt" || coll.fieldTypes["age"] != FieldTypeInt || coll.clock != clock {
+			t.Errorf("%s did not get the store defaults", coll.Path)
+		}
+	}
+	if users.timeout != 5*time.Second || orders.timeout != time.Second {
+		t.Errorf("timeouts = %v, %v, want the default then the registered one", users.timeout, orders.timeout)
+	}
+	if logs.Path != "tenants/t1/logs" {
+		t.Errorf("Collection path = %q", logs.Path)
+	}
+	if again, _ := store.Collection("tenants/t1/logs"); again == logs {
+		t.Error("Collection returned the same collection twice")
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	store := NewStore(testClient(t)).Register("users").Register("users").Register("")
+	if err := store.Err(); err == nil {
+		t.Error("duplicate and empty registrations recorded no error")
+	}
+	if _, err := store.Get("orders"); err == nil {
+		t.Error("Get of an unregistered name returned no error")
+	}
+	for _, path := range []string{"", "users/u1", "/"} {
+		if _, err := store.Collection(path); err == nil {
+			t.Errorf("Collection(%q) returned no error", path)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cffirestore
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+)
+
+// ListSubcollections returns the sorted ids of the subcollections of doc id.
+func (coll *Collection) ListSubcollections(ctx context.Context, id string) ([]string, error) {
+	return run(ctx, coll, call{Op: "ListSubcollections", DocID: id}, func(ctx context.Context, c *OperationCall) ([]string, error) {
+		ctx, cancel := coll.withTimeout(ctx)
+		defer cancel()
+		ref := coll.ref.Doc(c.DocID)
+		if _, err := ref.Get(ctx); err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil, docNotFound(c.DocID)
+			}
+			return nil, err
+		}
+		refs, err := ref.Collections(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, len(refs))
+		for i, ref := range refs {
+			ids[i] = ref.ID
+		}
+		sort.Strings(ids)
+		return ids, nil
+	})
+}
+
+// ListRootCollections returns the sorted ids of the root collections of the
+// collection's database.
+func (coll *Collection) ListRootCollections(ctx context.Context) ([]string, error) {
+	return run(ctx, coll, call{Op: "ListRootCollections"}, func(ctx context.Context, c *OperationCall) ([]string, error) {
+		ctx, cancel := coll.withTimeout(ctx)
+		defer cancel()
+		refs, err := coll.Client.Collections(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, len(refs))
+		for i, ref := range refs {
+			ids[i] = ref.ID
+		}
+		sort.Strings(ids)
+		return ids, nil
+	})
+}
+
+// Sub returns the subcollection name of doc id, with the settings of coll
+// that are not about its fields: middlewares, tracer, logger, clock,
+// timeout, page and query caps, index recorder, protected fields mode,
+// deleted docs exclusion, the response options but ExcludeFields, and a
+// query cache of the same TTL.
+// Schema, encryption, unique, keyword, normalized, typed and TTL fields,
+// soft delete cascades and append-only logs are left to configure.
+func (coll *Collection) Sub(id string, name string) *Collection {
+	sub := CollectionWithPath(coll.Client, coll.Path+"/"+id+"/"+name)
+	sub.middlewares = append([]Middleware{}, coll.middlewares...)
+	sub.tracer = coll.tracer
+	sub.logger = coll.logger
+	sub.clock = coll.clock
+	sub.timeout = coll.timeout
+	sub.maxPerPage = coll.maxPerPage
+	sub.maxCount = coll.maxCount
+	sub.inconsistentCount = coll.inconsistentCount
+	sub.maxDocs = coll.maxDocs
+	sub.truncationMode = coll.truncationMode
+	sub.indexRecorder = coll.indexRecorder
+	sub.protectedFieldsMode = coll.protectedFieldsMode
+	sub.excludeDeleted = coll.excludeDeleted
+	if coll.responseOptions != nil {
+		opts := *coll.responseOptions
+		opts.ExcludeFields = nil
+		sub.responseOptions = &opts
+	}
+	if coll.queryCache != nil {
+		sub.WithQueryCache(coll.queryCache.ttl)
+	}
+	return sub
+}
@@ -0,0 +1,23 @@
+package cffirestore
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout sets a deadline for every Firestore call of the collection
+// whose context has none. Bulk operations apply it per chunk of writes.
+func (coll *Collection) WithTimeout(d time.Duration) *Collection {
+	coll.timeout = d
+	return coll
+}
+
+func (coll *Collection) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if coll.timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, coll.timeout)
+}
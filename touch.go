@@ -0,0 +1,94 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// Touch sets only the updatedAt of doc id.
+func (coll *Collection) Touch(id string) (*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "Touch", DocID: id}, func(ctx context.Context, c *OperationCall) (*firestore.WriteResult, error) {
+		ctx, cancel := coll.withTimeout(ctx)
+		defer cancel()
+		result, err := coll.ref.Doc(c.DocID).Update(ctx, []firestore.Update{
+			{
+				Path:  UpdatedAtFieldName,
+				Value: coll.now(),
+			},
+		})
+		if status.Code(err) == codes.NotFound {
+			return nil, docNotFound(c.DocID)
+		}
+		return result, err
+	})
+}
+
+// TouchDocs sets only the updatedAt of every doc matching condition.
+func (coll *Collection) TouchDocs(condition []any) ([]*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "TouchDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]*firestore.WriteResult, error) {
+		return coll.touchDocs(ctx, c.Condition)
+	})
+}
+
+func (coll *Collection) TouchDocsWithReport(condition []any) (*BulkResult, error) {
+	return run(context.Background(), coll, call{Op: "TouchDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.touchDocsReport(ctx, c.Condition)
+	})
+}
+
+func (coll *Collection) touchDocs(ctx context.Context, condition []any) ([]*firestore.WriteResult, error) {
+	report, err := coll.touchDocsReport(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+	return report.Results(), report.Err()
+}
+
+func (coll *Collection) touchDocsReport(ctx context.Context, condition []any) (*BulkResult, error) {
+	now := coll.now()
+	report := &BulkResult{}
+	found := false
+	err := coll.eachPage(ctx, condition, []string{}, bulkPageSize, func(snaps []*firestore.DocumentSnapshot) error {
+		found = true
+		chunkCtx, span := coll.startSpan(ctx, "TouchDocs.chunk", "", nil)
+		chunkReport := coll.touchEach500Docs(chunkCtx, snaps, now)
+		span.End(chunkReport.Succeeded, chunkReport.Err())
+		report.merge(chunkReport)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("not found")
+	}
+	return report, nil
+}
+
+func (coll *Collection) touchEach500Docs(ctx context.Context, snaps []*firestore.DocumentSnapshot, now time.Time) *BulkResult {
+	ctx, cancel := coll.withTimeout(ctx)
+	defer cancel()
+	batch := coll.Client.BulkWriter(ctx)
+	jobs := &bulkJobs{}
+	report := &BulkResult{}
+	for _, snap := range snaps {
+		job, err := batch.Update(snap.Ref, []firestore.Update{
+			{
+				Path:  UpdatedAtFieldName,
+				Value: now,
+			},
+		})
+		if err != nil {
+			report.add(snap.Ref.ID, "touch", nil, err)
+			continue
+		}
+		jobs.add(snap.Ref.ID, job)
+	}
+	batch.End()
+	report.merge(jobs.report("touch"))
+	return report
+}
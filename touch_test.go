@@ -0,0 +1,34 @@
+package cffirestore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTouchDocsPages(t *testing.T) {
+	coll := emulatorCollection(t)
+	tracer := &recordingTracer{}
+	coll.WithTracer(tracer)
+	seedDocs(t, coll, 1234)
+
+	results, err := coll.TouchDocs([]any{[]any{"n", ">=", 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1234 {
+		t.Errorf("TouchDocs wrote %d docs, want 1234", len(results))
+	}
+	if pages := tracer.counts("cffirestore.TouchDocs.chunk"); !reflect.DeepEqual(pages, []int{500, 500, 234}) {
+		t.Errorf("TouchDocs pages = %v, want [500 500 234]", pages)
+	}
+	count, err := coll.CountDocs([]any{[]any{UpdatedAtFieldName, "!=", nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1234 {
+		t.Errorf("%d docs were touched, want 1234", count)
+	}
+	if _, err := coll.TouchDocs([]any{[]any{"n", "<", 0}}); err == nil {
+		t.Error("TouchDocs matching no doc returned no error")
+	}
+}
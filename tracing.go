@@ -0,0 +1,64 @@
+package cffirestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Tracer starts spans around Collection operations. See the cfotel package
+// for an OpenTelemetry implementation.
+type Tracer interface {
+	Start(ctx context.Context, info SpanInfo) (context.Context, Span)
+}
+
+type SpanInfo struct {
+	// Name is like "cffirestore.ListDocs".
+	Name      string
+	Path      string
+	DocID     string
+	Condition string
+}
+
+type Span interface {
+	End(docCount int, err error)
+}
+
+// DefaultTracer is used by collections without their own tracer.
+var DefaultTracer Tracer
+
+func (coll *Collection) WithTracer(tracer Tracer) *Collection {
+	coll.tracer = tracer
+	return coll
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(int, error) {}
+
+func (coll *Collection) startSpan(ctx context.Context, name string, docID string, condition []any) (context.Context, Span) {
+	tracer := coll.tracer
+	if tracer == nil {
+		tracer = DefaultTracer
+	}
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.Start(ctx, SpanInfo{
+		Name:      "cffirestore." + name,
+		Path:      coll.Path,
+		DocID:     docID,
+		Condition: conditionSummary(condition),
+	})
+}
+
+func conditionSummary(condition []any) string {
+	if len(condition) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(condition))
+	for _, c := range condition {
+		parts = append(parts, fmt.Sprintf("%v", c))
+	}
+	return strings.Join(parts, " ")
+}
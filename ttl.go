@@ -0,0 +1,96 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"time"
+)
+
+// WithTTLField names the field of the collection's Firestore TTL policy.
+// Since TTL deletes lag, queries, counts and pages then only match docs
+// whose field is after now. That filter orders them on field first, ahead of
+// their own orderBy, so they can't have an inequality on another field, and
+// it leaves out docs without field, as Firestore does for any inequality.
+// Add IncludeExpired to a condition to match expired docs too.
+func (coll *Collection) WithTTLField(field string) *Collection {
+	coll.ttlField = field
+	return coll
+}
+
+type includeExpiredCondition struct{}
+
+// IncludeExpired makes a condition also match the expired docs of a
+// collection with a TTL field, e.g. []any{IncludeExpired, ...}.
+var IncludeExpired Condition = includeExpiredCondition{}
+
+func (includeExpiredCondition) canonical() []any {
+	return nil
+}
+
+func (includeExpiredCondition) expand(coll *Collection, plan *queryPlan) error {
+	plan.includeExpired = true
+	return nil
+}
+
+// planTTL adds the not expired filter to plan.
+func (coll *Collection) planTTL(plan *queryPlan) {
+	if coll.ttlField == "" || plan.includeExpired {
+		return
+	}
+	plan.ttlField = coll.ttlField
+	plan.where(coll.ttlField, ">", coll.now())
+	plan.requireOrderBy(coll.ttlField)
+}
+
+// cacheFor is how long result can be cached for, at most ttl: until its
+// first doc expires, so a cached result keyed by condition doesn't outlive
+// the now of its TTL filter.
+func (coll *Collection) cacheFor(result any, ttl time.Duration) time.Duration {
+	if coll.ttlField == "" {
+		return ttl
+	}
+	var docs []map[string]any
+	switch r := result.(type) {
+	case []map[string]any:
+		docs = r
+	case *PaginateResult:
+		docs = r.Docs
+	case *TokenPaginateResult:
+		docs = r.Docs
+	}
+	now := coll.now()
+	for _, doc := range docs {
+		val, _ := GetPathAny(doc, coll.ttlField)
+		if expires, ok := val.(time.Time); ok && expires.Sub(now) < ttl {
+			ttl = expires.Sub(now)
+		}
+	}
+	return ttl
+}
+
+var errNoTTLField = errors.New("no TTL field configured")
+
+// AddDocWithTTL adds v like AddDoc with the TTL field set to ttl from now.
+func (coll *Collection) AddDocWithTTL(uid *string, v map[string]any, ttl time.Duration) (*firestore.DocumentRef, *firestore.WriteResult, error) {
+	var ref *firestore.DocumentRef
+	result, err := run(context.Background(), coll, call{Op: "AddDoc", Data: v}, func(ctx context.Context, c *OperationCall) (result *firestore.WriteResult, err error) {
+		if coll.ttlField == "" {
+			return nil, errNoTTLField
+		}
+		c.Data[coll.ttlField] = coll.now().Add(ttl)
+		ref, result, err = coll.addDoc(ctx, uid, c.Data)
+		return result, err
+	})
+	return ref, result, err
+}
+
+// SetTTL sets the TTL field of doc id to ttl from now.
+func (coll *Collection) SetTTL(id string, ttl time.Duration) (*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "SetTTL", DocID: id}, func(ctx context.Context, c *OperationCall) (*firestore.WriteResult, error) {
+		if coll.ttlField == "" {
+			return nil, errNoTTLField
+		}
+		return coll.updateDoc(ctx, c.DocID, map[string]any{coll.ttlField: coll.now().Add(ttl)}, UpdateOptions{})
+	})
+}
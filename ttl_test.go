@@ -0,0 +1,129 @@
+package cffirestore
+
+import (
+	"github.com/samber/lo"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTLFilter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	coll := testCollection(t).WithClock(NewFakeClock(now)).WithTTLField("expiresAt")
+	query, err := coll.makeQuery([]any{[]any{"status", "==", "open"}, map[string]any{"orderBy": "name:desc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sq := structuredQuery(t, query)
+	filters := sq.GetWhere().GetCompositeFilter().GetFilters()
+	if len(filters) != 2 {
+		t.Fatalf("where = %v, want the status and TTL filters", sq.GetWhere())
+	}
+	ttl := filters[1].GetFieldFilter()
+	if ttl.GetField().GetFieldPath() != "expiresAt" || ttl.GetOp().String() != "GREATER_THAN" || !ttl.GetValue().GetTimestampValue().AsTime().Equal(now) {
+		t.Errorf("TTL filter = %v, want expiresAt > %v", ttl, now)
+	}
+	if got, want := orderByKeys(sq), []string{"expiresAt:asc", "name:desc", "__name__:desc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("orderBy = %v, want %v", got, want)
+	}
+
+	query, err = coll.makeQuery([]any{IncludeExpired, []any{"age", ">", 18}, map[string]any{"orderBy": "age:desc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sq = structuredQuery(t, query)
+	if filter := sq.GetWhere().GetFieldFilter(); filter.GetField().GetFieldPath() != "age" {
+		t.Errorf("where with IncludeExpired = %v, want only the age filter", sq.GetWhere())
+	}
+	if got, want := orderByKeys(sq), []string{"age:desc", "__name__:desc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("orderBy with IncludeExpired = %v, want %v", got, want)
+	}
+
+	for _, condition := range [][]any{
+		{[]any{"age", ">", 18}},
+		{Or([]any{"status", "==", "open"}, []any{"age", "<", 18})},
+	} {
+		if _, err := coll.makeQuery(condition); err == nil || !strings.Contains(err.Error(), "ttl expiresAt") {
+			t.Errorf("makeQuery(%v) error = %v, want the TTL inequality one", condition, err)
+		}
+	}
+	if _, err := coll.makeQuery([]any{Between("expiresAt", now, now.Add(time.Hour))}); err != nil {
+		t.Errorf("makeQuery(Between(expiresAt)) = %v", err)
+	}
+}
+
+func TestMemoryCollectionTTL(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	m := NewMemoryCollection("tests")
+	m.Config().WithClock(clock).WithTTLField("expiresAt")
+	for id, doc := range map[string]map[string]any{
+		"expired": {"n": int64(1), "expiresAt": now.Add(-time.Minute)},
+		"soon":    {"n": int64(2), "expiresAt": now.Add(time.Minute)},
+		"later":   {"n": int64(3), "expiresAt": now.Add(time.Hour)},
+		"never":   {"n": int64(4)},
+	} {
+		id := id
+		if _, _, err := m.AddDocWithId(&id, nil, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(condition []any) []string {
+		t.Helper()
+		docs, err := m.ListDocs(condition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lo.Map(docs, func(doc map[string]any, _ int) string { return doc["_id"].(string) })
+	}
+	count := func(condition []any) int {
+		t.Helper()
+		n, err := m.CountDocs(condition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	orderByN := map[string]any{"orderBy": "n:desc"}
+	if got, want := ids([]any{orderByN}), []string{"soon", "later"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("docs = %v, want %v", got, want)
+	}
+	if n := count(nil); n != 2 {
+		t.Errorf("count = %d, want 2", n)
+	}
+	if got, want := ids([]any{IncludeExpired, orderByN}), []string{"never", "later", "soon", "expired"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("docs with IncludeExpired = %v, want %v", got, want)
+	}
+	if n := count([]any{IncludeExpired}); n != 4 {
+		t.Errorf("count with IncludeExpired = %d, want 4", n)
+	}
+	clock.Advance(2 * time.Minute)
+	if got, want := ids([]any{orderByN}), []string{"later"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("docs later = %v, want %v", got, want)
+	}
+	if n := count(nil); n != 1 {
+		t.Errorf("count later = %d, want 1", n)
+	}
+}
+
+func TestCacheForFirstExpiry(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	coll := (&Collection{}).WithClock(NewFakeClock(now)).WithTTLField("expiresAt")
+	docs := []map[string]any{
+		{"expiresAt": now.Add(time.Hour)},
+		{"expiresAt": now.Add(10 * time.Second)},
+		{},
+	}
+	for _, result := range []any{docs, &PaginateResult{Docs: docs}, &TokenPaginateResult{Docs: docs}} {
+		if got := coll.cacheFor(result, time.Minute); got != 10*time.Second {
+			t.Errorf("%T cached for %v, want 10s", result, got)
+		}
+	}
+	if got := coll.cacheFor(3, time.Minute); got != time.Minute {
+		t.Errorf("count cached for %v, want 1m", got)
+	}
+	if got := (&Collection{}).cacheFor(docs, time.Minute); got != time.Minute {
+		t.Errorf("without TTL field cached for %v, want 1m", got)
+	}
+}
@@ -0,0 +1,163 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Tx reads and writes the docs of the collections of one client in a
+// firestore transaction, see RunTransaction. As in firestore, every read
+// must come before the first write, and writes only land when fn returns
+// nil.
+type Tx struct {
+	ctx     context.Context
+	client  *firestore.Client
+	tx      *firestore.Transaction
+	written map[*Collection]bool
+}
+
+// RunTransaction runs fn in a firestore transaction of client, again when
+// the transaction retries, so fn should have no other side effects.
+func RunTransaction(ctx context.Context, client *firestore.Client, fn func(ctx context.Context, tx *Tx) error, opts ...firestore.TransactionOption) error {
+	var written map[*Collection]bool
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		t := &Tx{ctx: ctx, client: client, tx: tx, written: make(map[*Collection]bool)}
+		written = t.written
+		return fn(ctx, t)
+	}, opts...)
+	if err == nil {
+		for coll := range written {
+			if coll.queryCache != nil {
+				coll.queryCache.clear()
+			}
+		}
+	}
+	return err
+}
+
+// RunTransaction is the package RunTransaction on the client of coll, tx
+// works with the other collections of that client too.
+func (coll *Collection) RunTransaction(ctx context.Context, fn func(ctx context.Context, tx *Tx) error, opts ...firestore.TransactionOption) error {
+	return RunTransaction(ctx, coll.Client, fn, opts...)
+}
+
+// Transaction returns the underlying firestore transaction.
+func (t *Tx) Transaction() *firestore.Transaction {
+	return t.tx
+}
+
+func (t *Tx) GetDoc(coll *Collection, id string) (map[string]any, error) {
+	return run(t.ctx, coll, call{Op: "GetDoc", DocID: id}, func(ctx context.Context, c *OperationCall) (map[string]any, error) {
+		if err := t.check(coll, false); err != nil {
+			return nil, err
+		}
+		snap, err := t.tx.Get(coll.ref.Doc(c.DocID))
+		if status.Code(err) == codes.NotFound {
+			return nil, docNotFound(c.DocID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		data := coll.docResponse(snap)
+		if err := coll.decryptFields(data); err != nil {
+			return nil, err
+		}
+		return coll.shapeDoc(data), nil
+	})
+}
+
+func (t *Tx) ListDocs(coll *Collection, condition []any) ([]map[string]any, error) {
+	return run(t.ctx, coll, call{Op: "ListDocs", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		if err := t.check(coll, false); err != nil {
+			return nil, err
+		}
+		return coll.listDocs(withReadTx(ctx, t.tx), c.Condition)
+	})
+}
+
+func (t *Tx) AddDoc(coll *Collection, uid *string, v map[string]any, docIdPrefix ...string) (*firestore.DocumentRef, error) {
+	id := coll.ref.NewDoc().ID
+	if len(docIdPrefix) > 0 {
+		id = docIdPrefix[0] + id
+	}
+	return t.AddDocWithId(coll, &id, uid, v)
+}
+
+func (t *Tx) AddDocWithId(coll *Collection, id *string, uid *string, v map[string]any) (*firestore.DocumentRef, error) {
+	return run(t.ctx, coll, call{Op: "AddDocWithId", DocID: lo.FromPtr(id), Data: v}, func(ctx context.Context, c *OperationCall) (*firestore.DocumentRef, error) {
+		if err := t.check(coll, true); err != nil {
+			return nil, err
+		}
+		ref, stored, err := coll.prepareNewDoc(c.docIDPtr(), uid, c.Data, AddOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return ref, t.tx.Set(ref, stored)
+	})
+}
+
+// UpdateDoc merges data into doc id like Collection.UpdateDoc. Search
+// keywords and a whole doc schema read the doc first, so it must then come
+// before the writes.
+func (t *Tx) UpdateDoc(coll *Collection, id string, data map[string]any) error {
+	_, err := run(t.ctx, coll, call{Op: "UpdateDoc", DocID: id, Data: data}, func(ctx context.Context, c *OperationCall) (any, error) {
+		if err := t.check(coll, true); err != nil {
+			return nil, err
+		}
+		data, err := coll.protectUpdate(c.Data, false)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := coll.prepareUpdate(withReadTx(ctx, t.tx), c.DocID, data, UpdateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return nil, t.tx.Set(coll.ref.Doc(c.DocID), stored, firestore.MergeAll)
+	})
+	return err
+}
+
+// DeleteDoc deletes doc id like Collection.DeleteDoc, the transaction fails
+// when it does not exist. A cascading soft delete is not supported.
+func (t *Tx) DeleteDoc(coll *Collection, id string, isSoftDelete ...bool) error {
+	softDelete := len(isSoftDelete) > 0 && isSoftDelete[0]
+	_, err := run(t.ctx, coll, call{Op: "DeleteDoc", DocID: id}, func(ctx context.Context, c *OperationCall) (any, error) {
+		if err := t.check(coll, true); err != nil {
+			return nil, err
+		}
+		ref := coll.ref.Doc(c.DocID)
+		if !softDelete {
+			return nil, t.tx.Delete(ref, firestore.Exists)
+		}
+		if coll.softDeleteCascade {
+			return nil, errors.New("cascading soft delete is not supported in a transaction")
+		}
+		now := coll.now()
+		return nil, t.tx.Update(ref, []firestore.Update{
+			{Path: DeletedAtFieldName, Value: now},
+			{Path: UpdatedAtFieldName, Value: now},
+		})
+	})
+	return err
+}
+
+// check rejects a collection of another client, and writes to a collection
+// with unique fields, which keeps them in transactions of its own.
+func (t *Tx) check(coll *Collection, write bool) error {
+	if coll.Client != t.client {
+		return errors.New(fmt.Sprintf("collection %s is not of the transaction client", coll.Path))
+	}
+	if !write {
+		return nil
+	}
+	if len(coll.uniqueFields) > 0 {
+		return errors.New(fmt.Sprintf("collection %s has unique fields, write it outside the transaction", coll.Path))
+	}
+	t.written[coll] = true
+	return nil
+}
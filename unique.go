@@ -0,0 +1,281 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrUniqueViolation = errors.New("unique violation")
+
+// UniqueViolationError is returned when a unique field value is already
+// reserved by another doc. errors.Is(err, ErrUniqueViolation) matches it.
+type UniqueViolationError struct {
+	Field string
+	Value any
+	// OwnerID is the id of the doc holding the value.
+	OwnerID string
+}
+
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("%s: %s %v is taken by doc %s", ErrUniqueViolation, e.Field, e.Value, e.OwnerID)
+}
+
+func (e *UniqueViolationError) Unwrap() error {
+	return ErrUniqueViolation
+}
+
+const uniqueOwnerFieldName = "docId"
+
+// WithUniqueField makes AddDocWithId, UpdateDoc and hard deletes maintain a
+// reservation doc per value of each given top level field in the
+// "<path>__unique_<field>" collection, so two docs can't hold the same
+// value. Values are compared trimmed and lowercased. Soft deleted docs keep
+// their reservations.
+func (coll *Collection) WithUniqueField(fields ...string) *Collection {
+	coll.uniqueFields = lo.Uniq(append(coll.uniqueFields, fields...))
+	return coll
+}
+
+func (coll *Collection) uniqueRef(field string, key string) *firestore.DocumentRef {
+	return coll.Client.Collection(coll.Path + "__unique_" + field).Doc(key)
+}
+
+// uniqueKey returns the reservation doc id of val, "" when val reserves
+// nothing.
+func uniqueKey(val any) string {
+	if val == nil {
+		return ""
+	}
+	key := strings.ToLower(strings.TrimSpace(fmt.Sprint(val)))
+	if key == "" {
+		return ""
+	}
+	return url.PathEscape(key)
+}
+
+// writeUnique runs write in a transaction that moves the unique field
+// reservations of doc id from its current values to the ones in data. With
+// replace, fields missing from data release their reservation. write runs
+// before the reservation writes, so it may still read in tx.
+func (coll *Collection) writeUnique(ctx context.Context, id string, data map[string]any, replace bool, write func(tx *firestore.Transaction, ref *firestore.DocumentRef) error) (*firestore.WriteResult, error) {
+	ref := coll.ref.Doc(id)
+	err := coll.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		old := map[string]any{}
+		snap, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if snap != nil && snap.Exists() {
+			old = snap.Data()
+		}
+
+		reserve := make(map[*firestore.DocumentRef]any)
+		release := make([]*firestore.DocumentRef, 0)
+		for _, field := range coll.uniqueFields {
+			newVal, ok := data[field]
+			if !ok && !replace {
+				continue
+			}
+			oldKey, newKey := uniqueKey(old[field]), uniqueKey(newVal)
+			if oldKey == newKey {
+				continue
+			}
+			if newKey != "" {
+				resRef := coll.uniqueRef(field, newKey)
+				resSnap, err := tx.Get(resRef)
+				if err != nil && status.Code(err) != codes.NotFound {
+					return err
+				}
+				if resSnap != nil && resSnap.Exists() {
+					if owner, _ := resSnap.DataAt(uniqueOwnerFieldName); owner != id {
+						return &UniqueViolationError{Field: field, Value: newVal, OwnerID: fmt.Sprint(owner)}
+					}
+				}
+				reserve[resRef] = newVal
+			}
+			if oldKey != "" {
+				release = append(release, coll.uniqueRef(field, oldKey))
+			}
+		}
+
+		if err := write(tx, ref); err != nil {
+			return err
+		}
+		for resRef, val := range reserve {
+			if err := tx.Set(resRef, map[string]any{
+				uniqueOwnerFieldName: id,
+				"value":              val,
+				CreatedAtFieldName:   coll.now(),
+			}); err != nil {
+				return err
+			}
+		}
+		for _, resRef := range release {
+			if err := tx.Delete(resRef); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	snap, err := ref.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &firestore.WriteResult{UpdateTime: snap.UpdateTime}, nil
+}
+
+// releaseUnique deletes the reservations of doc values still owned by id.
+func (coll *Collection) releaseUnique(ctx context.Context, id string, doc map[string]any) error {
+	return coll.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		owned := make([]*firestore.DocumentRef, 0)
+		for _, field := range coll.uniqueFields {
+			key := uniqueKey(doc[field])
+			if key == "" {
+				continue
+			}
+			resRef := coll.uniqueRef(field, key)
+			resSnap, err := tx.Get(resRef)
+			if status.Code(err) == codes.NotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if owner, _ := resSnap.DataAt(uniqueOwnerFieldName); owner == id {
+				owned = append(owned, resRef)
+			}
+		}
+		for _, resRef := range owned {
+			if err := tx.Delete(resRef); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (coll *Collection) uniqueUpdateField(updates []firestore.Update) (string, bool) {
+	for _, update := range updates {
+		if lo.Contains(coll.uniqueFields, update.Path) {
+			return update.Path, true
+		}
+	}
+	return "", false
+}
+
+// uniqueDeepField returns the unique field a field path update writes,
+// itself or by replacing a map holding it.
+func (coll *Collection) uniqueDeepField(updates []firestore.Update) (string, bool) {
+	for _, update := range updates {
+		path := update.Path
+		if len(update.FieldPath) > 0 {
+			path = strings.Join(update.FieldPath, ".")
+		}
+		for _, field := range coll.uniqueFields {
+			if field == path || strings.HasPrefix(field, path+".") {
+				return field, true
+			}
+		}
+	}
+	return "", false
+}
+
+type UniqueFieldReport struct {
+	// Duplicates maps each value held by more than one doc to the doc ids,
+	// oldest first. The oldest doc keeps the reservation.
+	Duplicates map[string][]string `json:"duplicates"`
+	// Missing lists the values that had no reservation.
+	Missing []string `json:"missing"`
+	// Reserved is the number of reservations created by repair.
+	Reserved int `json:"reserved"`
+}
+
+// BackfillUniqueField scans the collection for field values, reporting
+// duplicates and values without a reservation. With repair the missing
+// reservations are created for the oldest doc of each value; duplicates are
+// only reported.
+func (coll *Collection) BackfillUniqueField(ctx context.Context, field string, repair bool) (*UniqueFieldReport, error) {
+	return run(ctx, coll, call{Op: "BackfillUniqueField"}, func(ctx context.Context, c *OperationCall) (*UniqueFieldReport, error) {
+		return coll.backfillUniqueField(ctx, field, repair)
+	})
+}
+
+func (coll *Collection) backfillUniqueField(ctx context.Context, field string, repair bool) (*UniqueFieldReport, error) {
+	type owner struct {
+		id        string
+		val       any
+		createdAt time.Time
+	}
+	owners := make(map[string][]owner)
+	iter := coll.ref.Select(field, CreatedAtFieldName).Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data := snap.Data()
+		key := uniqueKey(data[field])
+		if key == "" {
+			continue
+		}
+		createdAt, _ := data[CreatedAtFieldName].(time.Time)
+		owners[key] = append(owners[key], owner{snap.Ref.ID, data[field], createdAt})
+	}
+
+	report := &UniqueFieldReport{Duplicates: map[string][]string{}, Missing: []string{}}
+	keys := lo.Keys(owners)
+	sort.Strings(keys)
+	for _, key := range keys {
+		docs := owners[key]
+		sort.SliceStable(docs, func(i, j int) bool {
+			if !docs[i].createdAt.Equal(docs[j].createdAt) {
+				return docs[i].createdAt.Before(docs[j].createdAt)
+			}
+			return docs[i].id < docs[j].id
+		})
+		if len(docs) > 1 {
+			report.Duplicates[key] = lo.Map(docs, func(o owner, _ int) string { return o.id })
+		}
+		resRef := coll.uniqueRef(field, key)
+		_, err := resRef.Get(ctx)
+		if err == nil {
+			continue
+		}
+		if status.Code(err) != codes.NotFound {
+			return nil, err
+		}
+		report.Missing = append(report.Missing, key)
+		if !repair {
+			continue
+		}
+		_, err = resRef.Create(ctx, map[string]any{
+			uniqueOwnerFieldName: docs[0].id,
+			"value":              docs[0].val,
+			CreatedAtFieldName:   coll.now(),
+		})
+		if err != nil && status.Code(err) != codes.AlreadyExists {
+			return nil, err
+		}
+		if err == nil {
+			report.Reserved++
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,124 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/iterator"
+)
+
+type allDocsCondition struct{}
+
+// AllDocs lets UpdateDocs run without any where clause, e.g.
+// []any{AllDocs}. Without it an empty condition is refused.
+var AllDocs Condition = allDocsCondition{}
+
+func (allDocsCondition) canonical() []any {
+	return nil
+}
+
+func (allDocsCondition) expand(*Collection, *queryPlan) error {
+	return nil
+}
+
+func hasWhere(condition []any) bool {
+	for idx, c := range condition {
+		if c == AllDocs {
+			return true
+		}
+		if _, ok := c.(map[string]any); ok && idx == len(condition)-1 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// UpdateDocs merges the same data into every doc matching condition and
+// stamps updatedAt.
+func (coll *Collection) UpdateDocs(ctx context.Context, condition []any, data map[string]any) ([]*firestore.WriteResult, error) {
+	return run(ctx, coll, call{Op: "UpdateDocs", Condition: condition, Data: data}, func(ctx context.Context, c *OperationCall) ([]*firestore.WriteResult, error) {
+		return coll.updateDocs(ctx, c.Condition, c.Data)
+	})
+}
+
+func (coll *Collection) UpdateDocsWithReport(ctx context.Context, condition []any, data map[string]any) (*BulkResult, error) {
+	return run(ctx, coll, call{Op: "UpdateDocs", Condition: condition, Data: data}, func(ctx context.Context, c *OperationCall) (*BulkResult, error) {
+		return coll.updateDocsReport(ctx, c.Condition, c.Data)
+	})
+}
+
+func (coll *Collection) updateDocs(ctx context.Context, condition []any, data map[string]any) ([]*firestore.WriteResult, error) {
+	report, err := coll.updateDocsReport(ctx, condition, data)
+	if err != nil {
+		return nil, err
+	}
+	return report.Results(), report.Err()
+}
+
+func (coll *Collection) updateDocsReport(ctx context.Context, condition []any, data map[string]any) (*BulkResult, error) {
+	if !hasWhere(condition) {
+		return nil, errors.New("empty condition, pass AllDocs to update every doc")
+	}
+	data, err := coll.protectUpdate(data, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range coll.uniqueFields {
+		if _, ok := data[field]; ok {
+			return nil, errors.New(fmt.Sprintf("unique field %s cannot be set by UpdateDocs", field))
+		}
+	}
+	if coll.touchesKeywordFields(data) {
+		return nil, errors.New("search keyword source fields cannot be set by UpdateDocs, use BatchDocs")
+	}
+	dropMetadata(data)
+	if err := encodeValues(data); err != nil {
+		return nil, err
+	}
+	data[UpdatedAtFieldName] = coll.now()
+	coll.normalizeFields(data)
+	if err := coll.validateAgainstSchema(data, true); err != nil {
+		return nil, err
+	}
+	stored, err := coll.encryptFields(data)
+	if err != nil {
+		return nil, err
+	}
+	query, err := coll.makeQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := coll.Client.BulkWriter(ctx)
+	jobs := &bulkJobs{}
+	report := &BulkResult{}
+	var queryErr error
+	iter := query.Select().Documents(ctx)
+	pending := 0
+	for {
+		snap, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			queryErr = err
+			break
+		}
+		job, err := batch.Set(snap.Ref, stored, firestore.MergeAll)
+		if err != nil {
+			report.add(snap.Ref.ID, "update", nil, err)
+			continue
+		}
+		jobs.add(snap.Ref.ID, job)
+		if pending++; pending == 500 {
+			batch.Flush()
+			pending = 0
+		}
+	}
+	iter.Stop()
+	batch.End()
+	report.merge(jobs.report("update"))
+	return report, queryErr
+}
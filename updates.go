@@ -0,0 +1,137 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+)
+
+// DeleteField can be used as a value in FlattenToUpdates data to remove the
+// field from the document.
+var DeleteField = firestore.Delete
+
+// FlattenToUpdates converts nested maps into field path updates, so only the
+// given leaves are written. Slices are written whole, nil leaves are stored
+// as null and empty maps are stored as empty maps.
+func FlattenToUpdates(data map[string]any) []firestore.Update {
+	updates := make([]firestore.Update, 0)
+	flattenToUpdates(data, nil, &updates)
+	return updates
+}
+
+func flattenToUpdates(data map[string]any, prefix firestore.FieldPath, updates *[]firestore.Update) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := append(append(firestore.FieldPath{}, prefix...), key)
+		if nested, ok := data[key].(map[string]any); ok && len(nested) > 0 {
+			flattenToUpdates(nested, path, updates)
+			continue
+		}
+		*updates = append(*updates, firestore.Update{
+			FieldPath: path,
+			Value:     data[key],
+		})
+	}
+}
+
+// UpdateDocDeep updates only the leaves of data, leaving sibling fields of
+// nested maps untouched. The doc must exist. Unique fields cannot be
+// changed, use UpdateDoc. The doc with data merged in is validated against
+// the schema, see WithJSONSchema.
+func (coll *Collection) UpdateDocDeep(id string, data map[string]any) (*firestore.WriteResult, error) {
+	return run(context.Background(), coll, call{Op: "UpdateDocDeep", DocID: id, Data: data}, func(ctx context.Context, c *OperationCall) (*firestore.WriteResult, error) {
+		ctx, cancel := coll.withTimeout(ctx)
+		defer cancel()
+		data, err := coll.protectUpdate(c.Data, false)
+		if err != nil {
+			return nil, err
+		}
+		dropMetadata(data)
+		if err := encodeValues(data); err != nil {
+			return nil, err
+		}
+		data[UpdatedAtFieldName] = coll.now()
+		coll.normalizeFields(data)
+		if err := coll.updateSearchKeywords(ctx, c.DocID, data); err != nil {
+			return nil, err
+		}
+		if err := coll.validateUpdate(ctx, c.DocID, data); err != nil {
+			return nil, err
+		}
+		if field, ok := coll.uniqueDeepField(FlattenToUpdates(data)); ok {
+			return nil, errors.New(fmt.Sprintf("unique field %s cannot be changed by UpdateDocDeep, use UpdateDoc", field))
+		}
+		stored, err := coll.encryptFields(data)
+		if err != nil {
+			return nil, err
+		}
+		return coll.ref.Doc(c.DocID).Update(ctx, FlattenToUpdates(stored))
+	})
+}
+
+// UpdateDocFn updates doc id with the fields fn changes in a copy of it,
+// inside a transaction: fn is called again with fresh data when the
+// transaction retries, and an fn error aborts without writing. A nil doc
+// from fn changes nothing. It returns the updated doc.
+func (coll *Collection) UpdateDocFn(ctx context.Context, id string, fn func(current map[string]any) (map[string]any, error)) (map[string]any, error) {
+	return run(ctx, coll, call{Op: "UpdateDocFn", DocID: id}, func(ctx context.Context, c *OperationCall) (map[string]any, error) {
+		ctx, cancel := coll.withTimeout(ctx)
+		defer cancel()
+		ref := coll.ref.Doc(c.DocID)
+		var result map[string]any
+		err := coll.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			snap, err := tx.Get(ref)
+			if status.Code(err) == codes.NotFound {
+				return docNotFound(c.DocID)
+			}
+			if err != nil {
+				return err
+			}
+			current := snap.Data()
+			if err := coll.decryptFields(current); err != nil {
+				return err
+			}
+			after, err := fn(deepCopyMap(current).(map[string]any))
+			if err != nil {
+				return err
+			}
+			var updates []firestore.Update
+			if after != nil {
+				coll.normalizeFields(after)
+				coll.setSearchKeywords(after)
+				updates = makeUpdateData(current, after)
+			}
+			if len(updates) == 0 {
+				result = lo.Assign(current, map[string]any{"_id": snap.Ref.ID, "_ref": snap.Ref.Path})
+				return nil
+			}
+			if field, ok := coll.uniqueUpdateField(updates); ok {
+				return errors.New(fmt.Sprintf("unique field %s cannot be changed by UpdateDocFn, use UpdateDoc", field))
+			}
+			if err := coll.validateDoc(after); err != nil {
+				return err
+			}
+			updates, err = coll.encryptUpdates(updates)
+			if err != nil {
+				return err
+			}
+			now := coll.now()
+			updates = append(updates, firestore.Update{Path: UpdatedAtFieldName, Value: now})
+			result = lo.Assign(after, map[string]any{UpdatedAtFieldName: now, "_id": snap.Ref.ID, "_ref": snap.Ref.Path})
+			return tx.Update(ref, updates)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return coll.shapeDoc(result), nil
+	})
+}
@@ -0,0 +1,46 @@
+package cffirestore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUpdateDocFn(t *testing.T) {
+	coll := emulatorCollection(t).WithNormalizedFields("name")
+	ctx := context.Background()
+	id := "a"
+	if _, _, err := coll.AddDocWithId(&id, nil, map[string]any{"name": "John Doe", "age": int64(30)}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := coll.UpdateDocFn(ctx, id, func(current map[string]any) (map[string]any, error) {
+		current["name"] = "Jane Doe"
+		return current, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated["name_lower"] != "jane doe" {
+		t.Errorf("returned name_lower = %v, want jane doe", updated["name_lower"])
+	}
+	doc, err := coll.GetDoc(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["name_lower"] != "jane doe" {
+		t.Errorf("stored name_lower = %v, want jane doe", doc["name_lower"])
+	}
+
+	if _, err := coll.UpdateDocFn(ctx, id, func(current map[string]any) (map[string]any, error) {
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := coll.GetDoc(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after["name"] != "Jane Doe" || after["age"] != int64(30) || !after[UpdatedAtFieldName].(time.Time).Equal(doc[UpdatedAtFieldName].(time.Time)) {
+		t.Errorf("doc after a nil update = %v, want %v", after, doc)
+	}
+}
@@ -0,0 +1,213 @@
+package cffirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"sync"
+	"time"
+)
+
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeModified
+	ChangeRemoved
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeModified:
+		return "modified"
+	case ChangeRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// DocChange is a change of the docs matching a watched condition. Doc is
+// the doc after the change, or the last known one when removed.
+type DocChange struct {
+	Kind ChangeKind
+	ID   string
+	Doc  map[string]any
+}
+
+// Watcher sends the changes of the docs matching a condition, see Watch.
+type Watcher struct {
+	changes chan DocChange
+	done    chan struct{}
+	mu      sync.Mutex
+	err     error
+	// docs are the docs last sent, to catch up after a reconnect.
+	docs map[string]watchedDoc
+}
+
+type watchedDoc struct {
+	updated time.Time
+	doc     map[string]any
+}
+
+// Watch sends the docs matching condition as added then their changes,
+// until ctx is done or the listener fails, see Err, when Changes is
+// closed. Transient listener errors are logged and retried with backoff,
+// and the changes missed meanwhile are sent on reconnect.
+func (coll *Collection) Watch(ctx context.Context, condition []any) (*Watcher, error) {
+	return run(ctx, coll, call{Op: "Watch", Condition: condition}, func(_ context.Context, c *OperationCall) (*Watcher, error) {
+		return coll.watch(ctx, c.Condition)
+	})
+}
+
+// watch starts listening with ctx, which outlives the Watch call.
+func (coll *Collection) watch(ctx context.Context, condition []any) (*Watcher, error) {
+	query, err := coll.makeQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		changes: make(chan DocChange),
+		done:    make(chan struct{}),
+		docs:    make(map[string]watchedDoc),
+	}
+	go func() {
+		defer close(w.done)
+		defer close(w.changes)
+		retry := &backoff{}
+		for {
+			err := w.listen(ctx, coll, query, retry)
+			if ctx.Err() != nil {
+				return
+			}
+			cbErr, failed := err.(*callbackError)
+			if failed {
+				err = cbErr.err
+			}
+			if failed || !isTransient(err) {
+				coll.logf("watch %s: %v, stopped", coll.Path, err)
+				w.mu.Lock()
+				w.err = coll.wrapErr("Watch", err)
+				w.mu.Unlock()
+				return
+			}
+			wait := retry.next()
+			coll.logf("watch %s: %v, retrying in %s", coll.Path, err, wait)
+			if !sleep(ctx, wait) {
+				return
+			}
+		}
+	}()
+	return w, nil
+}
+
+// listen sends the changes of query until its stream fails. The first
+// snapshot of a stream is compared to the docs last sent instead of
+// trusting its changes, which are all additions.
+func (w *Watcher) listen(ctx context.Context, coll *Collection, query firestore.Query, retry *backoff) error {
+	iter := query.Snapshots(ctx)
+	defer iter.Stop()
+	first := true
+	for {
+		snap, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		retry.reset()
+		if first {
+			first = false
+			snaps, err := snap.Documents.GetAll()
+			if err != nil {
+				return err
+			}
+			if err := w.catchUp(ctx, coll, snaps); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, change := range snap.Changes {
+			id := change.Doc.Ref.ID
+			if change.Kind == firestore.DocumentRemoved {
+				if err := w.send(ctx, DocChange{Kind: ChangeRemoved, ID: id, Doc: w.docs[id].doc}); err != nil {
+					return err
+				}
+				delete(w.docs, id)
+				continue
+			}
+			kind := ChangeModified
+			if change.Kind == firestore.DocumentAdded {
+				kind = ChangeAdded
+			}
+			if err := w.sendSnap(ctx, coll, kind, change.Doc); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *Watcher) catchUp(ctx context.Context, coll *Collection, snaps []*firestore.DocumentSnapshot) error {
+	seen := make(map[string]bool, len(snaps))
+	for _, s := range snaps {
+		seen[s.Ref.ID] = true
+		last, known := w.docs[s.Ref.ID]
+		if known && last.updated.Equal(s.UpdateTime) {
+			continue
+		}
+		kind := ChangeAdded
+		if known {
+			kind = ChangeModified
+		}
+		if err := w.sendSnap(ctx, coll, kind, s); err != nil {
+			return err
+		}
+	}
+	for id, last := range w.docs {
+		if seen[id] {
+			continue
+		}
+		if err := w.send(ctx, DocChange{Kind: ChangeRemoved, ID: id, Doc: last.doc}); err != nil {
+			return err
+		}
+		delete(w.docs, id)
+	}
+	return nil
+}
+
+func (w *Watcher) sendSnap(ctx context.Context, coll *Collection, kind ChangeKind, snap *firestore.DocumentSnapshot) error {
+	doc := coll.docResponse(snap)
+	if err := coll.decryptFields(doc); err != nil {
+		return &callbackError{docErr(snap.Ref.ID, err)}
+	}
+	doc = coll.shapeDoc(doc)
+	w.docs[snap.Ref.ID] = watchedDoc{updated: snap.UpdateTime, doc: doc}
+	return w.send(ctx, DocChange{Kind: kind, ID: snap.Ref.ID, Doc: deepCopyMap(doc).(map[string]any)})
+}
+
+// send blocks until the change is received or ctx is done.
+func (w *Watcher) send(ctx context.Context, change DocChange) error {
+	select {
+	case w.changes <- change:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Changes is closed once the listener stopped, see Err.
+func (w *Watcher) Changes() <-chan DocChange {
+	return w.changes
+}
+
+// Done is closed once the listener stopped, after Changes.
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Err returns the error that stopped the listener, nil when its context
+// ended it.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
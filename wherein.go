@@ -0,0 +1,98 @@
+package cffirestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+	"strings"
+	"sync/atomic"
+)
+
+var WhereInChunkSize = 30
+var WhereInConcurrency = 4
+
+// ListDocsWhereIn lists docs whose field is in values, splitting values into
+// chunks of WhereInChunkSize so lists past Firestore's "in" limit work. The
+// merged docs are de-duplicated, sorted by the condition's orderBy and cut
+// to its limit. op defaults to "in" and may be "array-contains-any".
+func (coll *Collection) ListDocsWhereIn(ctx context.Context, field string, values []any, condition []any, op ...string) ([]map[string]any, error) {
+	return run(ctx, coll, call{Op: "ListDocsWhereIn", Condition: condition}, func(ctx context.Context, c *OperationCall) ([]map[string]any, error) {
+		return coll.listDocsWhereIn(ctx, field, values, c.Condition, op...)
+	})
+}
+
+func (coll *Collection) listDocsWhereIn(ctx context.Context, field string, values []any, condition []any, op ...string) ([]map[string]any, error) {
+	operator := "in"
+	if len(op) > 0 {
+		operator = strings.ToLower(op[0])
+	}
+	if operator != "in" && operator != "array-contains-any" {
+		return nil, errors.New(fmt.Sprintf("unsupported operator for ListDocsWhereIn: %s", operator))
+	}
+	if len(values) == 0 {
+		return []map[string]any{}, nil
+	}
+	plan, err := coll.planQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	orderBys, err := plan.fullOrderBys()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := plan.option("offset"); ok {
+		return nil, errors.New("offset is not supported by ListDocsWhereIn")
+	}
+
+	chunks := lo.Chunk(lo.Uniq(values), WhereInChunkSize)
+	chunkDocs := make([][]map[string]any, len(chunks))
+	var truncated atomic.Bool
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(WhereInConcurrency)
+	for i, chunk := range chunks {
+		i, chunk := i, chunk
+		g.Go(func() error {
+			chunkCondition := append([]any{[]any{field, operator, chunk}}, condition...)
+			docs, err := coll.listRawDocs(gctx, chunkCondition)
+			if errors.Is(err, ErrResultTruncated) {
+				truncated.Store(true)
+			} else if err != nil {
+				return err
+			}
+			chunkDocs[i] = docs
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	docs := make([]map[string]any, 0)
+	for _, chunk := range chunkDocs {
+		for _, doc := range chunk {
+			ref, _ := doc["_ref"].(string)
+			if !seen[ref] {
+				seen[ref] = true
+				docs = append(docs, doc)
+			}
+		}
+	}
+	sortDocs(docs, orderBys)
+	if limit, ok := plan.option("limit"); ok {
+		n, err := intOption("limit", limit)
+		if err != nil {
+			return nil, err
+		}
+		if n < len(docs) {
+			docs = docs[:n]
+		}
+	}
+	docs, err = coll.truncateDocs(docs, coll.maxDocsCap(condition))
+	if err == nil && truncated.Load() && coll.truncationMode == ReportTruncation {
+		err = fmt.Errorf("%w: at most %d docs per chunk", ErrResultTruncated, coll.maxDocs)
+	}
+	return coll.shapeDocs(docs), err
+}